@@ -0,0 +1,23 @@
+package posix
+
+import "slices"
+
+// allowed reports whether the parameter may be expanded with the Allow and
+// VarsOnly options.
+func (ev *evaluator) allowed(name string) bool {
+	if !isVarName(name) {
+		return !ev.opts.VarsOnly
+	}
+	return ev.opts.Allow == nil || slices.Contains(ev.opts.Allow, name)
+}
+
+// disallow returns the result of a reference to a variable that is not
+// allowed, which is the reference unchanged, or an error with the
+// DisallowError option.
+func (ev *evaluator) disallow(dst segments, n Node, pos Pos, err *ExpandError) (segments, error) {
+	if ev.opts.DisallowError && isVarName(err.Name) {
+		err.Err = disallowedError{err.Name}
+		return nil, ev.error(pos, err)
+	}
+	return ev.output(dst, ev.verbatim(n)...)
+}
@@ -0,0 +1,61 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var allowtests = []struct {
+	in  string
+	out string
+}{
+	{"$FOO $BAR", "foo bar"},
+	{"$FOO $HOME", "foo $HOME"},
+	{"$HOME/bin ${HOME}", "$HOME/bin ${HOME}"},
+	{"$HOME$FOO $HOME.x", "${HOME}foo ${HOME}.x"},
+	{"${#HOME}", "${#HOME}"},
+	{"${HOME:-$FOO}", "${HOME:-$FOO}"},
+	{"${FOO:+$HOME}", "${HOME}"},
+	{"${UNSET:-$FOO}", "foo"},
+	{"$# $1", "0 "},
+}
+
+func TestExpand_allow(t *testing.T) {
+	mapping := Map{"FOO": "foo", "BAR": "bar", "HOME": "/home/me"}
+	for _, test := range allowtests {
+		out, err := Expand(test.in, mapping, WithAllow("FOO", "BAR", "UNSET"))
+		ok(t, err)
+		equals(t, test.out, out)
+	}
+}
+
+func TestExpand_varsOnly(t *testing.T) {
+	mapping := Params{Map{"FOO": "foo", "HOME": "/home/me"}, []string{"a"}}
+	out, err := Expand("$FOO $1 ${1} $# $$ $? $@ $* ${#1} ${1:-x}", mapping, WithVarsOnly())
+	ok(t, err)
+	equals(t, "foo $1 ${1} $# $$ $? $@ $* ${#1} ${1:-x}", out)
+
+	_, err = Expand("$FOO $HOME $1 $$", mapping, WithVarsOnly(), WithAllow("FOO"), WithDisallowError())
+	equals(t, "HOME: variable not allowed", err.Error())
+	out, err = Expand("$FOO $1 $$", mapping, WithVarsOnly(), WithAllow("FOO"), WithDisallowError())
+	ok(t, err)
+	equals(t, "foo $1 $$", out)
+}
+
+func TestExpand_allowNone(t *testing.T) {
+	out, err := Expand("$FOO", Map{"FOO": "foo"}, WithAllow())
+	ok(t, err)
+	equals(t, "$FOO", out)
+}
+
+func TestExpand_disallowError(t *testing.T) {
+	_, err := Expand("$FOO ${HOME:-x}", Map{"FOO": "foo"}, WithAllow("FOO"), WithDisallowError())
+	equals(t, "HOME: variable not allowed", err.Error())
+
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) {
+		t.Fatalf("expected an ExpandError, got: %#v", err)
+	}
+	equals(t, "HOME", expandErr.Name)
+	equals(t, '-', expandErr.Op)
+}
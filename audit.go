@@ -0,0 +1,138 @@
+package posix
+
+import (
+	"context"
+	"sync"
+)
+
+// Audit wraps a mapping to record the lookups and assignments made by
+// expansions, for debugging why a template expanded the way it did. Values
+// are not recorded, only their lengths, so the log can be kept even when
+// the mapping holds secrets. The log is safe for concurrent use, but the
+// positions of entries are only reliable when the Audit is used by one
+// expansion at a time.
+//
+// Assignments, special and positional parameters, and lookups that can
+// fail or take a context are passed through to the mapping when it
+// supports them.
+type Audit struct {
+	Getter
+
+	mu  sync.Mutex
+	pos Pos
+	log []AuditEntry
+}
+
+// AuditEntry records a single lookup or assignment.
+type AuditEntry struct {
+	// Set is true for an assignment, and false for a lookup.
+	Set bool
+
+	Name string
+
+	// Found reports whether a lookup found the variable set, and is true
+	// for a successful assignment.
+	Found bool
+
+	// Len is the length of the value that was found or assigned, or zero
+	// for the variables a SecretGetter reports as secret.
+	Len int
+
+	// Pos is the position of the reference in the template, or -1 for
+	// lookups that are not made for a reference, such as IFS for field
+	// splitting.
+	Pos Pos
+}
+
+// NewAudit returns an Audit recording the uses of the mapping.
+func NewAudit(mapping Getter) *Audit {
+	return &Audit{Getter: mapping, pos: -1}
+}
+
+// Log returns a copy of the entries recorded so far.
+func (a *Audit) Log() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEntry(nil), a.log...)
+}
+
+// Reset clears the recorded entries.
+func (a *Audit) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.log = nil
+}
+
+func (a *Audit) Get(k string) (string, bool) {
+	v, ok := a.Getter.Get(k)
+	a.record(AuditEntry{Name: k, Found: ok, Len: len(v)})
+	return v, ok
+}
+
+func (a *Audit) Lookup(k string) (string, bool, error) {
+	if eg, ok := a.Getter.(ErrGetter); ok {
+		v, ok, err := eg.Lookup(k)
+		a.record(AuditEntry{Name: k, Found: ok, Len: len(v)})
+		return v, ok, err
+	}
+	v, ok := a.Get(k)
+	return v, ok, nil
+}
+
+func (a *Audit) GetContext(ctx context.Context, k string) (string, bool, error) {
+	v, ok, err := getContext(ctx, a.Getter, k)
+	a.record(AuditEntry{Name: k, Found: ok, Len: len(v)})
+	return v, ok, err
+}
+
+func (a *Audit) Set(k, v string) error {
+	setter, ok := a.Getter.(Setter)
+	if !ok {
+		return assignError{k, a.Getter}
+	}
+	err := setter.Set(k, v)
+	a.record(AuditEntry{Set: true, Name: k, Found: err == nil, Len: len(v)})
+	return err
+}
+
+func (a *Audit) IsSecret(k string) bool {
+	return isSecret(a.Getter, k)
+}
+
+func (a *Audit) GetSpecial(k string) (string, bool) {
+	if sg, ok := a.Getter.(SpecialGetter); ok {
+		return sg.GetSpecial(k)
+	}
+	return "", false
+}
+
+func (a *Audit) Positional() []string {
+	args, _ := a.positional()
+	return args
+}
+
+func (a *Audit) positional() ([]string, bool) {
+	return positional(a.Getter)
+}
+
+// record adds an entry at the position of the current reference.
+func (a *Audit) record(e AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if isSecret(a.Getter, e.Name) {
+		e.Len = 0
+	}
+	e.Pos = a.pos
+	a.pos = -1
+	a.log = append(a.log, e)
+}
+
+// at sets the position of the reference that the next lookup or assignment
+// is made for, when the mapping is an Audit.
+func (ev *evaluator) at(pos Pos) {
+	if a, ok := ev.mapping.(*Audit); ok {
+		a.mu.Lock()
+		a.pos = pos
+		a.mu.Unlock()
+	}
+}
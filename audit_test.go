@@ -0,0 +1,66 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	a := NewAudit(RWMap{"A": "abc", "E": ""})
+	out, err := Expand("$A ${B:-x} ${#A} ${C:=12345}", a)
+	ok(t, err)
+	equals(t, "abc x 3 12345", out)
+	equals(t, []AuditEntry{
+		{Name: "A", Found: true, Len: 3, Pos: 0},
+		{Name: "B", Found: false, Pos: 3},
+		{Name: "A", Found: true, Len: 3, Pos: 11},
+		{Name: "C", Found: false, Pos: 17},
+		{Set: true, Name: "C", Found: true, Len: 5, Pos: 17},
+	}, a.Log())
+
+	a.Reset()
+	equals(t, 0, len(a.Log()))
+}
+
+func TestAudit_fields(t *testing.T) {
+	a := NewAudit(Map{"A": "x y"})
+	fields, err := ExpandFields("$A", a)
+	ok(t, err)
+	equals(t, []string{"x", "y"}, fields)
+	equals(t, []AuditEntry{
+		{Name: "A", Found: true, Len: 3, Pos: 0},
+		{Name: "IFS", Found: false, Pos: -1},
+	}, a.Log())
+}
+
+func TestAudit_passThrough(t *testing.T) {
+	a := NewAudit(Params{Getter: remoteMap{"A": "a"}, Args: []string{"one"}})
+	out, err := Expand("$1 $A $#", a)
+	ok(t, err)
+	equals(t, "one a 1", out)
+	equals(t, []AuditEntry{{Name: "A", Found: true, Len: 1, Pos: 3}}, a.Log())
+
+	_, err = Expand("$FAIL", a)
+	equals(t, true, errors.Is(err, errLookup))
+
+	_, err = Expand("${B:=x}", NewAudit(Map{}))
+	equals(t, "mapping type posix.Map does not support assignment", err.Error())
+
+	// positional parameters without a Positional mapping are looked up with
+	// Get, the same as without the Audit
+	a = NewAudit(Map{"1": "one"})
+	out, err = Expand("${1}X$1", a)
+	ok(t, err)
+	equals(t, "oneXone", out)
+	equals(t, 2, len(a.Log()))
+}
+
+func TestAudit_context(t *testing.T) {
+	a := NewAudit(secretStore{})
+	tenant := context.WithValue(context.Background(), tenantKey{}, "acme")
+	out, err := ExpandContext(tenant, "$TENANT", a)
+	ok(t, err)
+	equals(t, "acme", out)
+	equals(t, []AuditEntry{{Name: "TENANT", Found: true, Len: 4, Pos: 0}}, a.Log())
+}
@@ -0,0 +1,103 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ExpandAll expands each of the strings in order, like Expand. Assignments
+// made by earlier strings are visible to later ones: if the mapping is a
+// Setter they are made to the mapping, otherwise they are kept for the rest
+// of the batch without changing the mapping.
+//
+// Every string is expanded even if some fail. The result for a string with
+// an error is empty, and the error returned joins an IndexError for each
+// failed string.
+func ExpandAll(ss []string, mapping Getter) ([]string, error) {
+	return new(Expander).ExpandAll(ss, mapping)
+}
+
+// ExpandAll expands each of the strings like ExpandAll, using the options
+// set on the Expander.
+func (e *Expander) ExpandAll(ss []string, mapping Getter) ([]string, error) {
+	if _, ok := mapping.(Setter); !ok {
+		mapping = &overlay{Getter: mapping, vars: make(map[string]string)}
+	}
+	out := make([]string, len(ss))
+	var errs []error
+	for i, s := range ss {
+		v, err := e.Expand(s, mapping)
+		if err != nil {
+			errs = append(errs, &IndexError{Index: i, Err: err})
+			continue
+		}
+		out[i] = v
+	}
+	return out, errors.Join(errs...)
+}
+
+// IndexError is an error expanding one of the strings in a batch.
+type IndexError struct {
+	Index int
+	Err   error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("[%d]: %s", e.Index, e.Err)
+}
+
+func (e *IndexError) Unwrap() error {
+	return e.Err
+}
+
+// overlay is a Setter that keeps assignments separately from the mapping
+// it wraps. Lookup errors, special and positional parameters, and secrets
+// are passed through to the mapping.
+type overlay struct {
+	Getter
+	vars map[string]string
+}
+
+func (o *overlay) Get(k string) (string, bool) {
+	if v, ok := o.vars[k]; ok {
+		return v, true
+	}
+	return o.Getter.Get(k)
+}
+
+func (o *overlay) Set(k, v string) error {
+	o.vars[k] = v
+	return nil
+}
+
+func (o *overlay) Lookup(k string) (string, bool, error) {
+	if v, ok := o.vars[k]; ok {
+		return v, true, nil
+	}
+	return lookupIn(o.Getter, k)
+}
+
+func (o *overlay) GetContext(ctx context.Context, k string) (string, bool, error) {
+	if v, ok := o.vars[k]; ok {
+		return v, true, nil
+	}
+	return getContext(ctx, o.Getter, k)
+}
+
+func (o *overlay) IsSecret(k string) bool {
+	return isSecret(o.Getter, k)
+}
+
+func (o *overlay) GetSpecial(k string) (string, bool) {
+	return getSpecial(o.Getter, k)
+}
+
+func (o *overlay) Positional() []string {
+	args, _ := o.positional()
+	return args
+}
+
+func (o *overlay) positional() ([]string, bool) {
+	return positional(o.Getter)
+}
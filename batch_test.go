@@ -0,0 +1,42 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandAll(t *testing.T) {
+	mapping := Map{"a": "1"}
+	out, err := ExpandAll([]string{"$a", "${b:=2}", "$a$b"}, mapping)
+	ok(t, err)
+	equals(t, []string{"1", "2", "12"}, out)
+
+	// the read-only mapping is not changed
+	equals(t, Map{"a": "1"}, mapping)
+}
+
+func TestExpandAll_setter(t *testing.T) {
+	mapping := RWMap{}
+	out, err := ExpandAll([]string{"${a:=x}", "$a"}, mapping)
+	ok(t, err)
+	equals(t, []string{"x", "x"}, out)
+	equals(t, RWMap{"a": "x"}, mapping)
+}
+
+func TestExpandAll_errors(t *testing.T) {
+	out, err := ExpandAll([]string{"${a:?}", "ok", "${b", "$c"}, Map{"c": "3"})
+	equals(t, []string{"", "ok", "", "3"}, out)
+	equals(t, "[0]: a: parameter null or not set\n[2]: unexpected EOF while looking for matching `}'", err.Error())
+
+	var indexErr *IndexError
+	if !errors.As(err, &indexErr) {
+		t.Fatalf("expected an IndexError, got: %#v", err)
+	}
+	equals(t, 0, indexErr.Index)
+}
+
+func TestExpandAll_lookupError(t *testing.T) {
+	out, err := ExpandAll([]string{"$A", "$FAIL"}, remoteMap{"A": "a"})
+	equals(t, []string{"a", ""}, out)
+	equals(t, true, errors.Is(err, errLookup))
+}
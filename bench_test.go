@@ -0,0 +1,81 @@
+package posix
+
+import (
+	"strings"
+	"testing"
+)
+
+var benchMapping = Map{"HOME": "/home/me", "USER": "me", "EMPTY": ""}
+
+const benchLong = "user=$USER home=${HOME} default=${MISSING:-value} alt=${USER:+yes} nested=${MISSING:-${HOME:-x}/y}"
+
+// benchCases are the inputs for the benchmarks and allocation guards, with
+// the most allocations expanding each one is expected to take.
+var benchCases = []struct {
+	name   string
+	in     string
+	allocs float64
+}{
+	{"plain", "no expansions in this string at all", 0},
+	{"simple", "$HOME/bin", 13},
+	{"nested", "${MISSING:-${UNSET:-${HOME}/default}}", 24},
+	{"ops", "${USER:-nobody}@${HOST-localhost}:${EMPTY:+set}", 32},
+	{"long", benchLong, 51},
+	{"static", strings.Repeat("mostly static text, with a few expansions. ", 50) + "$HOME", 13},
+	{"deep", strings.Repeat("${A:-", 20) + "x" + strings.Repeat("}", 20), 76},
+}
+
+func BenchmarkExpand(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Expand(c.in, benchMapping); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExecute(b *testing.B) {
+	t, err := Parse(benchLong)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := t.Execute(benchMapping); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestExpand_allocs(t *testing.T) {
+	for _, c := range benchCases {
+		t.Run(c.name, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(100, func() {
+				Expand(c.in, benchMapping)
+			})
+			if allocs > c.allocs {
+				t.Errorf("Expand(%q) allocated %v times, want at most %v", c.in, allocs, c.allocs)
+			}
+		})
+	}
+}
+
+func TestExecute_allocs(t *testing.T) {
+	for _, c := range benchCases {
+		t.Run(c.name, func(t *testing.T) {
+			tmpl, err := Parse(c.in)
+			ok(t, err)
+			allocs := testing.AllocsPerRun(100, func() {
+				tmpl.Execute(benchMapping)
+			})
+			// the evaluator, the segment buffer as it grows, and the result
+			if allocs > 4 {
+				t.Errorf("Execute(%q) allocated %v times, want at most 4", c.in, allocs)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package posix
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// expandBraceWords performs brace expansion on each word of the string,
+// replacing a word like "a{b,c}" with its expansions separated by spaces.
+// Text in "${...}" or escaped by a backslash is not brace expanded, and
+// neither is quoted text when quoting applies outside of parameter expansions.
+//
+// When limit is positive, it returns false as soon as the expanded text is
+// longer than limit, before building the rest of the words.
+func expandBraceWords(s string, quoting bool, limit int) (string, bool) {
+	if strings.IndexByte(s, '{') < 0 {
+		return s, limit <= 0 || len(s) <= limit
+	}
+
+	var buf strings.Builder
+	start := 0
+	flush := func(end int) bool {
+		if end > start {
+			words := expandBraces(s[start:end], quoting, limit)
+			if words == nil {
+				return false
+			}
+			buf.WriteString(strings.Join(words, " "))
+		}
+		return limit <= 0 || buf.Len() <= limit
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			if !flush(i) {
+				return "", false
+			}
+			buf.WriteByte(c)
+			start = i + 1
+		default:
+			i += braceSkip(s[i:], quoting) - 1
+		}
+	}
+	if !flush(len(s)) {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// expandBraces returns the brace expansion of a single word, or nil when
+// limit is positive and the words are longer than it in total. Since each
+// expansion of the rest of the word is part of the words, the limit also
+// stops the recursion early.
+func expandBraces(word string, quoting bool, limit int) []string {
+	for i := 0; i < len(word); i += braceSkip(word[i:], quoting) {
+		if word[i] != '{' {
+			continue
+		}
+		end, parts := braceParts(word[i:], quoting)
+		if parts == nil {
+			continue
+		}
+
+		prefix := word[:i]
+		suffixes := expandBraces(word[i+end:], quoting, limit)
+		if suffixes == nil {
+			return nil
+		}
+		var words []string
+		size := 0
+		for _, part := range parts {
+			expanded := expandBraces(part, quoting, limit)
+			if expanded == nil {
+				return nil
+			}
+			for _, w := range expanded {
+				for _, suffix := range suffixes {
+					words = append(words, prefix+w+suffix)
+					size += len(prefix) + len(w) + len(suffix) + 1
+					if limit > 0 && size > limit+1 {
+						return nil
+					}
+				}
+			}
+		}
+		return words
+	}
+	return []string{word}
+}
+
+// braceParts splits a brace expression at the start of the string into its
+// comma-separated parts or the elements of its sequence, returning its
+// length and the parts, or nil if the braces are not closed or do not
+// contain a comma or sequence.
+func braceParts(s string, quoting bool) (int, []string) {
+	var parts []string
+	depth := 0
+	start := 1
+	for i := 0; i < len(s); i += braceSkip(s[i:], quoting) {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				if parts == nil {
+					if seq := braceSequence(s[1:i]); seq != nil {
+						return i + 1, seq
+					}
+					return 0, nil
+				}
+				return i + 1, append(parts, s[start:i])
+			}
+		case ',':
+			if depth == 1 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return 0, nil
+}
+
+// braceSkip returns the length of the text at the start of the string that
+// is not subject to brace expansion, or 1 to consider the next character.
+func braceSkip(s string, quoting bool) int {
+	switch s[0] {
+	case '$':
+		if strings.HasPrefix(s, "${") {
+			if end := scriptRefEnd(s); end > 0 {
+				return end
+			}
+			// an unterminated expansion extends to the end of the input,
+			// where the lexer reports it as an error
+			return len(s)
+		}
+	case '\\':
+		if len(s) > 1 {
+			return 2
+		}
+	case '\'', '"':
+		if quoting {
+			if end := strings.IndexByte(s[1:], s[0]); end >= 0 {
+				return end + 2
+			}
+		}
+	}
+	return 1
+}
+
+// braceSequence returns the elements of a sequence expression like "1..10",
+// "a..f" or "0..100..5", or nil if the string is not a valid sequence.
+func braceSequence(s string) []string {
+	bounds := strings.Split(s, "..")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil
+	}
+
+	var step uint64 = 1
+	if len(bounds) == 3 {
+		n, err := strconv.Atoi(bounds[2])
+		if err != nil {
+			return nil
+		}
+		if n < 0 {
+			// -n overflows for the smallest int
+			step = uint64(-(n + 1)) + 1
+		} else if n != 0 {
+			step = uint64(n)
+		}
+	}
+
+	start, err1 := strconv.Atoi(bounds[0])
+	end, err2 := strconv.Atoi(bounds[1])
+	if err1 == nil && err2 == nil {
+		width := 0
+		if zeroPadded(bounds[0]) || zeroPadded(bounds[1]) {
+			width = max(len(bounds[0]), len(bounds[1]))
+		}
+		nums := sequence(start, end, step)
+		if nums == nil {
+			return nil
+		}
+		seq := make([]string, 0, len(nums))
+		for _, n := range nums {
+			seq = append(seq, padNumber(n, width))
+		}
+		return seq
+	}
+
+	a, b := bounds[0], bounds[1]
+	if utf8.RuneCountInString(a) != 1 || utf8.RuneCountInString(b) != 1 || !isAlpha(rune(a[0])) || !isAlpha(rune(b[0])) {
+		return nil
+	}
+	var seq []string
+	for _, c := range sequence(int(a[0]), int(b[0]), step) {
+		seq = append(seq, string(rune(c)))
+	}
+	return seq
+}
+
+// maxBraceSequence is the most elements a sequence expression may have. A
+// longer sequence is not expanded, rather than allocating without bound.
+const maxBraceSequence = 1 << 16
+
+// sequence returns the numbers from start to end inclusive, counting by step
+// up or down towards end, or nil if there would be more than
+// maxBraceSequence of them. The count is computed up front, so the numbers
+// never overflow near the bounds of an int.
+func sequence(start, end int, step uint64) []int {
+	// the difference of two ints always fits in a uint64
+	span := uint64(end) - uint64(start)
+	if start > end {
+		span = uint64(start) - uint64(end)
+	}
+	if span/step >= maxBraceSequence {
+		return nil
+	}
+	seq := make([]int, span/step+1)
+	for i := range seq {
+		// the offset is at most span, so it doesn't overflow past end
+		offset := uint64(i) * step
+		if start <= end {
+			seq[i] = int(uint64(start) + offset)
+		} else {
+			seq[i] = int(uint64(start) - offset)
+		}
+	}
+	return seq
+}
+
+// zeroPadded reports whether a number is written with leading zeros, which
+// pads all the numbers in a sequence to the same width.
+func zeroPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+func padNumber(n, width int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + strings.Repeat("0", max(0, width-len(s))) + s[1:]
+	}
+	return strings.Repeat("0", max(0, width-len(s))) + s
+}
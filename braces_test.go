@@ -0,0 +1,84 @@
+package posix
+
+import "testing"
+
+var bracetests = []struct {
+	in  string
+	out string
+}{
+	{"a{b,c}d", "abd acd"},
+	{"{a,b} {c,d}", "a b c d"},
+	{"x  {a,b}  y", "x  a b  y"},
+	{"{a,b}{1,2}", "a1 a2 b1 b2"},
+	{"{a,b{c,d}}e", "ae bce bde"},
+	{"{a,,b}", "a  b"},
+	{"{a}", "{a}"},
+	{"{}", "{}"},
+	{"{a,b", "{a,b"},
+	{"{a{b,c}}", "{ab} {ac}"},
+	{`\{a,b}`, `\{a,b}`},
+	{"$set{2,3}", "yes-two "}, // like bash, expands to $set2 $set3
+	{"${set}{1,2}", "yes1 yes2"},
+	{"{$set,${set2}}", "yes yes-two"},
+	{"${set:+{a,b}}", "{a,b}"},
+	{"${unset:-x}{a,b}", "xa xb"},
+	{"${unset+{,}{,}", "{,}"}, // braces in an unterminated "${" are kept
+
+	// sequences
+	{"{1..5}", "1 2 3 4 5"},
+	{"{3..1}", "3 2 1"},
+	{"{-2..2}", "-2 -1 0 1 2"},
+	{"{0..20..5}", "0 5 10 15 20"},
+	{"{20..0..-5}", "20 15 10 5 0"},
+	{"{1..10..0}", "1 2 3 4 5 6 7 8 9 10"},
+	{"{01..10..3}", "01 04 07 10"},
+	{"{a..e}", "a b c d e"},
+	{"{e..a..2}", "e c a"},
+	{"f{1..2}{a..b}", "f1a f1b f2a f2b"},
+	{"{x,{1..3}}", "x 1 2 3"},
+	{"{1..}", "{1..}"},
+	{"{a..5}", "{a..5}"},
+	{"{ab..c}", "{ab..c}"},
+	{"{1..2..x}", "{1..2..x}"},
+
+	// sequences near the bounds of an int don't overflow
+	{"{9223372036854775806..9223372036854775807}", "9223372036854775806 9223372036854775807"},
+	{"{-9223372036854775807..-9223372036854775808}", "-9223372036854775807 -9223372036854775808"},
+	{"{9223372036854775807..9223372036854775800..-9223372036854775808}", "9223372036854775807"},
+	{"{-9223372036854775808..9223372036854775807..9223372036854775807}", "-9223372036854775808 -1 9223372036854775806"},
+
+	// sequences that are too long are not expanded
+	{"{1..65537}", "{1..65537}"},
+	{"{-9223372036854775808..9223372036854775807}", "{-9223372036854775808..9223372036854775807}"},
+}
+
+func TestExpand_braces(t *testing.T) {
+	mapping := Map{
+		"set":  "yes",
+		"set2": "yes-two",
+	}
+	e := &Expander{Dialect: Bash}
+
+	for _, tt := range bracetests {
+		x, err := e.Expand(tt.in, mapping)
+		if err != nil {
+			t.Errorf("pattern %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if x != tt.out {
+			t.Errorf("pattern %#v should expand to %#v, but got %#v", tt.in, tt.out, x)
+		}
+	}
+}
+
+func TestExpand_bracesPOSIX(t *testing.T) {
+	x, err := Expand("a{b,c}", Map{})
+	ok(t, err)
+	equals(t, "a{b,c}", x)
+}
+
+func TestExpandFields_braces(t *testing.T) {
+	e := &Expander{Dialect: Bash}
+	x, err := e.ExpandFields(`a{"b c",d} "{e,f}" '{g,h}' {$x,y}`, Map{"x": "1 2"})
+	ok(t, err)
+	equals(t, []string{"ab c", "ad", "{e,f}", "{g,h}", "1", "2", "y"}, x)
+}
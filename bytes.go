@@ -0,0 +1,40 @@
+package posix
+
+// ExpandBytes expands the parameters in b like Expand, returning the result
+// as a byte slice.
+func ExpandBytes(b []byte, mapping Getter) ([]byte, error) {
+	return new(Expander).ExpandBytes(b, mapping)
+}
+
+// ExpandBytes expands the parameters in b like ExpandBytes, using the
+// options set on the Expander.
+func (e *Expander) ExpandBytes(b []byte, mapping Getter) ([]byte, error) {
+	if e.literal(string(b)) {
+		return append([]byte(nil), b...), nil
+	}
+	// the parsed nodes and errors refer to parts of the input, so it is
+	// copied in case the caller reuses b
+	t, err := e.Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	return t.AppendExecute(nil, mapping)
+}
+
+// AppendExecute expands the template based on the mapping like Execute,
+// appending the result to dst and returning the extended slice.
+func (t *Template) AppendExecute(dst []byte, mapping Getter, opts ...Option) ([]byte, error) {
+	e, err := t.options(opts)
+	if err != nil {
+		return dst, err
+	}
+	ev := &evaluator{mapping: mapping, opts: e, src: t.src}
+	segs, err := ev.evalAll(t.Nodes)
+	if err != nil && !e.AllErrors {
+		return dst, e.wrapError(err)
+	}
+	for _, seg := range segs {
+		dst = append(dst, seg.text...)
+	}
+	return dst, e.wrapError(err)
+}
@@ -0,0 +1,29 @@
+package posix
+
+import "testing"
+
+func TestExpandBytes(t *testing.T) {
+	in := []byte("a=$a b=${b:-default}")
+	out, err := ExpandBytes(in, Map{"a": "1"})
+	ok(t, err)
+	equals(t, "a=1 b=default", string(out))
+	equals(t, "a=$a b=${b:-default}", string(in))
+}
+
+func TestExpandBytes_error(t *testing.T) {
+	in := []byte("${a:?is required}")
+	_, err := ExpandBytes(in, Map{})
+	copy(in, "xxxxxxxxxxxxxxxxx")
+	equals(t, "is required", err.Error())
+}
+
+func TestTemplate_AppendExecute(t *testing.T) {
+	tmpl, err := Parse("$a,")
+	ok(t, err)
+	var buf []byte
+	for _, a := range []string{"x", "y", "z"} {
+		buf, err = tmpl.AppendExecute(buf, Map{"a": a})
+		ok(t, err)
+	}
+	equals(t, "x,y,z,", string(buf))
+}
@@ -0,0 +1,94 @@
+package posix
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache memoizes parsed templates by their input string, so strings that are
+// expanded repeatedly are only parsed once. The least recently used
+// templates are evicted once the cache is full. A Cache is safe for
+// concurrent use by multiple goroutines.
+type Cache struct {
+	opts Expander
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	input string
+	tmpl  *Template
+}
+
+// NewCache returns a Cache holding up to size templates, parsed with the
+// default options.
+func NewCache(size int) *Cache {
+	return new(Expander).NewCache(size)
+}
+
+// NewCache returns a Cache holding up to size templates, parsed with the
+// options set on the Expander.
+func (e *Expander) NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		opts:    *e,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// Parse returns the cached template for the string, parsing it and adding
+// it to the cache if it is not present. Strings with syntax errors are not
+// cached.
+func (c *Cache) Parse(s string) (*Template, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[s]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*cacheEntry).tmpl, nil
+	}
+	c.mu.Unlock()
+
+	tmpl, err := c.opts.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[s]; ok {
+		// parsed concurrently by another caller
+		c.order.MoveToFront(el)
+		return el.Value.(*cacheEntry).tmpl, nil
+	}
+	c.entries[s] = c.order.PushFront(&cacheEntry{s, tmpl})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).input)
+	}
+	return tmpl, nil
+}
+
+// Expand replaces ${var} or $var in the string based on the mapping, like
+// Expand, using the cached template for the string.
+func (c *Cache) Expand(s string, mapping Getter) (string, error) {
+	tmpl, err := c.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Execute(mapping)
+}
+
+// Len returns the number of templates in the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,58 @@
+package posix
+
+import "testing"
+
+func TestCache_reuse(t *testing.T) {
+	c := NewCache(2)
+	a, err := c.Parse("$a")
+	ok(t, err)
+	again, err := c.Parse("$a")
+	ok(t, err)
+	if a != again {
+		t.Fatal("expected the cached template to be reused")
+	}
+
+	out, err := c.Expand("$a-$b", Map{"a": "1", "b": "2"})
+	ok(t, err)
+	equals(t, "1-2", out)
+	equals(t, 2, c.Len())
+}
+
+func TestCache_evictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(2)
+	a, err := c.Parse("$a")
+	ok(t, err)
+	_, err = c.Parse("$b")
+	ok(t, err)
+	_, err = c.Parse("$a")
+	ok(t, err)
+	_, err = c.Parse("$c")
+	ok(t, err)
+	equals(t, 2, c.Len())
+
+	again, err := c.Parse("$a")
+	ok(t, err)
+	if a != again {
+		t.Fatal("expected the recently used template to be kept")
+	}
+	c.mu.Lock()
+	_, kept := c.entries["$b"]
+	c.mu.Unlock()
+	equals(t, false, kept)
+}
+
+func TestCache_errorsNotCached(t *testing.T) {
+	c := NewCache(2)
+	_, err := c.Expand("${a", Map{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	equals(t, 0, c.Len())
+}
+
+func TestCache_options(t *testing.T) {
+	c := (&Expander{QuoteRemoval: true}).NewCache(1)
+	out, err := c.Expand(`'$a'`, Map{"a": "x"})
+	ok(t, err)
+	equals(t, "$a", out)
+}
@@ -0,0 +1,150 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachedGetter wraps a mapping whose lookups are slow, such as one backed
+// by network calls, caching the results of lookups for a TTL. Concurrent
+// lookups of the same key that is not cached share a single call to the
+// mapping. Lookup errors from an ErrGetter or ContextGetter are returned
+// to every waiting caller but are not cached.
+//
+// Assignments are passed through to the mapping when it supports them,
+// and update the cached value.
+type CachedGetter struct {
+	mapping Getter
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]cachedValue
+	inflight map[string]*cachedCall
+}
+
+type cachedValue struct {
+	value   string
+	set     bool
+	expires time.Time
+}
+
+// cachedCall is a lookup in progress that other callers wait for.
+type cachedCall struct {
+	done  chan struct{} // closed when the lookup is done
+	value string
+	set   bool
+	err   error
+	stale bool // whether the key was assigned or invalidated meanwhile
+}
+
+// errLookupPanicked is returned to the callers waiting for a lookup that
+// panicked.
+var errLookupPanicked = errors.New("lookup panicked")
+
+// NewCachedGetter returns a CachedGetter caching the lookups of the mapping
+// for the TTL. With a TTL of zero, values are cached until they are
+// invalidated.
+func NewCachedGetter(mapping Getter, ttl time.Duration) *CachedGetter {
+	return &CachedGetter{
+		mapping:  mapping,
+		ttl:      ttl,
+		now:      time.Now,
+		cache:    make(map[string]cachedValue),
+		inflight: make(map[string]*cachedCall),
+	}
+}
+
+func (c *CachedGetter) Get(k string) (string, bool) {
+	v, ok, _ := c.Lookup(k)
+	return v, ok
+}
+
+func (c *CachedGetter) Lookup(k string) (string, bool, error) {
+	return c.lookup(context.Background(), k)
+}
+
+// GetContext looks up the key like Lookup, with GetContext when the
+// mapping implements ContextGetter. A caller waiting for the lookup of
+// another caller stops waiting when its own context is done.
+func (c *CachedGetter) GetContext(ctx context.Context, k string) (string, bool, error) {
+	return c.lookup(ctx, k)
+}
+
+func (c *CachedGetter) lookup(ctx context.Context, k string) (string, bool, error) {
+	c.mu.Lock()
+	if v, ok := c.cache[k]; ok && (c.ttl == 0 || c.now().Before(v.expires)) {
+		c.mu.Unlock()
+		return v.value, v.set, nil
+	}
+	if call, ok := c.inflight[k]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.set, call.err
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		}
+	}
+	call := &cachedCall{done: make(chan struct{})}
+	c.inflight[k] = call
+	c.mu.Unlock()
+
+	// clean up even if the mapping panics, so later lookups don't wait
+	// for it forever
+	completed := false
+	defer func() {
+		if !completed {
+			call.err = errLookupPanicked
+		}
+		c.mu.Lock()
+		delete(c.inflight, k)
+		if call.err == nil && !call.stale {
+			c.cache[k] = cachedValue{value: call.value, set: call.set, expires: c.now().Add(c.ttl)}
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.set, call.err = getContext(ctx, c.mapping, k)
+	completed = true
+	return call.value, call.set, call.err
+}
+
+func (c *CachedGetter) Set(k, v string) error {
+	setter, ok := c.mapping.(Setter)
+	if !ok {
+		return assignError{k, c.mapping}
+	}
+	if err := setter.Set(k, v); err != nil {
+		c.Invalidate(k)
+		return err
+	}
+	c.mu.Lock()
+	c.cache[k] = cachedValue{value: v, set: true, expires: c.now().Add(c.ttl)}
+	if call, ok := c.inflight[k]; ok {
+		call.stale = true
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Invalidate removes the cached values of the keys, or of all keys when
+// none are given.
+func (c *CachedGetter) Invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(keys) == 0 {
+		c.cache = make(map[string]cachedValue)
+		for _, call := range c.inflight {
+			call.stale = true
+		}
+	}
+	for _, k := range keys {
+		delete(c.cache, k)
+		if call, ok := c.inflight[k]; ok {
+			call.stale = true
+		}
+	}
+}
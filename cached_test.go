@@ -0,0 +1,231 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingMap counts the lookups of each key, blocking them until release
+// is closed.
+type countingMap struct {
+	Map
+	calls   sync.Map
+	release chan struct{}
+}
+
+func (m *countingMap) Lookup(k string) (string, bool, error) {
+	n, _ := m.calls.LoadOrStore(k, new(int32))
+	atomic.AddInt32(n.(*int32), 1)
+	if m.release != nil {
+		<-m.release
+	}
+	if k == "FAIL" {
+		return "", false, errLookup
+	}
+	v, ok := m.Map[k]
+	return v, ok, nil
+}
+
+func (m *countingMap) count(k string) int {
+	n, ok := m.calls.Load(k)
+	if !ok {
+		return 0
+	}
+	return int(atomic.LoadInt32(n.(*int32)))
+}
+
+func TestCachedGetter(t *testing.T) {
+	m := &countingMap{Map: Map{"A": "a"}}
+	c := NewCachedGetter(m, time.Minute)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	out, err := Expand("$A $A ${B-unset} ${B-unset}", c)
+	ok(t, err)
+	equals(t, "a a unset unset", out)
+	equals(t, 1, m.count("A"))
+	equals(t, 1, m.count("B"))
+
+	now = now.Add(time.Minute)
+	_, err = Expand("$A", c)
+	ok(t, err)
+	equals(t, 2, m.count("A"))
+
+	c.Invalidate("A")
+	_, err = Expand("$A $B", c)
+	ok(t, err)
+	equals(t, 3, m.count("A"))
+	equals(t, 2, m.count("B"))
+
+	for i := 0; i < 2; i++ {
+		_, err = Expand("$FAIL", c)
+		equals(t, true, errors.Is(err, errLookup))
+	}
+	equals(t, 2, m.count("FAIL"))
+
+	_, err = Expand("${Z:=x}", c)
+	equals(t, "mapping type *posix.countingMap does not support assignment", err.Error())
+}
+
+func TestCachedGetter_singleflight(t *testing.T) {
+	m := &countingMap{Map: Map{"A": "a"}, release: make(chan struct{})}
+	c := NewCachedGetter(m, 0)
+
+	var wg sync.WaitGroup
+	results := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := c.Get("A")
+			results <- v
+		}()
+	}
+	for m.count("A") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(m.release)
+	wg.Wait()
+	close(results)
+	for v := range results {
+		equals(t, "a", v)
+	}
+	equals(t, 1, m.count("A"))
+}
+
+func TestCachedGetter_set(t *testing.T) {
+	rw := RWMap{}
+	c := NewCachedGetter(rw, 0)
+	out, err := Expand("${A-unset} ${A:=x} $A", c)
+	ok(t, err)
+	equals(t, "unset x x", out)
+	equals(t, RWMap{"A": "x"}, rw)
+
+	c.Invalidate()
+	rw["A"] = "changed"
+	v, _ := c.Get("A")
+	equals(t, "changed", v)
+}
+
+func TestCachedGetter_context(t *testing.T) {
+	tenant := context.WithValue(context.Background(), tenantKey{}, "acme")
+	c := NewCachedGetter(secretStore{}, 0)
+	out, err := ExpandContext(tenant, "$TENANT", c)
+	ok(t, err)
+	equals(t, "acme", out)
+
+	// the lookup is cached for other contexts
+	out, err = ExpandContext(context.Background(), "$TENANT", c)
+	ok(t, err)
+	equals(t, "acme", out)
+
+	// a caller waiting for another caller's lookup stops at its own
+	// deadline, and the error is not cached
+	m := &countingMap{Map: Map{"A": "a"}, release: make(chan struct{})}
+	c = NewCachedGetter(m, 0)
+	go c.Get("A")
+	for m.count("A") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = ExpandContext(ctx, "$A", c)
+	equals(t, true, errors.Is(err, context.DeadlineExceeded))
+	close(m.release)
+	out, err = ExpandContext(context.Background(), "$A", c)
+	ok(t, err)
+	equals(t, "a", out)
+	equals(t, 1, m.count("A"))
+}
+
+// panicMap panics on the first lookup of each key.
+type panicMap struct {
+	Map
+	panicked sync.Map
+}
+
+func (m *panicMap) Lookup(k string) (string, bool, error) {
+	if _, loaded := m.panicked.LoadOrStore(k, true); !loaded {
+		panic("lookup failed")
+	}
+	v, ok := m.Map[k]
+	return v, ok, nil
+}
+
+func TestCachedGetter_panic(t *testing.T) {
+	c := NewCachedGetter(&panicMap{Map: Map{"A": "a"}}, 0)
+	func() {
+		defer func() {
+			equals(t, "lookup failed", recover())
+		}()
+		c.Get("A")
+	}()
+
+	// the key is not left in flight, so the next lookup doesn't block
+	done := make(chan string)
+	go func() {
+		v, _ := c.Get("A")
+		done <- v
+	}()
+	select {
+	case v := <-done:
+		equals(t, "a", v)
+	case <-time.After(time.Second):
+		t.Fatal("the lookup after a panic blocked")
+	}
+}
+
+// acceptingMap accepts assignments without changing the values of its
+// lookups, like a store that is updated asynchronously.
+type acceptingMap struct {
+	*countingMap
+}
+
+func (acceptingMap) Set(k, v string) error {
+	return nil
+}
+
+func TestCachedGetter_setDuringLookup(t *testing.T) {
+	m := &countingMap{Map: Map{"A": "old"}, release: make(chan struct{})}
+	c := NewCachedGetter(acceptingMap{m}, 0)
+
+	looked := make(chan string)
+	go func() {
+		v, _ := c.Get("A")
+		looked <- v
+	}()
+	for m.count("A") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ok(t, c.Set("A", "new"))
+	close(m.release)
+	equals(t, "old", <-looked)
+
+	// the lookup that started before the assignment doesn't replace it
+	v, _ := c.Get("A")
+	equals(t, "new", v)
+	equals(t, 1, m.count("A"))
+
+	// nor does one that started before the cache was invalidated
+	m.release = make(chan struct{})
+	c.Invalidate()
+	go func() {
+		v, _ := c.Get("A")
+		looked <- v
+	}()
+	for m.count("A") == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Invalidate("A")
+	close(m.release)
+	equals(t, "old", <-looked)
+	m.Map["A"] = "newer"
+	v, _ = c.Get("A")
+	equals(t, "newer", v)
+	equals(t, 3, m.count("A"))
+}
@@ -0,0 +1,110 @@
+package posix
+
+import "context"
+
+// Chain returns a Getter that looks up each key in the getters in order,
+// returning the value from the first one where it is set, such as
+// Chain(flags, env, file) for flags that override the environment, which
+// overrides a config file.
+//
+// If any of the getters implements Setter, so does the chain, and
+// assignments are made to the first getter that implements it. Put a
+// writable layer first, such as Chain(RWMap{}, env), so assigned values
+// override the others. A lookup error from a getter that implements
+// ErrGetter or ContextGetter stops the lookup. Special and positional
+// parameters are provided by the first getter that implements
+// SpecialGetter or Positional, and are otherwise looked up with Get.
+func Chain(getters ...Getter) Getter {
+	c := chain(getters)
+	for _, g := range getters {
+		if s, ok := g.(Setter); ok {
+			return chainSetter{c, s}
+		}
+	}
+	return c
+}
+
+// WithDefaults returns a Getter that looks up keys in the base mapping,
+// and uses the value from defaults for keys that are not set in it, so
+// templates do not each need a ${name:-default}. Assignments are made to
+// the base mapping.
+func WithDefaults(base Getter, defaults map[string]string) Getter {
+	return Chain(base, Map(defaults))
+}
+
+type chain []Getter
+
+func (c chain) Get(k string) (string, bool) {
+	for _, g := range c {
+		if v, ok := g.Get(k); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (c chain) Lookup(k string) (string, bool, error) {
+	for _, g := range c {
+		if eg, ok := g.(ErrGetter); ok {
+			if v, ok, err := eg.Lookup(k); ok || err != nil {
+				return v, ok, err
+			}
+		} else if v, ok := g.Get(k); ok {
+			return v, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (c chain) GetContext(ctx context.Context, k string) (string, bool, error) {
+	for _, g := range c {
+		if v, ok, err := getContext(ctx, g, k); ok || err != nil {
+			return v, ok, err
+		}
+	}
+	return "", false, nil
+}
+
+func (c chain) IsSecret(k string) bool {
+	for _, g := range c {
+		if isSecret(g, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c chain) GetSpecial(k string) (string, bool) {
+	for _, g := range c {
+		if sg, ok := g.(SpecialGetter); ok {
+			if v, ok := sg.GetSpecial(k); ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (c chain) Positional() []string {
+	args, _ := c.positional()
+	return args
+}
+
+func (c chain) positional() ([]string, bool) {
+	for _, g := range c {
+		if args, ok := positional(g); ok {
+			return args, true
+		}
+	}
+	return nil, false
+}
+
+// chainSetter is a chain with a layer that assignments are made to.
+type chainSetter struct {
+	chain
+	setter Setter
+}
+
+func (c chainSetter) Set(k, v string) error {
+	return c.setter.Set(k, v)
+}
@@ -0,0 +1,89 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	flags := Map{"A": "flag"}
+	env := Map{"A": "env", "B": "env", "EMPTY": ""}
+	file := Map{"A": "file", "B": "file", "C": "file", "EMPTY": "file"}
+	mapping := Chain(flags, env, file)
+
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"$A $B $C", "flag env file"},
+		{"${EMPTY-unset}", ""},
+		{"${D-unset}", "unset"},
+	}
+	for _, c := range cases {
+		out, err := Expand(c.in, mapping)
+		ok(t, err)
+		equals(t, c.out, out)
+	}
+
+	if _, ok := mapping.(Setter); ok {
+		t.Fatal("expected a chain of read-only getters not to be a Setter")
+	}
+	_, err := Expand("${D=x}", mapping)
+	equals(t, "mapping type posix.chain does not support assignment", err.Error())
+}
+
+func TestChain_set(t *testing.T) {
+	local := RWMap{}
+	env := RWMap{"A": "env"}
+	mapping := Chain(Map{"F": "flag"}, local, env)
+
+	out, err := Expand("${A:=x} ${B:=y} $B", mapping)
+	ok(t, err)
+	equals(t, "env y y", out)
+	equals(t, RWMap{"B": "y"}, local)
+	equals(t, RWMap{"A": "env"}, env)
+}
+
+func TestChain_special(t *testing.T) {
+	mapping := Chain(Map{}, Params{Getter: Map{"?": "0"}, Args: []string{"a", "b"}})
+	out, err := Expand("$# $1 $?", mapping)
+	ok(t, err)
+	equals(t, "2 a 0", out)
+
+	// without a Positional layer, the parameters are looked up with Get
+	mapping = Chain(Map{}, Map{"1": "one", "#": "1", "@": "all"})
+	out, err = Expand("${1}X$1 $# $@", mapping)
+	ok(t, err)
+	equals(t, "oneXone 1 all", out)
+
+	out, err = Expand("$1 $#", WithDefaults(Map{}, map[string]string{"1": "one"}))
+	ok(t, err)
+	equals(t, "one 0", out)
+}
+
+func TestWithDefaults(t *testing.T) {
+	base := RWMap{"A": "a", "EMPTY": ""}
+	mapping := WithDefaults(base, map[string]string{"A": "default", "B": "b", "EMPTY": "e"})
+
+	out, err := Expand("$A $B ${EMPTY-unset} ${C:=c} $C", mapping)
+	ok(t, err)
+	equals(t, "a b  c c", out)
+	equals(t, RWMap{"A": "a", "EMPTY": "", "C": "c"}, base)
+
+	_, err = Expand("${C:=c}", WithDefaults(Map{}, nil))
+	equals(t, "mapping type posix.chain does not support assignment", err.Error())
+}
+
+func TestChain_context(t *testing.T) {
+	tenant := context.WithValue(context.Background(), tenantKey{}, "acme")
+	out, err := ExpandContext(tenant, "$TENANT $A", Chain(Map{"A": "a"}, secretStore{}))
+	ok(t, err)
+	equals(t, "acme a", out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = ExpandContext(ctx, "$SLOW", Chain(Map{}, secretStore{}, Map{"SLOW": "fast"}))
+	equals(t, true, errors.Is(err, context.DeadlineExceeded))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// checker compares the expansions of files with their existing targets
+// instead of writing them, writing a unified diff for each target that
+// differs.
+type checker struct {
+	w      io.Writer
+	differ bool // whether any target differs
+}
+
+// files checks that the files are unchanged by expanding them in place.
+func (c *checker) files(x expandFunc, files []string) error {
+	if len(files) == 0 {
+		return errors.New("in-place editing requires files")
+	}
+	for _, name := range files {
+		if err := c.file(x, name, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// file checks that the expansion of src matches dest. A missing dest is
+// compared as empty.
+func (c *checker) file(x expandFunc, src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	var out bytes.Buffer
+	if err := x(&out, in); err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+
+	old, err := os.ReadFile(dest)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if bytes.Equal(old, out.Bytes()) {
+		return nil
+	}
+	c.differ = true
+	return unifiedDiff(c.w, dest, dest+" (expanded)", old, out.Bytes())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_checkRecursive(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"app.conf.tmpl":    "name=$NAME\n",
+		"etc/db.conf.tmpl": "host=${HOST:-localhost}\n",
+	})
+	out := t.TempDir()
+	args := []string{"-check", "-recursive", src, "-out", out, "-strip-suffix", ".tmpl"}
+	environ := []string{"NAME=world"}
+
+	// a missing output is out of date, and isn't created
+	var stdout, stderr strings.Builder
+	status := run(args, nil, &stdout, &stderr, environ)
+	app := filepath.Join(out, "app.conf")
+	db := filepath.Join(out, "etc", "db.conf")
+	want := "--- " + app + "\n+++ " + app + " (expanded)\n@@ -0,0 +1 @@\n+name=world\n" +
+		"--- " + db + "\n+++ " + db + " (expanded)\n@@ -0,0 +1 @@\n+host=localhost\n"
+	if status != 1 || stdout.String() != want || stderr.String() != "" {
+		t.Errorf("missing: run(%q) = %d, %q, %q; want 1, %q", args, status, stdout.String(), stderr.String(), want)
+	}
+	if files := readFiles(t, out); len(files) != 0 {
+		t.Errorf("check wrote files: %q", files)
+	}
+
+	writeFiles(t, out, map[string]string{
+		"app.conf":    "name=old\n",
+		"etc/db.conf": "host=localhost\n",
+	})
+	stdout.Reset()
+	stderr.Reset()
+	status = run(args, nil, &stdout, &stderr, environ)
+	want = "--- " + app + "\n+++ " + app + " (expanded)\n@@ -1 +1 @@\n-name=old\n+name=world\n"
+	if status != 1 || stdout.String() != want || stderr.String() != "" {
+		t.Errorf("outdated: run(%q) = %d, %q, %q; want 1, %q", args, status, stdout.String(), stderr.String(), want)
+	}
+
+	writeFiles(t, out, map[string]string{"app.conf": "name=world\n"})
+	stdout.Reset()
+	stderr.Reset()
+	status = run(args, nil, &stdout, &stderr, environ)
+	if status != 0 || stdout.String() != "" || stderr.String() != "" {
+		t.Errorf("current: run(%q) = %d, %q, %q; want 0", args, status, stdout.String(), stderr.String())
+	}
+}
+
+func TestRun_checkInPlace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(name, []byte("$NAME\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"-check", "-i", name}, nil, &stdout, &stderr, []string{"NAME=world"})
+	want := "--- " + name + "\n+++ " + name + " (expanded)\n@@ -1 +1 @@\n-$NAME\n+world\n"
+	if status != 1 || stdout.String() != want {
+		t.Errorf("got %d, %q, %q; want 1, %q", status, stdout.String(), stderr.String(), want)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "$NAME\n" {
+		t.Errorf("check modified the file: %q", data)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	status = run([]string{"-check"}, nil, &stdout, &stderr, nil)
+	if want := "posix-expand: -check requires -recursive or -i\n"; status != 2 || stderr.String() != want {
+		t.Errorf("without -i: got %d, %q; want 2, %q", status, stderr.String(), want)
+	}
+}
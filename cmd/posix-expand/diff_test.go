@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var difftests = []struct {
+	a, b string
+	diff string
+}{
+	{"a\n", "a\n", "--- a\n+++ b\n"},
+	{"", "x\ny\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+	{"x\n", "", "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n"},
+	{"a\nb\nc\n", "a\nB\nc\n", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+	{"a\nb", "a\nb\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+	{
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+		"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -8,4 +9,3 @@\n 8\n 9\n 10\n-11\n",
+	},
+	{
+		"1\n2\n3\n4\n5\n6\n7\n",
+		"0\n1\n2\n3\n4\n5\n6\n",
+		"--- a\n+++ b\n@@ -1,7 +1,7 @@\n+0\n 1\n 2\n 3\n 4\n 5\n 6\n-7\n",
+	},
+	{"a\nx\nb\ny\n", "x\na\ny\nb\n", "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-a\n x\n-b\n+a\n y\n+b\n"},
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tt := range difftests {
+		var out strings.Builder
+		if err := unifiedDiff(&out, "a", "b", []byte(tt.a), []byte(tt.b)); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.diff {
+			t.Errorf("unifiedDiff(%q, %q) = %q, want %q", tt.a, tt.b, out.String(), tt.diff)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/mgood/go-posix/cmd/posix-expand
+
+go 1.21.3
+
+require (
+	github.com/mgood/go-posix v0.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/mgood/go-posix => ../..
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// inPlaceFlag enables in-place editing, with an optional suffix for
+// backups, as either -i or -i=.bak.
+type inPlaceFlag struct {
+	enabled bool
+	suffix  string
+}
+
+func (f *inPlaceFlag) String() string {
+	return f.suffix
+}
+
+func (f *inPlaceFlag) Set(s string) error {
+	if enabled, err := strconv.ParseBool(s); err == nil {
+		f.enabled, f.suffix = enabled, ""
+		return nil
+	}
+	f.enabled, f.suffix = true, s
+	return nil
+}
+
+// IsBoolFlag allows the flag to be given without a suffix.
+func (f *inPlaceFlag) IsBoolFlag() bool {
+	return true
+}
+
+// link is replaced in tests to simulate filesystems without hard links.
+var link = os.Link
+
+// expandInPlace replaces each file with its expansion. The expansion is
+// written to a temporary file that replaces the original once it is
+// complete, so the file is either unchanged or fully expanded. With a
+// suffix, the original is kept with the suffix added to its name.
+func expandInPlace(x expandFunc, files []string, suffix string) error {
+	if len(files) == 0 {
+		return errors.New("in-place editing requires files")
+	}
+	for _, name := range files {
+		if err := expandFileInPlace(x, name, suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expandFileInPlace(x expandFunc, name, suffix string) error {
+	backup := ""
+	if suffix != "" {
+		backup = name + suffix
+	}
+	return replaceExpanded(x, name, name, backup)
+}
+
+// replaceExpanded expands the file at src into a temporary file next to
+// dest, with the permissions of src, which then replaces dest, so dest is
+// either unchanged or fully expanded. With a backup name, the original dest
+// is kept under that name.
+func replaceExpanded(x expandFunc, src, dest, backup string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+	if err := x(out, in); err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	// the temporary file is created without permissions for others
+	if err := out.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if backup != "" {
+		if err := os.Remove(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		// not every filesystem supports hard links
+		if err := link(dest, backup); err != nil {
+			if err := copyFile(dest, backup); err != nil {
+				return err
+			}
+		}
+	}
+	return os.Rename(out.Name(), dest)
+}
+
+// copyFile copies the file at src to a new file dest, with the same
+// permissions.
+func copyFile(src, dest string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dest)
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_inPlace(t *testing.T) {
+	tests := []struct {
+		args   []string
+		backup string
+	}{
+		{[]string{"-i"}, ""},
+		{[]string{"--in-place"}, ""},
+		{[]string{"-i=.bak"}, ".bak"},
+		{[]string{"--in-place=.orig"}, ".orig"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "config")
+		if err := os.WriteFile(name, []byte("name=$NAME\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		var stdout, stderr strings.Builder
+		status := run(append(tt.args, name), nil, &stdout, &stderr, []string{"NAME=world"})
+		if status != 0 || stdout.Len() > 0 {
+			t.Fatalf("run(%q) = %d, %q, %q", tt.args, status, stdout.String(), stderr.String())
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "name=world\n" {
+			t.Errorf("%q: file contains %q", tt.args, data)
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("%q: file mode changed to %v", tt.args, info.Mode())
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.backup == "" {
+			if len(entries) != 1 {
+				t.Errorf("%q: unexpected files in %v", tt.args, entries)
+			}
+			continue
+		}
+		data, err = os.ReadFile(name + tt.backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "name=$NAME\n" {
+			t.Errorf("%q: backup contains %q", tt.args, data)
+		}
+	}
+}
+
+func TestRun_inPlaceError(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config")
+	in := "a=$NAME\nb=${MISSING:?is required}\n"
+	if err := os.WriteFile(name, []byte(in), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"-i=.bak", name}, nil, &stdout, &stderr, []string{"NAME=world"})
+	if status != 1 || stderr.String() != "posix-expand: "+name+": is required\n" {
+		t.Errorf("exit status %d: %q", status, stderr.String())
+	}
+
+	// the file is unchanged, and no backup or temporary file is left
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != in {
+		t.Errorf("file contains %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("unexpected files in %v", entries)
+	}
+
+	stderr.Reset()
+	if status := run([]string{"-i"}, strings.NewReader(in), &stdout, &stderr, nil); status != 1 {
+		t.Errorf("without files: exit status %d: %q", status, stderr.String())
+	}
+}
+
+func TestRun_inPlaceCopyBackup(t *testing.T) {
+	// without hard links, the backup is a copy of the original
+	defer func(old func(string, string) error) { link = old }(link)
+	link = func(string, string) error { return errors.ErrUnsupported }
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config")
+	if err := os.WriteFile(name, []byte("name=$NAME\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr strings.Builder
+	if status := run([]string{"-i=.bak", name}, nil, &stdout, &stderr, []string{"NAME=world"}); status != 0 {
+		t.Fatalf("exit status %d: %q", status, stderr.String())
+	}
+	files := readFiles(t, dir)
+	if len(files) != 2 || files["config"] != "name=world\n" || files["config.bak"] != "name=$NAME\n" {
+		t.Errorf("got files %v", files)
+	}
+	if info, err := os.Stat(name + ".bak"); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("backup: %v, %v", info, err)
+	}
+}
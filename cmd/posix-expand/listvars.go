@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/mgood/go-posix"
+)
+
+// listVars writes a line for each reference to a variable in the files, or
+// stdin if there are none, with the name of the variable, whether it is
+// set in the mapping, and the location of the reference. With allow, only
+// the listed variables are included.
+func listVars(e *posix.Expander, files []string, allow []string, stdin io.Reader, stdout io.Writer, mapping posix.Getter) error {
+	if len(files) == 0 {
+		return listReader(e, "<stdin>", stdin, allow, stdout, mapping)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = listReader(e, name, f, allow, stdout, mapping)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listReader(e *posix.Expander, name string, r io.Reader, allow []string, stdout io.Writer, mapping posix.Getter) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	t, err := e.Parse(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, ref := range t.Refs() {
+		if allow != nil && !slices.Contains(allow, ref.Name) {
+			continue
+		}
+		status := "unset"
+		if _, ok := mapping.Get(ref.Name); ok {
+			status = "set"
+		}
+		if _, err := fmt.Fprintf(stdout, "%s\t%s\t%s:%d:%d\n", ref.Name, status, name, ref.Line, ref.Column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_listVars(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(name, []byte("name=$NAME\n  ${PORT:-80} ${#NAME}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args  []string
+		stdin string
+		out   string
+	}{
+		{[]string{"-list-vars", name}, "", "NAME\tset\t" + name + ":1:6\nPORT\tunset\t" + name + ":2:3\nNAME\tset\t" + name + ":2:15\n"},
+		{[]string{"-list-vars", "$PORT", name}, "", "PORT\tunset\t" + name + ":2:3\n"},
+		{[]string{"-list-vars"}, "$NAME $EMPTY", "NAME\tset\t<stdin>:1:1\nEMPTY\tset\t<stdin>:1:7\n"},
+		{[]string{"-list-vars"}, "no variables", ""},
+	}
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr, []string{"NAME=world", "EMPTY="})
+		if status != 0 || stdout.String() != tt.out {
+			t.Errorf("run(%q) = %d, %q, %q; want %q", tt.args, status, stdout.String(), stderr.String(), tt.out)
+		}
+	}
+
+	// the file is not changed
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name=$NAME\n  ${PORT:-80} ${#NAME}\n" {
+		t.Errorf("file was modified: %q", data)
+	}
+}
+
+func TestRun_listVarsError(t *testing.T) {
+	var stdout, stderr strings.Builder
+	status := run([]string{"-list-vars"}, strings.NewReader("${a"), &stdout, &stderr, nil)
+	if status != 1 || stderr.String() != "posix-expand: <stdin>: unexpected EOF while looking for matching `}'\n" {
+		t.Errorf("exit status %d: %q", status, stderr.String())
+	}
+
+	stderr.Reset()
+	if status := run([]string{"-list-vars", "-i", "file"}, nil, &stdout, &stderr, nil); status != 2 {
+		t.Errorf("with -i: exit status %d: %q", status, stderr.String())
+	}
+}
@@ -0,0 +1,313 @@
+// Command posix-expand expands shell parameters in text, like GNU envsubst
+// but with the full set of POSIX parameter expansions, such as
+// ${VAR:-default}, ${VAR:?message}, ${VAR:=value} and ${VAR+alternate}.
+//
+// Usage:
+//
+//	posix-expand [flags] [shell-format] [file ...]
+//
+// The files, or standard input if there are none, are expanded against the
+// environment and written to standard output. Variables assigned with
+// ${VAR=value} are visible to the rest of the input. Like envsubst, only
+// variables are expanded, and references to positional and special
+// parameters, such as $1 and $$, are passed through unchanged.
+//
+// Like envsubst, if the first argument contains a "$", it is a shell
+// format listing the variables to expand, such as '$FOO ${BAR}'.
+// References to other variables are passed through unchanged.
+//
+// With -env-file, variables are also loaded from .env files, with later
+// files overriding earlier ones. The environment overrides the files,
+// unless -env-override is set, so the files can render templates the same
+// way on any machine. The values in each file are expanded as they are
+// loaded, referring to the variables assigned before them in the file,
+// then to the environment and the earlier files, in the same order as the
+// input.
+//
+// With -i or -in-place, each file is replaced with its expansion instead,
+// keeping the original with a suffix given as -i=.bak. A file is only
+// replaced once it has been fully expanded.
+//
+// With -recursive, each file in a directory tree is expanded to the same
+// path in the -out directory, optionally removing a -strip-suffix such as
+// ".tmpl" from its name. With -include, only files with names matching one
+// of the patterns are expanded, and others are skipped. Directories and
+// files keep their permissions.
+//
+// With -check, the outputs of -recursive or -i are compared with the
+// existing files instead of being written, and a unified diff is printed
+// for each file that is out of date, failing the command with status 1 if
+// any are. This lets CI verify that generated files are up to date.
+//
+// With -list-vars, the input is only parsed, and each reference to a
+// variable is listed with whether the variable is set and its location:
+//
+//	HOME	set	app.conf:3:7
+//
+// With -format json or -format yaml, the input is parsed as a document
+// and only its string values are expanded, so an expansion can't change
+// the structure of the document or expand the keys. The JSON is otherwise
+// copied unchanged, while YAML is re-encoded, keeping comments but not the
+// original formatting. Expanded values remain strings even if they look
+// like numbers or booleans. Each file is read fully before expanding it,
+// and nothing is written for a file that fails to expand.
+//
+// With -no-unset or -no-empty, a variable that is unset or empty fails the
+// command with status 1, unless the expansion provides a default, so
+// pipelines stop when the environment is incomplete. The output is
+// streamed, so the text before the failed expansion has been written.
+//
+// With -conformance, it instead reports which features of the bundled
+// conformance corpus for the dialect are supported with the other flags,
+// and exits with status 1 if any case fails.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mgood/go-posix"
+	"github.com/mgood/go-posix/corpus"
+	"github.com/mgood/go-posix/dotenv"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Environ()))
+}
+
+// run runs the command with the arguments and environment, returning the
+// exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, environ []string) int {
+	fs := flag.NewFlagSet("posix-expand", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dialect := fs.String("dialect", corpus.POSIX, "the `dialect` of the expansions, posix or bash")
+	conformance := fs.Bool("conformance", false, "report conformance with the corpus for the dialect")
+	noUnset := fs.Bool("no-unset", false, "fail when expanding a variable that is not set")
+	noEmpty := fs.Bool("no-empty", false, "fail when expanding a variable that is set but empty")
+	var envFiles listFlag
+	fs.Var(&envFiles, "env-file", "load variables from the .env `file`, which may be repeated")
+	envOverride := fs.Bool("env-override", false, "let the env files override the environment")
+	var inPlace inPlaceFlag
+	fs.Var(&inPlace, "i", "edit the files in place, keeping backups with a suffix given as -i=.bak")
+	fs.Var(&inPlace, "in-place", "same as -i")
+	var t tree
+	fs.StringVar(&t.dir, "recursive", "", "expand the files in the `dir` tree into the -out directory")
+	fs.Var((*listFlag)(&t.include), "include", "with -recursive, only expand files with names matching the `pattern`, which may be repeated")
+	fs.StringVar(&t.strip, "strip-suffix", "", "with -recursive, remove the `suffix` from the names of expanded files")
+	fs.StringVar(&t.out, "out", "", "with -recursive, the `dir` to write the expanded files to")
+	format := fs.String("format", "text", "parse the input as `format` text, json or yaml, and only expand its string values")
+	check := fs.Bool("check", false, "with -recursive or -i, print a diff of the outputs that are out of date instead of writing them, and fail if any are")
+	list := fs.Bool("list-vars", false, "list the variables referenced by the files, whether they are set, and where, without expanding")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: posix-expand [flags] [shell-format] [file ...]\n\n")
+		fmt.Fprintf(stderr, "Expands shell parameters in the files, or standard input, and writes\n")
+		fmt.Fprintf(stderr, "the result to standard output. With a shell-format such as '$FOO $BAR',\n")
+		fmt.Fprintf(stderr, "only the variables it references are expanded.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	var opts []posix.Option
+	switch *dialect {
+	case corpus.POSIX:
+	case corpus.Bash:
+		opts = append(opts, posix.WithDialect(posix.Bash))
+	default:
+		fmt.Fprintf(stderr, "posix-expand: unknown dialect %q\n", *dialect)
+		return 2
+	}
+	switch *format {
+	case "text", "json", "yaml":
+	default:
+		fmt.Fprintf(stderr, "posix-expand: unknown format %q\n", *format)
+		return 2
+	}
+	if *noUnset {
+		opts = append(opts, posix.WithNoUnset())
+	}
+	if *noEmpty {
+		opts = append(opts, posix.WithNoEmpty())
+	}
+
+	files := fs.Args()
+	var allow []string
+	if len(files) > 0 && strings.Contains(files[0], "$") {
+		names, err := posix.Vars(files[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "posix-expand: shell-format: %s\n", err)
+			return 2
+		}
+		allow = append([]string{}, names...)
+		opts = append(opts, posix.WithAllow(names...))
+		files = files[1:]
+	}
+
+	if *conformance {
+		return report(posix.NewExpander(opts...), *dialect, stdout, stderr)
+	}
+	e := posix.NewExpander(append(opts, posix.WithVarsOnly())...)
+
+	if *list && (t.dir != "" || inPlace.enabled || *format != "text") {
+		fmt.Fprintf(stderr, "posix-expand: -list-vars can't be used with -recursive, -i or -format\n")
+		return 2
+	}
+	if *check && t.dir == "" && !inPlace.enabled {
+		fmt.Fprintf(stderr, "posix-expand: -check requires -recursive or -i\n")
+		return 2
+	}
+	if t.dir != "" {
+		err := t.validate()
+		if err == nil && (len(files) > 0 || inPlace.enabled) {
+			err = errors.New("-recursive can't be used with files or -i")
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "posix-expand: %s\n", err)
+			return 2
+		}
+	}
+
+	mapping, err := mapping(environ, envFiles, *envOverride)
+	if err != nil {
+		fmt.Fprintf(stderr, "posix-expand: %s\n", err)
+		return 1
+	}
+	x := structured(*format, e, mapping)
+	c := &checker{w: stdout}
+	switch {
+	case *list:
+		err = listVars(e, files, allow, stdin, stdout, mapping)
+	case t.dir != "":
+		if *check {
+			t.check = c
+		}
+		err = expandTree(x, t)
+	case inPlace.enabled && *check:
+		err = c.files(x, files)
+	case inPlace.enabled:
+		err = expandInPlace(x, files, inPlace.suffix)
+	default:
+		err = expand(x, files, stdin, stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "posix-expand: %s\n", err)
+		return 1
+	}
+	if c.differ {
+		return 1
+	}
+	return 0
+}
+
+// expandFunc expands the input from r and writes it to w.
+type expandFunc func(w io.Writer, r io.Reader) error
+
+// stream returns an expandFunc that streams the input through the Expander.
+func stream(e *posix.Expander, mapping posix.Getter) expandFunc {
+	return func(w io.Writer, r io.Reader) error {
+		_, err := io.Copy(w, e.NewReader(r, mapping))
+		return err
+	}
+}
+
+// expand expands each file, or stdin if there are none, to stdout.
+func expand(x expandFunc, files []string, stdin io.Reader, stdout io.Writer) error {
+	if len(files) == 0 {
+		return x(stdout, stdin)
+	}
+	for _, name := range files {
+		if err := expandFile(x, name, stdout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expandFile(x expandFunc, name string, stdout io.Writer) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := x(stdout, f); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// report writes the conformance report of the Expander for the dialect.
+func report(e *posix.Expander, dialect string, stdout, stderr io.Writer) int {
+	r, err := e.Conformance(dialect)
+	if err != nil {
+		fmt.Fprintf(stderr, "posix-expand: %s\n", err)
+		return 1
+	}
+	fmt.Fprint(stdout, r)
+	if !r.Passed() {
+		return 1
+	}
+	return 0
+}
+
+// mapping returns the variables of the environment and the env files,
+// with the files overriding the environment if override is set. The
+// mapping is mutable, so assignments are visible to the rest of the input
+// without changing the process environment or the files.
+func mapping(environ, envFiles []string, override bool) (posix.Getter, error) {
+	env := make(posix.Map, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	// the chain returns the first value set, so later files come first. Each
+	// file is expanded against the layers loaded before it, in the same
+	// order as the final chain.
+	var files []posix.Getter
+	for _, name := range envFiles {
+		f, err := dotenv.Load(name)
+		if err != nil {
+			return nil, err
+		}
+		var rest posix.Getter
+		if override {
+			rest = posix.Chain(append(files[:len(files):len(files)], env)...)
+		} else {
+			rest = posix.Chain(append([]posix.Getter{env}, files...)...)
+		}
+		vars, err := f.Expand(rest)
+		if err != nil {
+			return nil, err
+		}
+		files = append([]posix.Getter{vars}, files...)
+	}
+
+	layers := []posix.Getter{posix.RWMap{}}
+	if override {
+		layers = append(append(layers, files...), env)
+	} else {
+		layers = append(append(layers, env), files...)
+	}
+	return posix.Chain(layers...), nil
+}
+
+// listFlag is a flag that may be repeated, collecting each value.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var runtests = []struct {
+	args   []string
+	stdin  string
+	stdout string
+	stderr string
+	status int
+}{
+	{nil, "hello $NAME", "hello world", "", 0},
+	{nil, "${UNSET:-default} ${NAME:+set} ${#NAME}", "default set 5", "", 0},
+	{nil, "${NEW:=assigned} $NEW", "assigned assigned", "", 0},
+	{nil, "${UNSET:?is required}", "", "posix-expand: is required\n", 1},
+	{nil, "a{b,c}", "a{b,c}", "", 0},
+	{[]string{"-dialect", "bash"}, "a{b,c}", "ab ac", "", 0},
+	{[]string{"-dialect", "zsh"}, "", "", "posix-expand: unknown dialect \"zsh\"\n", 2},
+	{[]string{"--no-unset"}, "$NAME ${UNSET:-ok}", "world ok", "", 0},
+	{[]string{"--no-unset"}, "$NAME $UNSET", "world ", "posix-expand: UNSET: unbound variable\n", 1},
+	{[]string{"--no-empty"}, "$UNSET", "", "", 0},
+	{[]string{"--no-empty"}, "${EMPTY:-ok} $EMPTY", "ok ", "posix-expand: EMPTY: parameter is empty\n", 1},
+	{[]string{"--no-unset", "--no-empty"}, "$EMPTY", "", "posix-expand: EMPTY: parameter is empty\n", 1},
+	{[]string{"$NAME"}, "$NAME ${OTHER:-x} $HOME/bin", "world ${OTHER:-x} $HOME/bin", "", 0},
+	{[]string{"${NAME} $EMPTY"}, "$NAME:$EMPTY:$PATH", "world::$PATH", "", 0},
+	{[]string{"$"}, "$NAME", "$NAME", "", 0},
+	{[]string{"$NAME"}, "$1 $$ $# $? $@ ${1:-x} $NAME", "$1 $$ $# $? $@ ${1:-x} world", "", 0},
+	{nil, "$1 $$ ${#} ${1:-x} $NAME", "$1 $$ ${#} ${1:-x} world", "", 0},
+	{[]string{"${NAME"}, "", "", "posix-expand: shell-format: unexpected EOF while looking for matching `}'\n", 2},
+	{[]string{"missing.txt"}, "", "", "posix-expand: open missing.txt: no such file or directory\n", 1},
+}
+
+func TestRun(t *testing.T) {
+	for _, tt := range runtests {
+		var stdout, stderr strings.Builder
+		status := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr, []string{"NAME=world", "EMPTY="})
+		if status != tt.status || stdout.String() != tt.stdout || stderr.String() != tt.stderr {
+			t.Errorf("run(%q) with %q = %d, %q, %q; want %d, %q, %q", tt.args, tt.stdin,
+				status, stdout.String(), stderr.String(), tt.status, tt.stdout, tt.stderr)
+		}
+	}
+}
+
+func TestRun_files(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(a, []byte("${X=from a}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("$X and $NAME\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	status := run([]string{a, b}, strings.NewReader("ignored"), &stdout, &stderr, []string{"NAME=world"})
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	// assignments in one file are visible in the next
+	if want := "from a\nfrom a and world\n"; stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_conformance(t *testing.T) {
+	for _, dialect := range []string{"posix", "bash"} {
+		var stdout, stderr strings.Builder
+		status := run([]string{"-dialect", dialect, "-conformance"}, nil, &stdout, &stderr, nil)
+		if status != 0 {
+			t.Errorf("%s: exit status %d: %s%s", dialect, status, stdout.String(), stderr.String())
+		}
+		if !strings.HasPrefix(stdout.String(), "dialect "+dialect+"\n") {
+			t.Errorf("%s: unexpected report: %s", dialect, stdout.String())
+		}
+	}
+}
+
+func TestRun_formatFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(name, []byte("$X and $NAME\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"$X", name}, nil, &stdout, &stderr, []string{"NAME=world", "X=x"})
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	if want := "x and $NAME\n"; stdout.String() != want {
+		t.Errorf("got %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestRun_envFile(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.env")
+	local := filepath.Join(dir, "local.env")
+	if err := os.WriteFile(base, []byte("A=base\nB=base\nC=base\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte("B=local\nC=local\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	environ := []string{"C=environ"}
+	in := "$A $B $C ${D:=assigned} $D"
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"-env-file", base, "-env-file", local}, "base local environ assigned assigned"},
+		{[]string{"-env-file", base, "-env-file", local, "-env-override"}, "base local local assigned assigned"},
+		{[]string{"-env-file", local, "-env-file", base}, "base base environ assigned assigned"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := run(tt.args, strings.NewReader(in), &stdout, &stderr, environ)
+		if status != 0 || stdout.String() != tt.out {
+			t.Errorf("run(%q) = %d, %q, %q; want %q", tt.args, status, stdout.String(), stderr.String(), tt.out)
+		}
+	}
+
+	// the files are not changed by assignments
+	data, err := os.ReadFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "B=local\nC=local\n" {
+		t.Errorf("env file was modified: %q", data)
+	}
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"-env-file", filepath.Join(dir, "missing.env")}, strings.NewReader(in), &stdout, &stderr, environ)
+	if status != 1 || !strings.Contains(stderr.String(), "missing.env") {
+		t.Errorf("missing env file: exit status %d: %q", status, stderr.String())
+	}
+}
+
+func TestRun_envFileExpand(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.env")
+	app := filepath.Join(dir, "app.env")
+	if err := os.WriteFile(base, []byte("HOST=base\nPORT=80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(app, []byte("URL=https://${HOST}:${PORT}/api\nRAW='${HOST}'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	environ := []string{"HOST=environ"}
+	in := "$URL $RAW"
+
+	tests := []struct {
+		args []string
+		out  string
+	}{
+		{[]string{"-env-file", app}, "https://environ:/api ${HOST}"},
+		{[]string{"-env-file", base, "-env-file", app}, "https://environ:80/api ${HOST}"},
+		{[]string{"-env-file", base, "-env-file", app, "-env-override"}, "https://base:80/api ${HOST}"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := run(tt.args, strings.NewReader(in), &stdout, &stderr, environ)
+		if status != 0 || stdout.String() != tt.out {
+			t.Errorf("run(%q) = %d, %q, %q; want %q", tt.args, status, stdout.String(), stderr.String(), tt.out)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.env")
+	if err := os.WriteFile(bad, []byte("A=ok\nB=${MISSING?required}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr strings.Builder
+	status := run([]string{"-env-file", bad}, strings.NewReader(in), &stdout, &stderr, environ)
+	if status != 1 || !strings.Contains(stderr.String(), "bad.env") {
+		t.Errorf("invalid env file: exit status %d: %q", status, stderr.String())
+	}
+}
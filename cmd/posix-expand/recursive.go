@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tree describes the expansion of a directory tree of templates into
+// another directory.
+type tree struct {
+	dir     string   // the directory of templates
+	out     string   // the directory to write the expanded files to
+	include []string // patterns of the file names to expand, or all files if empty
+	strip   string   // a suffix to remove from the names of the expanded files
+	check   *checker // if set, compares with the output instead of writing it
+}
+
+// expandTree expands each file of the tree that matches the include
+// patterns to the same relative path in the output directory, creating
+// directories as needed. Files and directories keep their permissions.
+func expandTree(x expandFunc, t tree) error {
+	out, err := filepath.Abs(t.out)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(t.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// don't expand the output again when it is inside the tree
+			if abs, err := filepath.Abs(path); err == nil && abs == out {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !t.included(d.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(t.dir, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(t.out, strings.TrimSuffix(rel, t.strip))
+		if t.check != nil {
+			return t.check.file(x, path, dest)
+		}
+		if err := t.mkdirs(filepath.Dir(rel)); err != nil {
+			return err
+		}
+		return expandTo(x, path, dest)
+	})
+}
+
+// validate checks the options of the tree for usage errors.
+func (t tree) validate() error {
+	if t.out == "" {
+		return errors.New("-recursive requires -out")
+	}
+	for _, pattern := range t.include {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("-include %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// included reports whether the file name matches any of the include
+// patterns, or there are none.
+func (t tree) included(name string) bool {
+	for _, pattern := range t.include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return len(t.include) == 0
+}
+
+// mkdirs creates the directory at the relative path in the output, and its
+// parents, with the permissions of the corresponding directories of the
+// tree.
+func (t tree) mkdirs(rel string) error {
+	if rel == "." {
+		return os.MkdirAll(t.out, 0o755)
+	}
+	if err := t.mkdirs(filepath.Dir(rel)); err != nil {
+		return err
+	}
+	info, err := os.Stat(filepath.Join(t.dir, rel))
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(t.out, rel)
+	if err := os.Mkdir(dest, info.Mode().Perm()); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil
+		}
+		return err
+	}
+	// the mode of a new directory is limited by the umask
+	return os.Chmod(dest, info.Mode().Perm())
+}
+
+// expandTo expands the file at src into dest, with the same permissions.
+// Like in-place editing, dest is only replaced once the expansion is
+// complete.
+func expandTo(x expandFunc, src, dest string) error {
+	return replaceExpanded(x, src, dest, "")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRun_recursive(t *testing.T) {
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{
+		"app.conf.tmpl":        "name=$NAME\n",
+		"etc/db/db.conf.tmpl":  "host=${HOST:-localhost}\n",
+		"etc/README":           "not a $TEMPLATE\n",
+		"bin/run.sh.tmpl":      "echo $NAME\n",
+		"bin/other.sh.tmpl.gz": "skipped",
+	})
+	if err := os.Chmod(filepath.Join(src, "bin", "run.sh.tmpl"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "etc", "db"), 0o770); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "out")
+
+	var stdout, stderr strings.Builder
+	args := []string{"-recursive", src, "-include", "*.tmpl", "-strip-suffix", ".tmpl", "-out", out}
+	if status := run(args, nil, &stdout, &stderr, []string{"NAME=world"}); status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	got := readFiles(t, out)
+	want := map[string]string{
+		"app.conf":       "name=world\n",
+		"etc/db/db.conf": "host=localhost\n",
+		"bin/run.sh":     "echo world\n",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+	for name, data := range want {
+		if got[name] != data {
+			t.Errorf("%s: got %q, want %q", name, got[name], data)
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	for name, mode := range map[string]os.FileMode{"bin/run.sh": 0o755, "app.conf": 0o644, "etc/db": 0o770} {
+		info, err := os.Stat(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("%s: mode %v, want %v", name, info.Mode().Perm(), mode)
+		}
+	}
+}
+
+func TestRun_recursiveAll(t *testing.T) {
+	// without -include every file is expanded, and an output directory
+	// inside the tree is not expanded again
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"a": "$NAME", "sub/b": "${NAME}!"})
+	out := filepath.Join(src, "out")
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{"-recursive", src, "-out", out}, nil, &stdout, &stderr, []string{"NAME=world"}); status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	got := readFiles(t, out)
+	if len(got) != 2 || got["a"] != "world" || got["sub/b"] != "world!" {
+		t.Errorf("got files %v", got)
+	}
+}
+
+func TestRun_recursiveUsage(t *testing.T) {
+	dir := t.TempDir()
+	tests := [][]string{
+		{"-recursive", dir},
+		{"-recursive", dir, "-out", dir, "file"},
+		{"-recursive", dir, "-out", dir, "-i"},
+		{"-recursive", dir, "-out", dir, "-include", "[x"},
+	}
+	for _, args := range tests {
+		var stdout, stderr strings.Builder
+		if status := run(args, nil, &stdout, &stderr, nil); status != 2 {
+			t.Errorf("run(%q) = %d, %q", args, status, stderr.String())
+		}
+	}
+}
+
+func TestRun_recursiveError(t *testing.T) {
+	// a failed expansion leaves the previous output in place
+	src := t.TempDir()
+	writeFiles(t, src, map[string]string{"a": "${NAME:?is required}"})
+	out := t.TempDir()
+	writeFiles(t, out, map[string]string{"a": "old"})
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{"-recursive", src, "-out", out}, nil, &stdout, &stderr, nil); status != 1 {
+		t.Errorf("exit status %d: %q", status, stderr.String())
+	}
+	if got := readFiles(t, out); len(got) != 1 || got["a"] != "old" {
+		t.Errorf("got files %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mgood/go-posix"
+)
+
+// structured returns an expandFunc that parses the input in the format,
+// json or yaml, and expands only its string values, or streams the input
+// as text for any other format.
+func structured(format string, e *posix.Expander, mapping posix.Getter) expandFunc {
+	switch format {
+	case "json":
+		return expandJSON(e, mapping)
+	case "yaml":
+		return expandYAML(e, mapping)
+	}
+	return stream(e, mapping)
+}
+
+// expandJSON returns an expandFunc for JSON documents. Each string value is
+// replaced by its expansion, and the rest of the document, including the
+// keys and formatting, is copied unchanged.
+func expandJSON(e *posix.Expander, mapping posix.Getter) expandFunc {
+	return func(w io.Writer, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+
+		// the containers enclosing the token, and whether an object
+		// expects a key next
+		type frame struct{ object, key bool }
+		var stack []frame
+		var out bytes.Buffer
+		var copied, prev int64
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			end := dec.InputOffset()
+
+			// whether the token completes a value, rather than opening a
+			// container or being a key
+			value := true
+			switch tok := tok.(type) {
+			case json.Delim:
+				switch tok {
+				case '{':
+					stack = append(stack, frame{object: true, key: true})
+					value = false
+				case '[':
+					stack = append(stack, frame{})
+					value = false
+				default:
+					stack = stack[:len(stack)-1]
+				}
+			case string:
+				if n := len(stack); n > 0 && stack[n-1].key {
+					stack[n-1].key = false
+					value = false
+					break
+				}
+				s, err := e.Expand(tok, mapping)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", bytes.Count(data[:end], []byte("\n"))+1, err)
+				}
+				if s != tok {
+					// only whitespace and separators come before the string
+					start := prev + int64(bytes.IndexByte(data[prev:end], '"'))
+					out.Write(data[copied:start])
+					if err := writeJSONString(&out, s); err != nil {
+						return err
+					}
+					copied = end
+				}
+			}
+			// a value in an object is followed by a key
+			if n := len(stack); value && n > 0 && stack[n-1].object {
+				stack[n-1].key = true
+			}
+			prev = end
+		}
+		out.Write(data[copied:])
+		_, err = w.Write(out.Bytes())
+		return err
+	}
+}
+
+// writeJSONString writes s as a JSON string, without escaping HTML.
+func writeJSONString(w *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	// the encoder ends each value with a newline
+	w.Truncate(w.Len() - 1)
+	return nil
+}
+
+// expandYAML returns an expandFunc for YAML documents. Each string value is
+// replaced by its expansion, and remains a string even if it looks like
+// another type. Keys and values with other types are unchanged, and the
+// documents are re-encoded with comments but not the original formatting.
+func expandYAML(e *posix.Expander, mapping posix.Getter) expandFunc {
+	return func(w io.Writer, r io.Reader) error {
+		var out bytes.Buffer
+		dec := yaml.NewDecoder(r)
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		for {
+			var doc yaml.Node
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := expandNode(e, &doc, mapping); err != nil {
+				return err
+			}
+			if err := enc.Encode(&doc); err != nil {
+				return err
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		_, err := w.Write(out.Bytes())
+		return err
+	}
+}
+
+// expandNode expands the string values in the YAML node and its children.
+func expandNode(e *posix.Expander, n *yaml.Node, mapping posix.Getter) error {
+	switch n.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := expandNode(e, c, mapping); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		// the content alternates keys and values
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := expandNode(e, n.Content[i], mapping); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if n.ShortTag() != "!!str" {
+			return nil
+		}
+		s, err := e.Expand(n.Value, mapping)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = s
+	}
+	return nil
+}
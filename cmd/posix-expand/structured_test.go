@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var structuredtests = []struct {
+	format string
+	in     string
+	out    string
+	err    string
+}{
+	{"json", `{"$NAME": "$NAME"}`, `{"$NAME": "world"}`, ""},
+	{"json", "{\n  \"a\": [\"$NAME\", 1.50, null],\n  \"b\": {\"c\": \"${UNSET:-x}\"}\n}\n", "{\n  \"a\": [\"world\", 1.50, null],\n  \"b\": {\"c\": \"x\"}\n}\n", ""},
+	{"json", `{"a": "$QUOTE", "b": "$NAME"}`, `{"a": "say \"<hi>\"", "b": "world"}`, ""},
+	{"json", `{"a": "${QUOTE+\n}"}`, `{"a": "\n"}`, ""},
+	{"json", `"$NAME" ["$NAME"]`, `"world" ["world"]`, ""},
+	{"json", `{"a": "${NEW:=set}", "b": "$NEW"}`, `{"a": "set", "b": "set"}`, ""},
+	{"json", "{\n\"a\": \"${UNSET:?required}\"}", "", "posix-expand: line 2: required\n"},
+	{"json", `{"a": }`, "", "posix-expand: missing value after object key\n"},
+	{"yaml", "$NAME: $NAME\n", "$NAME: world\n", ""},
+	{"yaml", "# config\nname: $NAME # who\nport: $PORT\nlist:\n  - ${UNSET:-x}\n  - 1\n", "# config\nname: world # who\nport: \"80\"\nlist:\n  - x\n  - 1\n", ""},
+	{"yaml", "a: !custom $NAME\nb: !!str $NAME\n", "a: !custom $NAME\nb: !!str world\n", ""},
+	{"yaml", "a: $NAME\n---\nb: $NAME\n", "a: world\n---\nb: world\n", ""},
+	{"yaml", "a: 1\nb: ${UNSET:?required}\n", "", "posix-expand: line 2: required\n"},
+	{"text", `{"$NAME": "$NAME"}`, `{"world": "world"}`, ""},
+}
+
+func TestRun_format(t *testing.T) {
+	environ := []string{"NAME=world", "PORT=80", `QUOTE=say "<hi>"`}
+	for _, tt := range structuredtests {
+		var stdout, stderr strings.Builder
+		run([]string{"-format", tt.format}, strings.NewReader(tt.in), &stdout, &stderr, environ)
+		if stdout.String() != tt.out || stderr.String() != tt.err {
+			t.Errorf("%s: %q = %q, %q; want %q, %q", tt.format, tt.in, stdout.String(), stderr.String(), tt.out, tt.err)
+		}
+	}
+}
+
+func TestRun_formatUsage(t *testing.T) {
+	tests := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"-format", "xml"}, "posix-expand: unknown format \"xml\"\n"},
+		{[]string{"-format", "json", "-list-vars"}, "posix-expand: -list-vars can't be used with -recursive, -i or -format\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := run(tt.args, strings.NewReader(""), &stdout, &stderr, nil)
+		if status != 2 || stderr.String() != tt.stderr {
+			t.Errorf("run(%q) = %d, %q; want 2, %q", tt.args, status, stderr.String(), tt.stderr)
+		}
+	}
+}
+
+func TestRun_formatInPlace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(name, []byte(`{"$NAME": ["$NAME"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr strings.Builder
+	status := run([]string{"-format", "json", "-i", name}, nil, &stdout, &stderr, []string{"NAME=world"})
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr.String())
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"$NAME": ["world"]}`; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+}
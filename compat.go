@@ -0,0 +1,32 @@
+package posix
+
+// ExpandFunc replaces ${var} or $var in the string using the mapping
+// function, like os.Expand, but with the full set of parameter expansions.
+// It can replace os.Expand without handling errors: if the expansion fails,
+// such as for invalid syntax or a ${var:?message} error, s is returned
+// unchanged.
+//
+// Like os.Expand, every variable is set, so ${var:-word} uses the word
+// when the mapping returns "", but ${var-word} never does. Assignments such
+// as ${var:=word} are visible to the rest of the string. Positional and
+// special parameters, such as $1 and $#, are passed to the mapping by name.
+func ExpandFunc(s string, mapping func(string) string) string {
+	out, err := Expand(s, Chain(RWMap{}, Func(mapping)))
+	if err != nil {
+		return s
+	}
+	return out
+}
+
+// MustExpandEnv replaces ${var} or $var in the string according to the
+// values of the current environment variables, like ExpandEnv, but never
+// returns an error, so it can replace os.ExpandEnv. If the expansion fails,
+// s is returned unchanged. Assignments are visible to the rest of the
+// string without changing the environment.
+func MustExpandEnv(s string) string {
+	out, err := Expand(s, Chain(RWMap{}, osEnviron))
+	if err != nil {
+		return s
+	}
+	return out
+}
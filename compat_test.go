@@ -0,0 +1,50 @@
+package posix
+
+import (
+	"os"
+	"testing"
+)
+
+var compattests = []struct {
+	in, out string
+}{
+	{"", ""},
+	{"$HOME/bin:${PATH}", "/home/me/bin:/bin"},
+	{"${EMPTY:-default} ${EMPTY-default}", "default "},
+	{"${UNSET:-default}", "default"},
+	{"${HOME:+set} ${#HOME}", "set 8"},
+	{"${X:=assigned} $X", "assigned assigned"},
+	{"${HOME:?unused} ${EMPTY:?is empty}", "${HOME:?unused} ${EMPTY:?is empty}"},
+	{"${HOME", "${HOME"},
+}
+
+func TestExpandFunc(t *testing.T) {
+	env := map[string]string{"HOME": "/home/me", "PATH": "/bin", "EMPTY": ""}
+	for _, tt := range compattests {
+		equals(t, tt.out, ExpandFunc(tt.in, func(k string) string { return env[k] }))
+	}
+}
+
+func TestExpandFunc_os(t *testing.T) {
+	mapping := func(k string) string { return "<" + k + ">" }
+	for _, in := range []string{
+		"plain", "$A", "${A}b", "$A.b-c", "a$", "a $A_b ${B}",
+		"$1 $# ${1} $10", "$@ $* $? $$ $! $0 $-",
+	} {
+		equals(t, os.Expand(in, mapping), ExpandFunc(in, mapping))
+	}
+}
+
+func TestMustExpandEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("PATH", "/bin")
+	t.Setenv("EMPTY", "")
+	os.Unsetenv("UNSET")
+	os.Unsetenv("X")
+	for _, tt := range compattests {
+		equals(t, tt.out, MustExpandEnv(tt.in))
+	}
+	// assignments don't change the environment
+	_, ok := os.LookupEnv("X")
+	equals(t, false, ok)
+}
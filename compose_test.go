@@ -0,0 +1,57 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var composetests = []dialecttest{
+	{"plain", "plain"},
+	{"$HOST:${PORT}", "example.com:8080"},
+	{"$$HOST $$$HOST $${HOST} $$", "$HOST $example.com ${HOST} $"},
+	{"$1 $# $@ $ ${HOST}$", "$1 $# $@ $ example.com$"},
+	{`\$HOST '$HOST' "$HOST" ~`, `\example.com 'example.com' "example.com" ~`},
+	{"${UNSET:-default} ${EMPTY:-default} ${EMPTY-default}", "default default "},
+	{"${HOST:+set} ${EMPTY:+set} ${EMPTY+set}", "set  set"},
+	{`${UNSET:-"quoted \value"}`, `"quoted \value"`},
+	{"${UNSET:-${HOST:-x}/$PORT}", "example.com/8080"},
+	{"${UNSET:-$$}", "$"},
+	{"${EMPTY?}", ""},
+}
+
+func TestExpand_compose(t *testing.T) {
+	mapping := Map{"HOST": "example.com", "PORT": "8080", "EMPTY": ""}
+	testDialect(t, Compose, mapping, composetests)
+}
+
+var composeerrortests = []struct {
+	in, err string
+}{
+	{"${UNSET:?must be set}", "required variable UNSET is missing a value: must be set"},
+	{"${EMPTY:?}", "required variable EMPTY is missing a value"},
+	{"${UNSET?}", "required variable UNSET is missing a value"},
+	{"${UNSET?${HOST} is not enough}", "required variable UNSET is missing a value: example.com is not enough"},
+	{"x ${VAR=default}", `Invalid template: "x ${VAR=default}"`},
+	{"${VAR:=default}", `Invalid template: "${VAR:=default}"`},
+	{"${HOST", `Invalid template: "${HOST"`},
+	{"${}", `Invalid template: "${}"`},
+	{"${1}", `Invalid template: "${1}"`},
+	{"${#HOST}", `Invalid template: "${#HOST}"`},
+	{"${HOST%.com}", `Invalid template: "${HOST%.com}"`},
+}
+
+func TestExpand_composeErrors(t *testing.T) {
+	mapping := Map{"HOST": "example.com", "EMPTY": ""}
+	e := NewExpander(WithDialect(Compose))
+	for _, tt := range composeerrortests {
+		_, err := e.Expand(tt.in, mapping)
+		equals(t, tt.err, err.Error())
+	}
+
+	// the position and underlying error are still available
+	_, err := e.Expand("a\n${HOST", mapping)
+	var pe *PosError
+	equals(t, true, errors.As(err, &pe))
+	equals(t, 2, pe.Line)
+	equals(t, "unexpected EOF while looking for matching `}'", errors.Unwrap(pe.Err).Error())
+}
@@ -0,0 +1,12 @@
+package posix
+
+import "github.com/mgood/go-posix/corpus"
+
+// Conformance runs the Expander against the cases of the bundled corpus for
+// a dialect, such as corpus.POSIX, and reports which features behave as
+// expected with the Expander's options.
+func (e *Expander) Conformance(dialect string) (corpus.Report, error) {
+	return corpus.Run(dialect, func(input string, env map[string]string) (string, error) {
+		return e.Expand(input, RWMap(env))
+	})
+}
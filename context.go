@@ -0,0 +1,57 @@
+package posix
+
+import (
+	"context"
+	"fmt"
+)
+
+// contextVarsKey is the context key for the variables added with
+// ContextWithVars.
+type contextVarsKey struct{}
+
+// ContextWithVars returns a copy of the context holding the variables, in
+// addition to any added to the parent, which they override. Use
+// FromContext to expand them.
+func ContextWithVars(ctx context.Context, vars map[string]string) context.Context {
+	merged := make(map[string]string)
+	if parent, ok := ctx.Value(contextVarsKey{}).(map[string]string); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextVarsKey{}, merged)
+}
+
+// FromContext returns a Getter for request-scoped values stored in the
+// context. A name in keys is looked up with ctx.Value using the key it maps
+// to, as with map[string]any{"request_id": requestIDKey{}}, and other names
+// are looked up in the variables added with ContextWithVars.
+//
+// Values are formatted with fmt.Sprint, and nil values are unset.
+func FromContext(ctx context.Context, keys map[string]any) Getter {
+	return contextGetter{ctx, keys}
+}
+
+type contextGetter struct {
+	ctx  context.Context
+	keys map[string]any
+}
+
+func (c contextGetter) Get(k string) (string, bool) {
+	if key, ok := c.keys[k]; ok {
+		v := c.ctx.Value(key)
+		if v == nil {
+			return "", false
+		}
+		if s, ok := v.(string); ok {
+			return s, true
+		}
+		return fmt.Sprint(v), true
+	}
+	vars, _ := c.ctx.Value(contextVarsKey{}).(map[string]string)
+	v, ok := vars[k]
+	return v, ok
+}
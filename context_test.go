@@ -0,0 +1,36 @@
+package posix
+
+import (
+	"context"
+	"testing"
+)
+
+type requestIDKey struct{}
+type tenantKey struct{}
+type userKey struct{}
+
+type ctxUser struct{ name string }
+
+func (u ctxUser) String() string { return u.name }
+
+func TestFromContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), requestIDKey{}, 42)
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	ctx = context.WithValue(ctx, userKey{}, ctxUser{"me"})
+	ctx = ContextWithVars(ctx, map[string]string{"region": "us", "env": "dev"})
+	ctx = ContextWithVars(ctx, map[string]string{"env": "prod"})
+
+	mapping := FromContext(ctx, map[string]any{
+		"request_id": requestIDKey{},
+		"tenant":     tenantKey{},
+		"user":       userKey{},
+		"missing":    "no such key",
+	})
+	out, err := Expand("/$tenant/$env/$region/$request_id/$user ${missing-unset} ${other-unset}", mapping)
+	ok(t, err)
+	equals(t, "/acme/prod/us/42/me unset unset", out)
+
+	out, err = Expand("${env-unset}", FromContext(context.Background(), nil))
+	ok(t, err)
+	equals(t, "unset", out)
+}
@@ -0,0 +1,110 @@
+// Package corpus provides the test cases for the parameter expansions
+// supported by github.com/mgood/go-posix, so other implementations and
+// dialects can be checked against the same expectations.
+package corpus
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+)
+
+// Names of the dialects with expected results in the corpus.
+const (
+	// POSIX is the dialect for the default expansion rules.
+	POSIX = "posix"
+
+	// Bash is the dialect with bash extensions, such as brace expansion.
+	Bash = "bash"
+)
+
+//go:embed cases.json
+var casesJSON []byte
+
+// Case is a single expansion to test.
+type Case struct {
+	// Feature names the kind of expansion under test, such as "default" or
+	// "quoting", for grouping results.
+	Feature string `json:"feature"`
+
+	// Input is the string to expand.
+	Input string `json:"input"`
+
+	// Env holds the variables to expand against. Variables that are not
+	// included are unset. Each case should be run against a fresh mutable
+	// copy, since some cases assign variables.
+	Env map[string]string `json:"env"`
+
+	// Expect holds the expected result by the name of the dialect. A case
+	// without an entry for a dialect is not defined for it.
+	Expect map[string]Result `json:"expect"`
+}
+
+// Result is the expected result of a Case.
+type Result struct {
+	// Output is the expanded string, when there is no error.
+	Output string `json:"output"`
+
+	// Error is the expected error message, or empty if the expansion
+	// should succeed.
+	Error string `json:"error,omitempty"`
+}
+
+// Result returns the expected result for the dialect, or false if the case
+// does not apply to it.
+func (c Case) Result(dialect string) (Result, bool) {
+	r, ok := c.Expect[dialect]
+	return r, ok
+}
+
+type corpus struct {
+	Env   map[string]string `json:"env"`
+	Cases []Case            `json:"cases"`
+}
+
+// Load returns all the cases in the corpus. The cases are decoded on each
+// call, so callers are free to modify them.
+func Load() ([]Case, error) {
+	var c corpus
+	if err := json.Unmarshal(casesJSON, &c); err != nil {
+		return nil, err
+	}
+	for i := range c.Cases {
+		if c.Cases[i].Env == nil {
+			c.Cases[i].Env = make(map[string]string, len(c.Env))
+			for k, v := range c.Env {
+				c.Cases[i].Env[k] = v
+			}
+		}
+	}
+	return c.Cases, nil
+}
+
+// Dialect returns the cases that define a result for the dialect.
+func Dialect(dialect string) ([]Case, error) {
+	cases, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	filtered := cases[:0]
+	for _, c := range cases {
+		if _, ok := c.Expect[dialect]; ok {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
+
+// Features returns the sorted names of the features covered by the cases.
+func Features(cases []Case) []string {
+	seen := make(map[string]bool)
+	var features []string
+	for _, c := range cases {
+		if !seen[c.Feature] {
+			seen[c.Feature] = true
+			features = append(features, c.Feature)
+		}
+	}
+	sort.Strings(features)
+	return features
+}
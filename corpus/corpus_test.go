@@ -0,0 +1,32 @@
+package corpus
+
+import "testing"
+
+func TestLoad(t *testing.T) {
+	cases, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if c.Feature == "" || len(c.Expect) == 0 {
+			t.Errorf("case %#v should have a feature and expectations", c.Input)
+		}
+	}
+
+	// cases get independent environments
+	cases[0].Env["set"] = "changed"
+	if cases[1].Env["set"] != "yes" {
+		t.Errorf("modifying one case's env should not affect others")
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	cases, err := Dialect(POSIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	features := Features(cases)
+	if len(features) == 0 || features[0] != "alternative" {
+		t.Errorf("unexpected features: %v", features)
+	}
+}
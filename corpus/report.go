@@ -0,0 +1,108 @@
+package corpus
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandFunc expands the input against a fresh mutable copy of env, for
+// running the corpus against an implementation.
+type ExpandFunc func(input string, env map[string]string) (string, error)
+
+// Report summarizes the results of running the corpus for a dialect.
+type Report struct {
+	Dialect  string
+	Features []FeatureReport
+}
+
+// FeatureReport holds the results for the cases of a single feature.
+type FeatureReport struct {
+	Feature  string
+	Passed   int
+	Failures []Failure
+}
+
+// Failure describes a case where the result did not match the expectation.
+type Failure struct {
+	Case   Case
+	Output string
+	Err    error
+}
+
+// Passed reports whether all the cases passed.
+func (r Report) Passed() bool {
+	for _, f := range r.Features {
+		if len(f.Failures) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the report as text, with a line for each feature followed
+// by its failures.
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "dialect %s\n", r.Dialect)
+	for _, f := range r.Features {
+		status := "ok"
+		if len(f.Failures) > 0 {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%-4s %-12s %d/%d\n", status, f.Feature, f.Passed, f.Passed+len(f.Failures))
+		for _, fail := range f.Failures {
+			want, _ := fail.Case.Result(r.Dialect)
+			fmt.Fprintf(&b, "\t%q: expected %s, got %s\n", fail.Case.Input, describe(want.Output, want.Error), describeErr(fail.Output, fail.Err))
+		}
+	}
+	return b.String()
+}
+
+func describe(output, err string) string {
+	if err != "" {
+		return fmt.Sprintf("error %q", err)
+	}
+	return fmt.Sprintf("%q", output)
+}
+
+func describeErr(output string, err error) string {
+	if err != nil {
+		return describe("", err.Error())
+	}
+	return describe(output, "")
+}
+
+// Run expands each case of the dialect and reports the results by feature.
+func Run(dialect string, expand ExpandFunc) (Report, error) {
+	cases, err := Dialect(dialect)
+	if err != nil {
+		return Report{}, err
+	}
+
+	r := Report{Dialect: dialect}
+	index := make(map[string]int)
+	for _, c := range cases {
+		i, ok := index[c.Feature]
+		if !ok {
+			i = len(r.Features)
+			index[c.Feature] = i
+			r.Features = append(r.Features, FeatureReport{Feature: c.Feature})
+		}
+
+		want, _ := c.Result(dialect)
+		out, err := expand(c.Input, c.Env)
+		if matches(want, out, err) {
+			r.Features[i].Passed++
+		} else {
+			r.Features[i].Failures = append(r.Features[i].Failures, Failure{c, out, err})
+		}
+	}
+	return r, nil
+}
+
+func matches(want Result, out string, err error) bool {
+	if want.Error != "" {
+		return err != nil && err.Error() == want.Error
+	}
+	return err == nil && out == want.Output
+}
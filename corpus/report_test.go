@@ -0,0 +1,38 @@
+package corpus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	// an implementation that only handles the plain parameter cases
+	r, err := Run(POSIX, func(input string, env map[string]string) (string, error) {
+		if input == "${set}" {
+			return env["set"], nil
+		}
+		return "", errors.New("not supported")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Passed() {
+		t.Fatal("report should not pass")
+	}
+
+	var param FeatureReport
+	for _, f := range r.Features {
+		if f.Feature == "parameter" {
+			param = f
+		}
+	}
+	if param.Passed != 1 || len(param.Failures) == 0 {
+		t.Errorf("unexpected results for parameter feature: %+v", param)
+	}
+
+	s := r.String()
+	if !strings.HasPrefix(s, "dialect posix\nFAIL parameter") || !strings.Contains(s, `"${null}": expected "", got error "not supported"`) {
+		t.Errorf("unexpected report:\n%s", s)
+	}
+}
@@ -0,0 +1,53 @@
+package posix
+
+import (
+	"testing"
+
+	"github.com/mgood/go-posix/corpus"
+)
+
+func TestExpand_corpus(t *testing.T) {
+	testCorpus(t, corpus.POSIX, &Expander{})
+}
+
+func TestExpand_corpusBash(t *testing.T) {
+	testCorpus(t, corpus.Bash, &Expander{Dialect: Bash})
+}
+
+func testCorpus(t *testing.T, dialect string, e *Expander) {
+	cases, err := corpus.Dialect(dialect)
+	ok(t, err)
+	if len(cases) == 0 {
+		t.Fatalf("corpus should not be empty for dialect %s", dialect)
+	}
+
+	for _, tt := range cases {
+		want, _ := tt.Result(dialect)
+		x, err := e.Expand(tt.Input, RWMap(tt.Env))
+		if want.Error != "" {
+			if err == nil || err.Error() != want.Error {
+				t.Errorf("%s: pattern %#v should have produced error %#v, but got: %s", tt.Feature, tt.Input, want.Error, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: pattern %#v should not have produced an error, but got: %s", tt.Feature, tt.Input, err)
+		}
+		if x != want.Output {
+			t.Errorf("%s: pattern %#v should expand to %#v, but got %#v", tt.Feature, tt.Input, want.Output, x)
+		}
+	}
+}
+
+func TestExpander_conformance(t *testing.T) {
+	r, err := new(Expander).Conformance(corpus.POSIX)
+	ok(t, err)
+	if !r.Passed() {
+		t.Errorf("default expander should pass the posix corpus:\n%s", r)
+	}
+
+	// the bash extensions are not enabled by default
+	r, err = new(Expander).Conformance(corpus.Bash)
+	ok(t, err)
+	equals(t, false, r.Passed())
+}
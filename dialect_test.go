@@ -0,0 +1,73 @@
+package posix
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// dialecttest is a case for testDialect.
+type dialecttest struct {
+	in, out string
+}
+
+// testDialect expands each case in the dialect, with and without the
+// shell word options, which the dialects other than POSIX and Bash
+// ignore. The template is also formatted back to a string that expands
+// the same way, and expanded by a Reader.
+func testDialect(t *testing.T, dialect Dialect, mapping Getter, tests []dialecttest) {
+	for _, o := range [][]Option{nil, {WithQuoteRemoval(), WithTilde(nil)}} {
+		e := NewExpander(append(o, WithDialect(dialect))...)
+		for _, tt := range tests {
+			t.Run(tt.in, func(t *testing.T) {
+				out, err := e.Expand(tt.in, mapping)
+				ok(t, err)
+				equals(t, tt.out, out)
+
+				tmpl, err := e.Parse(tt.in)
+				ok(t, err)
+				out, err = e.Expand(tmpl.String(), mapping)
+				ok(t, err)
+				equals(t, tt.out, out)
+
+				r := e.NewReader(iotest.OneByteReader(strings.NewReader(tt.in)), mapping)
+				data, err := io.ReadAll(r)
+				ok(t, err)
+				equals(t, tt.out, string(data))
+			})
+		}
+	}
+}
+
+var dialecttests = []struct {
+	in    string
+	posix string
+	bash  string
+}{
+	{"${a:1:2}", "${a:...}: bash extension not supported by the POSIX dialect", "unexpected op: ':'"},
+	{"${a^^}", "${a^^...}: bash extension not supported by the POSIX dialect", "unexpected op: '^^'"},
+	{"${a,}", "${a,...}: bash extension not supported by the POSIX dialect", "unexpected op: ','"},
+	{"${a/x/y}", "${a/...}: bash extension not supported by the POSIX dialect", "unexpected op: '/'"},
+	{"${a//x/y}", "${a//...}: bash extension not supported by the POSIX dialect", "unexpected op: '//'"},
+	{"${a@Q}", "${a@...}: bash extension not supported by the POSIX dialect", "unexpected op: '@'"},
+	{"${a%x}", "unexpected op: '%'", "unexpected op: '%'"},
+	{"${a##x}", "unexpected op: '##'", "unexpected op: '##'"},
+	{"${a:%x}", "${a:%x}: bad substitution", "${a:%x}: bad substitution"},
+	{"${a:}", "${a:}: bad substitution", "${a:}: bad substitution"},
+}
+
+func TestValidate_dialect(t *testing.T) {
+	for _, test := range dialecttests {
+		err := Validate(test.in)
+		equals(t, test.posix, err.Error())
+
+		err = NewExpander(WithDialect(Bash)).Validate(test.in)
+		equals(t, test.bash, err.Error())
+	}
+}
+
+func TestValidate_dialectStable(t *testing.T) {
+	err := NewExpander(WithStableErrors()).Validate("${a^^}")
+	equals(t, "bad substitution", err.Error())
+}
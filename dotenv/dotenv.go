@@ -0,0 +1,223 @@
+// Package dotenv reads .env files into a mapping for expanding parameters
+// with github.com/mgood/go-posix.
+//
+// A .env file has one "KEY=value" assignment per line, optionally preceded
+// by "export". Blank lines and lines starting with "#" are ignored. Values
+// may be single-quoted, taken literally, or double-quoted, where "\n",
+// "\t", "\"", "\\" and "\$" are escapes, and either kind of quotes may span
+// multiple lines. Unquoted values end at a " #" comment and have
+// surrounding spaces removed. Values are not expanded when they are read,
+// but File.Expand expands them, referring to the variables assigned before
+// them.
+package dotenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mgood/go-posix"
+)
+
+// File is the contents of a .env file, which implements the posix.Getter
+// and posix.Setter interfaces.
+type File struct {
+	// Path is the file the variables were loaded from, and are saved to.
+	Path string
+
+	// WriteBack saves the file to Path after each assignment, so
+	// ${VAR:=default} can persist a default value.
+	WriteBack bool
+
+	vars   map[string]string
+	chunks []chunk
+}
+
+// chunk is a part of the file, either an assignment to a key, or other text
+// such as comments and blank lines that is kept unchanged.
+type chunk struct {
+	key    string
+	export bool
+	text   string
+	line   int
+
+	// template is the value to expand, unless it is literal, which is
+	// a shell double-quoted string for a double-quoted value
+	template     string
+	literal      bool
+	doubleQuoted bool
+}
+
+// Load reads the .env file at the path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.Path = path
+	return f, nil
+}
+
+// Parse reads the contents of a .env file. A syntax error is reported as a
+// *posix.LineError.
+func Parse(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: string(data), line: 1}
+	f := &File{vars: make(map[string]string)}
+	for p.src != "" {
+		c, value, err := p.next()
+		if err != nil {
+			return nil, &posix.LineError{Line: p.start, Err: err}
+		}
+		if c.key != "" {
+			if _, ok := f.vars[c.key]; ok {
+				// the last assignment wins, so drop the earlier one when
+				// the file is written
+				f.remove(c.key)
+			}
+			f.vars[c.key] = value
+		}
+		f.chunks = append(f.chunks, c)
+	}
+	return f, nil
+}
+
+func (f *File) Get(k string) (string, bool) {
+	v, ok := f.vars[k]
+	return v, ok
+}
+
+// Set assigns the variable, and saves the file with WriteBack. A new
+// variable is added to the end of the file.
+func (f *File) Set(k, v string) error {
+	if f.vars == nil {
+		f.vars = make(map[string]string)
+	}
+	if _, ok := f.vars[k]; ok {
+		f.modified(k)
+	} else {
+		f.chunks = append(f.chunks, chunk{key: k, literal: true})
+	}
+	f.vars[k] = v
+	if f.WriteBack {
+		return f.Save()
+	}
+	return nil
+}
+
+// Keys returns the names of the variables in the order they are assigned
+// in the file.
+func (f *File) Keys() []string {
+	var keys []string
+	for _, c := range f.chunks {
+		if c.key != "" {
+			keys = append(keys, c.key)
+		}
+	}
+	return keys
+}
+
+// Environ returns the variables as "key=value" strings in sorted order.
+func (f *File) Environ() []string {
+	env := make([]string, 0, len(f.vars))
+	for k, v := range f.vars {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// WriteTo writes the file, keeping comments and the formatting of
+// assignments to variables that were not changed.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, c := range f.chunks {
+		if c.text != "" {
+			b.WriteString(c.text)
+			continue
+		}
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		if c.export {
+			b.WriteString("export ")
+		}
+		b.WriteString(c.key + "=" + quote(f.vars[c.key]) + "\n")
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Save writes the file to Path.
+func (f *File) Save() error {
+	if f.Path == "" {
+		return fmt.Errorf("dotenv: no path to save the file to")
+	}
+	var b bytes.Buffer
+	f.WriteTo(&b)
+	return os.WriteFile(f.Path, b.Bytes(), 0o644)
+}
+
+// remove drops the assignment to the key, so a later one replaces it.
+func (f *File) remove(k string) {
+	for i, c := range f.chunks {
+		if c.key == k {
+			f.chunks = append(f.chunks[:i], f.chunks[i+1:]...)
+			return
+		}
+	}
+}
+
+// modified marks the assignment to the key to be written from its value
+// instead of the original text. The value is written quoted, so it is no
+// longer expanded.
+func (f *File) modified(k string) {
+	for i := range f.chunks {
+		if f.chunks[i].key == k {
+			f.chunks[i].text = ""
+			f.chunks[i].literal = true
+		}
+	}
+}
+
+// Marshal formats the variables as a .env file, with one assignment per
+// line in sorted order, and the values quoted so Parse reads them back
+// unchanged and File.Expand does not expand them.
+func Marshal(vars map[string]string) ([]byte, error) {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		if !isName(k) {
+			return nil, fmt.Errorf("dotenv: invalid variable name %q", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	for _, k := range keys {
+		b.WriteString(k + "=" + quote(vars[k]) + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+// quote formats a value so it is read back unchanged.
+func quote(v string) string {
+	if v != "" && strings.Trim(v, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./:@%+,=-") == "" {
+		return v
+	}
+	if !strings.ContainsAny(v, "'\n") {
+		return "'" + v + "'"
+	}
+	return `"` + escaper.Replace(v) + `"`
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\t", `\t`)
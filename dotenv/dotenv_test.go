@@ -0,0 +1,251 @@
+package dotenv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mgood/go-posix"
+)
+
+const example = `# settings
+export HOME=/home/me
+NAME = app  # the app name
+EMPTY=
+SINGLE='it is $HOME'
+DOUBLE="line 1\nline \"2\" \$HOME"
+MULTI="a
+b"
+PATH=$HOME/bin
+NAME=override
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse(strings.NewReader(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"DOUBLE=line 1\nline \"2\" $HOME",
+		"EMPTY=",
+		"HOME=/home/me",
+		"MULTI=a\nb",
+		"NAME=override",
+		"PATH=$HOME/bin",
+		"SINGLE=it is $HOME",
+	}
+	if env := f.Environ(); !reflect.DeepEqual(expect, env) {
+		t.Errorf("expected %q, got %q", expect, env)
+	}
+	expectKeys := []string{"HOME", "EMPTY", "SINGLE", "DOUBLE", "MULTI", "PATH", "NAME"}
+	if keys := f.Keys(); !reflect.DeepEqual(expectKeys, keys) {
+		t.Errorf("expected keys %q, got %q", expectKeys, keys)
+	}
+
+	out, err := posix.Expand("$PATH ${EMPTY:-default} ${UNSET-unset}", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "$HOME/bin default unset" {
+		t.Errorf("unexpected expansion: %q", out)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	cases := []struct {
+		in  string
+		err string
+	}{
+		{"A=1\nnot an assignment\n", "line 2: expected an assignment like KEY=value"},
+		{"1A=x", `line 1: invalid variable name "1A"`},
+		{"A=1\nB=\"open\n\n", "line 2: unexpected EOF while looking for matching `\"'"},
+		{"A='x' y", "line 1: unexpected text after the value of A"},
+		{"A=\"x\ny\"\nB", "line 3: expected an assignment like KEY=value"},
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c.in))
+		if err == nil || err.Error() != c.err {
+			t.Errorf("%q: expected error %q, got %v", c.in, c.err, err)
+		}
+		var lineErr *posix.LineError
+		if !errors.As(err, &lineErr) {
+			t.Errorf("%q: expected a LineError, got %#v", c.in, err)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	f, err := Parse(strings.NewReader("# comment\nexport A=1 # keep\nB='x'\nC=3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Set("B", "it's\nnew")
+	f.Set("D", "$x y")
+	f.Set("E", "plain")
+
+	var b strings.Builder
+	f.WriteTo(&b)
+	expect := "# comment\nexport A=1 # keep\nB=\"it's\\nnew\"\nC=3\nD='$x y'\nE=plain\n"
+	if b.String() != expect {
+		t.Errorf("expected %q, got %q", expect, b.String())
+	}
+
+	g, err := Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Environ(), g.Environ()) {
+		t.Errorf("expected the written file to read back as %q, got %q", f.Environ(), g.Environ())
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("# defaults\nA=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteBack = true
+
+	out, err := posix.Expand("${A:=x} ${B:=two words}", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "1 two words" {
+		t.Errorf("unexpected expansion: %q", out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# defaults\nA=1\nB='two words'\n" {
+		t.Errorf("unexpected file contents: %q", data)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+	if err := new(File).Save(); err == nil {
+		t.Error("expected an error saving a file without a path")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	f, err := Parse(strings.NewReader(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := f.Expand(posix.Map{"HOME": "/root", "USER": "me"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := posix.Map{
+		"HOME":   "/home/me",
+		"NAME":   "override",
+		"EMPTY":  "",
+		"SINGLE": "it is $HOME",
+		"DOUBLE": "line 1\nline \"2\" $HOME",
+		"MULTI":  "a\nb",
+		"PATH":   "/home/me/bin",
+	}
+	if !reflect.DeepEqual(expect, vars) {
+		t.Errorf("expected %q, got %q", expect, vars)
+	}
+
+	cases := []struct {
+		in     string
+		expect string
+	}{
+		{"A=${USER}-$B\nB=1\n", "me-"},
+		{"B=1\nA=\"${B}:${USER:-x} \\$B\"\n", "1:me $B"},
+		{"B=1\nA=\"x\\\\$B\"\n", "x\\1"},
+		{"B=1\nA=\"\\\\\\$B \\q 'x' \\\"$B\\\"\"\n", "\\$B \\q 'x' \"1\""},
+		{"B=1\nA=\"${B:+\\$B}\"\n", "$B"},
+		{"A=${UNSET:-${USER}}\n", "me"},
+		{"A=${B:=set}/$B\n", "set/set"},
+	}
+	for _, c := range cases {
+		f, err := Parse(strings.NewReader(c.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vars, err := f.Expand(posix.Map{"USER": "me"})
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+		} else if vars["A"] != c.expect {
+			t.Errorf("%q: expected A=%q, got %q", c.in, c.expect, vars["A"])
+		}
+	}
+
+	f, err = Parse(strings.NewReader("A=$HOME\nB=$A\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Set("A", "$literal")
+	vars, err = f.Expand(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["B"] != "$literal" {
+		t.Errorf("expected the value set to be literal, got %q", vars["B"])
+	}
+}
+
+func TestExpand_errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("A=1\n\nB=\"${C:?C is required}\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Expand(nil)
+	if expect := path + ": line 3: C is required"; err == nil || err.Error() != expect {
+		t.Errorf("expected error %q, got %v", expect, err)
+	}
+	var lineErr *posix.LineError
+	if !errors.As(err, &lineErr) {
+		t.Errorf("expected a LineError, got %#v", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	vars := map[string]string{
+		"PLAIN":  "/usr/bin",
+		"EMPTY":  "",
+		"SPACES": "two words",
+		"REF":    "$HOME",
+		"QUOTES": "it's \"x\"\n$y",
+	}
+	data, err := Marshal(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "EMPTY=''\nPLAIN=/usr/bin\nQUOTES=\"it's \\\"x\\\"\\n\\$y\"\nREF='$HOME'\nSPACES='two words'\n"
+	if string(data) != expect {
+		t.Errorf("expected %q, got %q", expect, data)
+	}
+
+	f, err := Parse(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.Expand(posix.Map{"HOME": "/root", "y": "z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(posix.Map(vars), got) {
+		t.Errorf("expected the marshaled file to read back as %q, got %q", vars, got)
+	}
+
+	if _, err := Marshal(map[string]string{"1A": "x"}); err == nil || err.Error() != `dotenv: invalid variable name "1A"` {
+		t.Errorf("expected an invalid name error, got %v", err)
+	}
+}
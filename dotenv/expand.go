@@ -0,0 +1,51 @@
+package dotenv
+
+import (
+	"fmt"
+
+	"github.com/mgood/go-posix"
+)
+
+// Expand returns the values of the variables with the parameters in
+// unquoted and double-quoted values expanded, in the order they are
+// assigned in the file, so a value can refer to the variables assigned
+// before it. Other variables are looked up in env, which may be nil.
+// Single-quoted values, an escaped "\$" in double quotes, and values
+// assigned with Set are taken literally. The file itself is unchanged.
+//
+// An expansion error is reported as a *posix.LineError.
+func (f *File) Expand(env posix.Getter, opts ...posix.Option) (posix.Map, error) {
+	e := posix.NewExpander(opts...)
+	// double-quoted values are expanded like a shell, removing the quotes
+	// and escapes
+	q := *e
+	q.QuoteRemoval = true
+	vars := posix.RWMap{}
+	var mapping posix.Getter = vars
+	if env != nil {
+		mapping = posix.Chain(vars, env)
+	}
+	for _, c := range f.chunks {
+		if c.key == "" {
+			continue
+		}
+		if c.literal {
+			vars[c.key] = f.vars[c.key]
+			continue
+		}
+		x := e
+		if c.doubleQuoted {
+			x = &q
+		}
+		v, err := x.Expand(c.template, mapping)
+		if err != nil {
+			err = &posix.LineError{Line: c.line, Err: err}
+			if f.Path != "" {
+				err = fmt.Errorf("%s: %w", f.Path, err)
+			}
+			return nil, err
+		}
+		vars[c.key] = v
+	}
+	return posix.Map(vars), nil
+}
@@ -0,0 +1,142 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type parser struct {
+	src   string
+	line  int // the line number at the start of src
+	start int // the line number where the current chunk started
+}
+
+// next parses the next line of the file, or multiple lines for a quoted
+// value, returning the chunk and the value of an assignment.
+func (p *parser) next() (chunk, string, error) {
+	p.start = p.line
+	text := p.src
+	rest := strings.TrimLeft(text, " \t")
+
+	if rest == "" || rest[0] == '\n' || rest[0] == '#' {
+		p.consumeLine()
+		return chunk{text: text[:len(text)-len(p.src)]}, "", nil
+	}
+
+	c := chunk{}
+	if after, ok := strings.CutPrefix(rest, "export"); ok && after != "" && (after[0] == ' ' || after[0] == '\t') {
+		c.export = true
+		rest = strings.TrimLeft(after, " \t")
+	}
+	eq := strings.IndexByte(rest, '=')
+	if eq < 0 {
+		return c, "", errors.New("expected an assignment like KEY=value")
+	}
+	c.key = strings.TrimRight(rest[:eq], " \t")
+	if !isName(c.key) {
+		return c, "", fmt.Errorf("invalid variable name %q", c.key)
+	}
+	rest = strings.TrimLeft(rest[eq+1:], " \t")
+
+	var value string
+	var err error
+	c.line = p.start
+	switch {
+	case strings.HasPrefix(rest, "'"):
+		value, _, rest, err = p.quoted(rest, '\'')
+		c.literal = true
+	case strings.HasPrefix(rest, `"`):
+		value, c.template, rest, err = p.quoted(rest, '"')
+		c.doubleQuoted = true
+	default:
+		end := strings.IndexByte(rest, '\n')
+		if end < 0 {
+			end = len(rest)
+		}
+		value = rest[:end]
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		}
+		value = strings.TrimRight(value, " \t\r")
+		c.template = value
+		rest = rest[end:]
+	}
+	if err != nil {
+		return c, "", err
+	}
+
+	// only a comment may follow a quoted value
+	trailing := strings.TrimLeft(rest, " \t\r")
+	if trailing != "" && trailing[0] != '\n' && trailing[0] != '#' {
+		return c, "", fmt.Errorf("unexpected text after the value of %s", c.key)
+	}
+	p.src = rest
+	p.consumeLine()
+	c.text = text[:len(text)-len(p.src)]
+	return c, value, nil
+}
+
+// quoted returns the value of the quoted string at the start of s, the
+// template to expand it, and the rest of the string after the closing
+// quote. The template of a double-quoted value is the same value as a
+// shell's double-quoted string, so each backslash escapes the next
+// character the same way when it is expanded.
+func (p *parser) quoted(s string, quote byte) (string, string, string, error) {
+	var b, t strings.Builder
+	t.WriteByte('"')
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			t.WriteByte('"')
+			return b.String(), t.String(), s[i+1:], nil
+		case c == '\\' && quote == '"' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+				t.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+				t.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+				t.WriteString(s[i-1 : i+1])
+			default:
+				b.WriteString(s[i-1 : i+1])
+				t.WriteString(`\\` + s[i:i+1])
+			}
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			b.WriteByte(c)
+			t.WriteByte(c)
+		}
+	}
+	return "", "", "", fmt.Errorf("unexpected EOF while looking for matching `%c'", quote)
+}
+
+// consumeLine skips the rest of the current line, including a comment and
+// the line ending.
+func (p *parser) consumeLine() {
+	end := strings.IndexByte(p.src, '\n')
+	if end < 0 {
+		p.src = ""
+		return
+	}
+	p.src = p.src[end+1:]
+	p.line++
+}
+
+// isName reports whether the string is a valid shell variable name.
+func isName(s string) bool {
+	for i, c := range s {
+		alpha := c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+		if !alpha && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
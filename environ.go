@@ -0,0 +1,131 @@
+package posix
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Environ implements the Getter and Setter interfaces for a set of shell
+// variables, tracking which of them are exported to child processes.
+//
+// Setting a variable does not change whether it is exported, the same as
+// `X=1` in a shell, while Export marks it like `export X`. An Environ must
+// not be modified concurrently with other uses, see SyncRWMap.
+type Environ struct {
+	vars map[string]environVar
+}
+
+type environVar struct {
+	value    string
+	set      bool
+	exported bool
+}
+
+// NewEnviron returns an Environ initialized from a list of "key=value"
+// strings, such as the result of os.Environ. The initial variables are
+// marked as exported.
+func NewEnviron(env []string) *Environ {
+	e := &Environ{vars: make(map[string]environVar, len(env))}
+	for _, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		e.vars[k] = environVar{value: v, set: true, exported: true}
+	}
+	return e
+}
+
+func (e *Environ) Get(k string) (string, bool) {
+	v := e.vars[k]
+	return v.value, v.set
+}
+
+func (e *Environ) Set(k, v string) error {
+	if e.vars == nil {
+		e.vars = make(map[string]environVar)
+	}
+	x := e.vars[k]
+	x.value = v
+	x.set = true
+	e.vars[k] = x
+	return nil
+}
+
+// Unset removes the variable, including its export marking.
+func (e *Environ) Unset(k string) {
+	delete(e.vars, k)
+}
+
+// Export marks the variable as exported. The variable does not need to be
+// set yet, it will be included in Exported once it is assigned a value.
+func (e *Environ) Export(k string) {
+	if e.vars == nil {
+		e.vars = make(map[string]environVar)
+	}
+	x := e.vars[k]
+	x.exported = true
+	e.vars[k] = x
+}
+
+// Unexport clears the export marking while keeping the variable's value, so
+// it is only visible to expansions and not to child processes.
+func (e *Environ) Unexport(k string) {
+	if x, ok := e.vars[k]; ok {
+		x.exported = false
+		e.vars[k] = x
+	}
+}
+
+// IsExported reports whether the variable is marked as exported.
+func (e *Environ) IsExported(k string) bool {
+	return e.vars[k].exported
+}
+
+// Exported returns the exported variables that have a value as "key=value"
+// strings in sorted order, suitable for exec.Cmd.Env.
+func (e *Environ) Exported() []string {
+	env := make([]string, 0, len(e.vars))
+	for k, v := range e.vars {
+		if v.set && v.exported {
+			env = append(env, k+"="+v.value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+// ExpandEnviron expands the value of each "key=value" string, such as the
+// result of os.Environ, against the entries before it, so that
+// "PATH=$HOME/bin" uses the value of an earlier HOME entry. The result is
+// suitable for exec.Cmd.Env. Strings without a "=" are kept unchanged.
+//
+// Every entry is expanded even if some fail. The value of an entry with an
+// error is empty, and the error returned joins an IndexError for each
+// failed entry.
+func ExpandEnviron(env []string) ([]string, error) {
+	return new(Expander).ExpandEnviron(env)
+}
+
+// ExpandEnviron expands the entries like ExpandEnviron, using the options
+// set on the Expander.
+func (e *Expander) ExpandEnviron(env []string) ([]string, error) {
+	vars := &Environ{}
+	out := make([]string, len(env))
+	var errs []error
+	for i, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			out[i] = kv
+			continue
+		}
+		v, err := e.Expand(v, vars)
+		if err != nil {
+			errs = append(errs, &IndexError{Index: i, Err: err})
+		}
+		vars.Set(k, v)
+		out[i] = k + "=" + v
+	}
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package posix
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnviron_exported(t *testing.T) {
+	env := NewEnviron([]string{"HOME=/home/me", "PATH=/bin", "bogus"})
+	equals(t, []string{"HOME=/home/me", "PATH=/bin"}, env.Exported())
+
+	// local assignment is visible to expansion, but not exported
+	x, err := Expand("${local:=value}", env)
+	ok(t, err)
+	equals(t, "value", x)
+	equals(t, false, env.IsExported("local"))
+	equals(t, []string{"HOME=/home/me", "PATH=/bin"}, env.Exported())
+
+	env.Export("local")
+	equals(t, []string{"HOME=/home/me", "PATH=/bin", "local=value"}, env.Exported())
+
+	// assigning an exported variable keeps it exported
+	ok(t, env.Set("PATH", "/usr/bin"))
+	equals(t, []string{"HOME=/home/me", "PATH=/usr/bin", "local=value"}, env.Exported())
+
+	env.Unexport("HOME")
+	env.Unset("local")
+	equals(t, []string{"PATH=/usr/bin"}, env.Exported())
+	v, set := env.Get("HOME")
+	equals(t, "/home/me", v)
+	equals(t, true, set)
+}
+
+func TestEnviron_exportBeforeSet(t *testing.T) {
+	var env Environ
+	env.Export("X")
+	equals(t, []string{}, env.Exported())
+	_, set := env.Get("X")
+	equals(t, false, set)
+
+	ok(t, env.Set("X", "1"))
+	equals(t, []string{"X=1"}, env.Exported())
+}
+
+func TestExpandEnviron(t *testing.T) {
+	env, err := ExpandEnviron([]string{
+		"HOME=/home/me",
+		"PATH=$HOME/bin:$PATH",
+		"bogus",
+		"PATH=/usr/bin:$PATH",
+		"LATER=$NEXT",
+		"NEXT=${UNSET:-default}",
+	})
+	ok(t, err)
+	equals(t, []string{
+		"HOME=/home/me",
+		"PATH=/home/me/bin:",
+		"bogus",
+		"PATH=/usr/bin:/home/me/bin:",
+		"LATER=",
+		"NEXT=default",
+	}, env)
+}
+
+func TestExpandEnviron_errors(t *testing.T) {
+	env, err := ExpandEnviron([]string{"A=${X:?}", "B=$A-ok", "C=${"})
+	equals(t, []string{"A=", "B=-ok", "C="}, env)
+	equals(t, "[0]: X: parameter null or not set\n[2]: unexpected EOF while looking for matching `}'", err.Error())
+}
+
+func TestEnvironSnapshot(t *testing.T) {
+	t.Setenv("POSIX_SNAPSHOT_TEST", "before")
+	snapshot := EnvironSnapshot()
+	t.Setenv("POSIX_SNAPSHOT_TEST", "after")
+	t.Setenv("POSIX_SNAPSHOT_NEW", "new")
+
+	out, err := Expand("$POSIX_SNAPSHOT_TEST ${POSIX_SNAPSHOT_NEW-unset} $0", snapshot)
+	ok(t, err)
+	equals(t, "before unset "+os.Args[0], out)
+
+	_, err = Expand("${POSIX_SNAPSHOT_NEW:=x}", snapshot)
+	if err == nil {
+		t.Fatal("assignment to a snapshot should return an error")
+	}
+}
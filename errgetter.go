@@ -0,0 +1,39 @@
+package posix
+
+// ErrGetter is an optional interface for mappings whose lookups can fail,
+// such as mappings backed by a remote secret store. When the mapping
+// implements it, variables are looked up with Lookup instead of Get, and a
+// lookup error stops the expansion with an error wrapping it, rather than
+// the variable expanding as if it were unset.
+type ErrGetter interface {
+	Lookup(key string) (value string, exists bool, err error)
+}
+
+// lookupIn looks up the variable in a wrapped mapping with Lookup when it
+// implements ErrGetter, so wrappers pass lookup errors through.
+func lookupIn(mapping Getter, k string) (string, bool, error) {
+	if eg, ok := mapping.(ErrGetter); ok {
+		return eg.Lookup(k)
+	}
+	v, ok := mapping.Get(k)
+	return v, ok, nil
+}
+
+// lookupErr returns the value of a parameter like lookup, looking up
+// variables with GetContext when expanding with a context and the mapping
+// implements ContextGetter, or with Lookup when it implements ErrGetter.
+func (ev *evaluator) lookupErr(name string) (string, bool, error) {
+	if ev.ctx != nil {
+		if err := ev.ctx.Err(); err != nil {
+			return "", false, err
+		}
+		if cg, ok := ev.mapping.(ContextGetter); ok && isVarName(name) {
+			return cg.GetContext(ev.ctx, name)
+		}
+	}
+	if eg, ok := ev.mapping.(ErrGetter); ok && isVarName(name) {
+		return eg.Lookup(name)
+	}
+	v, ok := ev.lookup(name)
+	return v, ok, nil
+}
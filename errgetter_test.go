@@ -0,0 +1,59 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var errLookup = errors.New("connection refused")
+
+type remoteMap map[string]string
+
+func (m remoteMap) Get(k string) (string, bool) {
+	v, ok, _ := m.Lookup(k)
+	return v, ok
+}
+
+func (m remoteMap) Lookup(k string) (string, bool, error) {
+	if k == "FAIL" {
+		return "", false, errLookup
+	}
+	v, ok := m[k]
+	return v, ok, nil
+}
+
+func TestErrGetter(t *testing.T) {
+	out, err := Expand("$A ${B-unset} ${#A}", remoteMap{"A": "abc"})
+	ok(t, err)
+	equals(t, "abc unset 3", out)
+
+	for _, in := range []string{"$FAIL", "${FAIL:-default}", "${#FAIL}", "${FAIL+set}"} {
+		_, err := Expand(in, remoteMap{})
+		if !errors.Is(err, errLookup) {
+			t.Fatalf("%s: expected the lookup error, got: %#v", in, err)
+		}
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) {
+			t.Fatalf("%s: expected an ExpandError, got: %#v", in, err)
+		}
+		equals(t, "FAIL", expandErr.Name)
+	}
+}
+
+func TestErrGetter_params(t *testing.T) {
+	out, err := ExpandWithArgs("$1 $A", remoteMap{"A": "a"}, []string{"x"})
+	ok(t, err)
+	equals(t, "x a", out)
+
+	_, err = ExpandWithArgs("$1 $FAIL", remoteMap{}, []string{"x"})
+	equals(t, true, errors.Is(err, errLookup))
+}
+
+func TestErrGetter_chain(t *testing.T) {
+	out, err := Expand("$A", Chain(Map{"A": "a"}, remoteMap{}))
+	ok(t, err)
+	equals(t, "a", out)
+
+	_, err = Expand("$FAIL", Chain(Map{}, remoteMap{}, Map{"FAIL": "x"}))
+	equals(t, true, errors.Is(err, errLookup))
+}
@@ -0,0 +1,346 @@
+package posix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The wording of error messages may change between versions. Errors
+// implement stableError to provide a fixed message for callers that set
+// Expander.StableErrors, so existing messages never change. Each stable
+// message has its own format, rather than calling Error, so rewording an
+// error does not change it, and each is listed in the StableErrors doc.
+type stableError interface {
+	error
+	stableMessage() string
+}
+
+// Reached the end of the input while looking for a closing character.
+type eofError struct {
+	char rune
+}
+
+func (e eofError) Error() string {
+	return fmt.Sprintf("unexpected EOF while looking for matching `%c'", e.char)
+}
+
+func (e eofError) stableMessage() string {
+	return fmt.Sprintf("unexpected EOF while looking for matching `%c'", e.char)
+}
+
+// A parameter was null or not set for the "?" operator.
+type unsetError struct {
+	name    string
+	message string
+}
+
+func (e unsetError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+	return fmt.Sprintf("%s: parameter null or not set", e.name)
+}
+
+func (e unsetError) stableMessage() string {
+	if e.message != "" {
+		return e.message
+	}
+	return fmt.Sprintf("%s: parameter null or not set", e.name)
+}
+
+// A parameter was null or not set for the "?" operator in the Compose
+// dialect, with the message of Compose.
+type requiredError struct {
+	name   string
+	reason string
+}
+
+func (e requiredError) Error() string {
+	if e.reason != "" {
+		return fmt.Sprintf("required variable %s is missing a value: %s", e.name, e.reason)
+	}
+	return fmt.Sprintf("required variable %s is missing a value", e.name)
+}
+
+func (e requiredError) stableMessage() string {
+	if e.reason != "" {
+		return fmt.Sprintf("required variable %s is missing a value: %s", e.name, e.reason)
+	}
+	return fmt.Sprintf("required variable %s is missing a value", e.name)
+}
+
+// The mapping cannot be assigned to with the "=" operator.
+type assignError struct {
+	name    string
+	mapping Getter
+}
+
+func (e assignError) Error() string {
+	return fmt.Sprintf("mapping type %T does not support assignment", e.mapping)
+}
+
+func (e assignError) stableMessage() string {
+	return fmt.Sprintf("%s: cannot assign in this way", e.name)
+}
+
+// Assignments are disabled with the AssignError option.
+type readOnlyError struct {
+	name string
+}
+
+func (e readOnlyError) Error() string {
+	return fmt.Sprintf("%s: readonly variable", e.name)
+}
+
+func (e readOnlyError) stableMessage() string {
+	return fmt.Sprintf("%s: readonly variable", e.name)
+}
+
+// Positional and special parameters cannot be assigned with the "="
+// operator.
+type specialAssignError struct {
+	name string
+}
+
+func (e specialAssignError) Error() string {
+	return fmt.Sprintf("$%s: cannot assign in this way", e.name)
+}
+
+func (e specialAssignError) stableMessage() string {
+	return fmt.Sprintf("$%s: cannot assign in this way", e.name)
+}
+
+// A parameter was not set with the NoUnset option.
+type unboundError struct {
+	name string
+}
+
+func (e unboundError) Error() string {
+	if isName(e.name) {
+		return fmt.Sprintf("%s: unbound variable", e.name)
+	}
+	return fmt.Sprintf("$%s: unbound variable", e.name)
+}
+
+func (e unboundError) stableMessage() string {
+	if isName(e.name) {
+		return fmt.Sprintf("%s: unbound variable", e.name)
+	}
+	return fmt.Sprintf("$%s: unbound variable", e.name)
+}
+
+// A parameter was set to an empty value with the NoEmpty option.
+type emptyError struct {
+	name string
+}
+
+func (e emptyError) Error() string {
+	if isName(e.name) {
+		return fmt.Sprintf("%s: parameter is empty", e.name)
+	}
+	return fmt.Sprintf("$%s: parameter is empty", e.name)
+}
+
+func (e emptyError) stableMessage() string {
+	if isName(e.name) {
+		return fmt.Sprintf("%s: parameter is empty", e.name)
+	}
+	return fmt.Sprintf("$%s: parameter is empty", e.name)
+}
+
+// A variable was not in the Allow list with the DisallowError option.
+type disallowedError struct {
+	name string
+}
+
+func (e disallowedError) Error() string {
+	return fmt.Sprintf("%s: variable not allowed", e.name)
+}
+
+func (e disallowedError) stableMessage() string {
+	return fmt.Sprintf("%s: variable not allowed", e.name)
+}
+
+// ErrOutputLimit is returned when the result of an expansion is longer than
+// the MaxOutput option.
+var ErrOutputLimit = errors.New("expansion exceeds the output limit")
+
+// ErrParseOption is returned when a Template is executed with an option that
+// changes how it is parsed, which can only be set when it is parsed.
+var ErrParseOption = errors.New("option can only be set when parsing")
+
+// An option passed to Execute changes how the template is parsed.
+type parseOptionError struct {
+	option string
+}
+
+func (e parseOptionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.option, ErrParseOption)
+}
+
+func (e parseOptionError) stableMessage() string {
+	return fmt.Sprintf("%s: option can only be set when parsing", e.option)
+}
+
+func (e parseOptionError) Unwrap() error {
+	return ErrParseOption
+}
+
+// The expansion used an operator that is not supported.
+type opError struct {
+	op string
+}
+
+func (e opError) Error() string {
+	return fmt.Sprintf("unexpected op: '%s'", e.op)
+}
+
+func (e opError) stableMessage() string {
+	return "bad substitution"
+}
+
+// The expansion could not be parsed, such as a parameter with an invalid
+// name. The reference is the text of the whole expansion.
+type substError struct {
+	ref string
+}
+
+func (e substError) Error() string {
+	return fmt.Sprintf("%s: bad substitution", e.ref)
+}
+
+func (e substError) stableMessage() string {
+	return "bad substitution"
+}
+
+// ExpandError is an error from expanding a parameter, such as
+// ${name:?message} when name is unset, or ${name=word} when the mapping
+// does not support assignment. The message is the same as the underlying
+// error.
+type ExpandError struct {
+	Name    string // the parameter being expanded
+	Op      rune   // the operator, one of '-', '=', '?' or '+', or 0 for none
+	Colon   bool   // whether the operator was preceded by a colon
+	Message string // the word of ${name?message}, if any
+	Err     error  // the underlying error
+}
+
+func (e *ExpandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExpandError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExpandError) stableMessage() string {
+	if s, ok := e.Err.(stableError); ok {
+		return s.stableMessage()
+	}
+	return e.Err.Error()
+}
+
+// PosError is an error at a position in the input string. The message is
+// the same as the underlying error, and the position is available for
+// callers to report where the problem occurred.
+type PosError struct {
+	Pos    Pos // the byte offset of the expansion or quote with the error
+	Line   int // the line number of Pos, starting at 1
+	Column int // the byte offset of Pos within its line, starting at 1
+	Err    error
+}
+
+// newPosError returns a PosError for the offset in the input.
+func newPosError(src string, pos Pos, err error) *PosError {
+	line, col := position(src, pos)
+	return &PosError{Pos: pos, Line: line, Column: col, Err: err}
+}
+
+// position returns the line and column of the offset in the input,
+// starting at 1.
+func position(src string, pos Pos) (line, col int) {
+	before := src[:pos]
+	line = strings.Count(before, "\n") + 1
+	col = len(before) - strings.LastIndexByte(before, '\n')
+	return line, col
+}
+
+func (e *PosError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PosError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PosError) stableMessage() string {
+	if s, ok := e.Err.(stableError); ok {
+		return s.stableMessage()
+	}
+	return e.Err.Error()
+}
+
+// The expansion used an operator that is only supported by the Bash
+// dialect.
+type dialectError struct {
+	name string
+	op   string
+}
+
+func (e dialectError) Error() string {
+	return fmt.Sprintf("${%s%s...}: bash extension not supported by the POSIX dialect", e.name, e.op)
+}
+
+func (e dialectError) stableMessage() string {
+	return "bad substitution"
+}
+
+// A syntax error in the Compose dialect, which like Compose reports the
+// whole template.
+type templateError struct {
+	template string
+	err      error
+}
+
+func (e templateError) Error() string {
+	return fmt.Sprintf("Invalid template: %q", e.template)
+}
+
+func (e templateError) Unwrap() error {
+	return e.err
+}
+
+func (e templateError) stableMessage() string {
+	return fmt.Sprintf("Invalid template: %q", e.template)
+}
+
+// stabilizedError replaces the message of an error with its stable message,
+// while still unwrapping to the original error.
+type stabilizedError struct {
+	err stableError
+}
+
+func (e stabilizedError) Error() string {
+	return e.err.stableMessage()
+}
+
+func (e stabilizedError) Unwrap() error {
+	return e.err
+}
+
+// stabilize returns the error with its stable message, if it has one.
+// Errors from the mapping are returned unchanged.
+func stabilize(err error) error {
+	if s, ok := err.(stableError); ok {
+		return stabilizedError{s}
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, err := range joined.Unwrap() {
+			errs = append(errs, stabilize(err))
+		}
+		return errors.Join(errs...)
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var stableerrortests = []struct {
+	in   string
+	opts []Option
+	err  string
+}{
+	{"${unset", nil, "unexpected EOF while looking for matching `}'"},
+	{"${unset-'", nil, "unexpected EOF while looking for matching `''"},
+	{"${unset%x}", nil, "bad substitution"},
+	{"${unset:}", nil, "bad substitution"},
+	{"${unset^^}", nil, "bad substitution"},
+	{"${unset:?}", nil, "unset: parameter null or not set"},
+	{"${1:?}", nil, "1: parameter null or not set"},
+	{"${unset:?custom message}", nil, "custom message"},
+	{"${unset:=word}", nil, "unset: cannot assign in this way"},
+	{"${1:=word}", nil, "$1: cannot assign in this way"},
+	{"${unset:=word}", []Option{WithAssign(AssignError)}, "unset: readonly variable"},
+	{"$unset", []Option{WithNoUnset()}, "unset: unbound variable"},
+	{"$1", []Option{WithNoUnset()}, "$1: unbound variable"},
+	{"$empty", []Option{WithNoEmpty()}, "empty: parameter is empty"},
+	{"$empty", []Option{WithAllow(), WithDisallowError()}, "empty: variable not allowed"},
+	{"${unset:?}", []Option{WithDialect(Compose)}, "required variable unset is missing a value"},
+	{"${unset", []Option{WithDialect(Compose)}, `Invalid template: "${unset"`},
+}
+
+func TestExpand_stableErrors(t *testing.T) {
+	for _, tt := range stableerrortests {
+		e := NewExpander(append(tt.opts, WithStableErrors())...)
+		_, err := e.Expand(tt.in, Map{"empty": ""})
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("pattern %#v should have produced error %#v, but got: %s", tt.in, tt.err, err)
+		}
+	}
+
+	tmpl, err := NewExpander(WithStableErrors()).Parse("$a")
+	ok(t, err)
+	_, err = tmpl.Execute(Map{}, WithDialect(Bash))
+	equals(t, "Dialect: option can only be set when parsing", err.Error())
+}
+
+func TestExpand_stableErrorsUnwrap(t *testing.T) {
+	e := &Expander{StableErrors: true}
+	_, err := e.Expand("${unset:=word}", Map{})
+	var assign assignError
+	if !errors.As(err, &assign) {
+		t.Fatalf("stable error should unwrap to the original error, got %#v", err)
+	}
+	equals(t, "mapping type posix.Map does not support assignment", errors.Unwrap(err).Error())
+}
+
+type failingSetter struct{ Map }
+
+func (failingSetter) Set(k, v string) error {
+	return errors.New("read-only file system")
+}
+
+func TestExpand_stableErrorsMappingError(t *testing.T) {
+	e := &Expander{StableErrors: true}
+	_, err := e.Expand("${unset:=word}", failingSetter{})
+	equals(t, "read-only file system", err.Error())
+}
+
+var poserrortests = []struct {
+	in     string
+	pos    Pos
+	line   int
+	column int
+}{
+	{"abc ${unset", 4, 1, 5},
+	{"abc\ndef ${a:-${b", 13, 2, 10},
+	{"${a:-'x", 5, 1, 6},
+	{"${a.b}", 0, 1, 1},
+	{"x\n${a:%b}", 2, 2, 1},
+	{"${a:-$b} ${c:?}", 9, 1, 10},
+	{"${a}\n\n  ${b:=x}", 8, 3, 3},
+}
+
+func TestExpand_posError(t *testing.T) {
+	for _, tt := range poserrortests {
+		_, err := Expand(tt.in, Map{})
+		var posErr *PosError
+		if !errors.As(err, &posErr) {
+			t.Errorf("pattern %#v should have produced a PosError, but got: %#v", tt.in, err)
+			continue
+		}
+		equals(t, tt.pos, posErr.Pos)
+		equals(t, tt.line, posErr.Line)
+		equals(t, tt.column, posErr.Column)
+	}
+}
+
+func TestExpand_posErrorQuoteRemoval(t *testing.T) {
+	_, err := (&Expander{QuoteRemoval: true}).Expand(`a "b`, Map{})
+	var posErr *PosError
+	if !errors.As(err, &posErr) {
+		t.Fatalf("expected a PosError, got: %#v", err)
+	}
+	equals(t, Pos(2), posErr.Pos)
+}
+
+func TestExpand_posErrorStable(t *testing.T) {
+	e := &Expander{StableErrors: true}
+	_, err := e.Expand("${a:%b}", Map{})
+	equals(t, "bad substitution", err.Error())
+	var posErr *PosError
+	if !errors.As(err, &posErr) {
+		t.Fatalf("expected a PosError, got: %#v", err)
+	}
+}
+
+var expanderrortests = []struct {
+	in      string
+	mapping Getter
+	err     ExpandError
+}{
+	{"${unset:?}", Map{}, ExpandError{Name: "unset", Op: '?', Colon: true}},
+	{"${unset?is required}", Map{}, ExpandError{Name: "unset", Op: '?', Message: "is required"}},
+	{"${unset:?$set is required}", Map{"set": "x"}, ExpandError{Name: "unset", Op: '?', Colon: true, Message: "x is required"}},
+	{"${unset=word}", Map{}, ExpandError{Name: "unset", Op: '='}},
+	{"${1:=word}", Map{}, ExpandError{Name: "1", Op: '=', Colon: true}},
+	{"${unset:=word}", failingSetter{}, ExpandError{Name: "unset", Op: '=', Colon: true}},
+}
+
+func TestExpand_expandError(t *testing.T) {
+	for _, tt := range expanderrortests {
+		_, err := Expand(tt.in, tt.mapping)
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) {
+			t.Errorf("pattern %#v should have produced an ExpandError, but got: %#v", tt.in, err)
+			continue
+		}
+		equals(t, tt.err.Name, expandErr.Name)
+		equals(t, tt.err.Op, expandErr.Op)
+		equals(t, tt.err.Colon, expandErr.Colon)
+		equals(t, tt.err.Message, expandErr.Message)
+	}
+}
+
+func TestExpand_expandErrorMessage(t *testing.T) {
+	_, err := Expand("${unset:=word}", failingSetter{})
+	equals(t, "read-only file system", err.Error())
+}
+
+func TestExpand_allErrors(t *testing.T) {
+	e := &Expander{AllErrors: true}
+	out, err := e.Expand("a=${a:?} b=${b:?is required} c=$c d=${d:-${e:?}}", Map{"c": "C"})
+	equals(t, "a= b= c=C d=", out)
+	equals(t, "a: parameter null or not set\nis required\ne: parameter null or not set", err.Error())
+
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) {
+		t.Fatalf("expected an ExpandError, got: %#v", err)
+	}
+	equals(t, "a", expandErr.Name)
+}
+
+func TestExpand_allErrorsNone(t *testing.T) {
+	e := &Expander{AllErrors: true}
+	out, err := e.Expand("${a:-x}", Map{})
+	ok(t, err)
+	equals(t, "x", out)
+}
+
+func TestExpand_allErrorsSyntax(t *testing.T) {
+	e := &Expander{AllErrors: true}
+	_, err := e.Expand("${a:?} ${b", Map{})
+	equals(t, "unexpected EOF while looking for matching `}'", err.Error())
+}
+
+func TestExpand_allErrorsStable(t *testing.T) {
+	e := &Expander{AllErrors: true, StableErrors: true}
+	_, err := e.Expand("${a:=x} ${1:=x}", Map{})
+	equals(t, "a: cannot assign in this way\n$1: cannot assign in this way", err.Error())
+}
+
+func TestExpandFields_allErrors(t *testing.T) {
+	e := &Expander{AllErrors: true}
+	fields, err := e.ExpandFields("x ${a:?} ${b:?} y", Map{})
+	equals(t, []string{"x", "y"}, fields)
+	equals(t, "a: parameter null or not set\nb: parameter null or not set", err.Error())
+}
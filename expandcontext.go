@@ -0,0 +1,54 @@
+package posix
+
+import "context"
+
+// ContextGetter is an optional interface for mappings whose lookups may
+// block, such as adapters for remote secret stores. When expanding with
+// ExpandContext, variables are looked up with GetContext, so the lookups
+// can be cancelled and time out with the context. A lookup error stops the
+// expansion with an error wrapping it.
+type ContextGetter interface {
+	GetContext(ctx context.Context, name string) (value string, exists bool, err error)
+}
+
+// ExpandContext expands the string like Expand, looking up variables with
+// GetContext when the mapping implements ContextGetter. The expansion
+// stops with an error wrapping the context's error once it is done.
+func ExpandContext(ctx context.Context, s string, mapping Getter, opts ...Option) (string, error) {
+	return NewExpander(opts...).ExpandContext(ctx, s, mapping)
+}
+
+// ExpandContext expands the string like ExpandContext, using the options
+// set on the Expander.
+func (e *Expander) ExpandContext(ctx context.Context, s string, mapping Getter) (string, error) {
+	t, err := e.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.ExecuteContext(ctx, mapping)
+}
+
+// ExecuteContext expands the template like Execute, with the context for
+// lookups as for ExpandContext.
+func (t *Template) ExecuteContext(ctx context.Context, mapping Getter, opts ...Option) (string, error) {
+	return t.execute(ctx, mapping, opts)
+}
+
+// getContext looks up the variable in a wrapped mapping with GetContext
+// when it implements ContextGetter, or else like Lookup, so wrappers pass
+// the context through to the mapping.
+func getContext(ctx context.Context, mapping Getter, k string) (string, bool, error) {
+	if cg, ok := mapping.(ContextGetter); ok {
+		return cg.GetContext(ctx, k)
+	}
+	if eg, ok := mapping.(ErrGetter); ok {
+		return eg.Lookup(k)
+	}
+	v, ok := mapping.Get(k)
+	return v, ok, nil
+}
+
+// canceled reports whether the context of the expansion is done.
+func (ev *evaluator) canceled() bool {
+	return ev.ctx != nil && ev.ctx.Err() != nil
+}
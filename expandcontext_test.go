@@ -0,0 +1,63 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// secretStore is a ContextGetter that blocks until the context is done for
+// the name "SLOW".
+type secretStore map[string]string
+
+func (s secretStore) Get(k string) (string, bool) {
+	v, ok := s[k]
+	return v, ok
+}
+
+func (s secretStore) GetContext(ctx context.Context, k string) (string, bool, error) {
+	switch k {
+	case "SLOW":
+		<-ctx.Done()
+		return "", false, ctx.Err()
+	case "TENANT":
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant, tenant != "", nil
+	}
+	v, ok := s[k]
+	return v, ok, nil
+}
+
+func TestExpandContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	out, err := ExpandContext(ctx, "$TENANT:$DB_PASSWORD ${MISSING-unset}", secretStore{"DB_PASSWORD": "pw"})
+	ok(t, err)
+	equals(t, "acme:pw unset", out)
+
+	// without a context, lookups use Get
+	out, err = Expand("${TENANT-unset}", secretStore{})
+	ok(t, err)
+	equals(t, "unset", out)
+}
+
+func TestExpandContext_timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := ExpandContext(ctx, "$A ${SLOW}", secretStore{"A": "a"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline error, got: %#v", err)
+	}
+	equals(t, "context deadline exceeded", err.Error())
+}
+
+func TestExpandContext_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ExpandContext(ctx, "$A $B", Map{"A": "a"})
+	equals(t, true, errors.Is(err, context.Canceled))
+
+	// the expansion stops at the cancellation with AllErrors
+	_, err = ExpandContext(ctx, "$A $B", Map{"A": "a"}, WithAllErrors())
+	equals(t, "context canceled", err.Error())
+}
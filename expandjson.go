@@ -0,0 +1,123 @@
+package posix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExpandJSON expands the string values of a JSON document, such as
+// {"url": "http://${HOST:-localhost}:${PORT}"}, leaving object keys and
+// other values unchanged. The result is indented with two spaces, with
+// object keys in sorted order, so it is the same for equal documents.
+//
+// With the JSONTypes option, a string that is exactly one reference is
+// replaced by the JSON value of its expansion, so {"port": "${PORT}"} can
+// expand to {"port": 8080}. An error is prefixed with the dotted path of the
+// value, such as "servers.0.host".
+func ExpandJSON(data []byte, mapping Getter, opts ...Option) ([]byte, error) {
+	return NewExpander(opts...).ExpandJSON(data, mapping)
+}
+
+// ExpandJSON expands the string values of a JSON document like ExpandJSON,
+// using the options set on the Expander.
+func (e *Expander) ExpandJSON(data []byte, mapping Getter) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("json: unexpected data after the top-level value")
+		}
+		return nil, err
+	}
+	v, err := e.expandJSONValue(v, "", mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// expandJSONValue returns the decoded value with its strings expanded,
+// where path is the dotted path to the value for errors.
+func (e *Expander) expandJSONValue(v any, path string, mapping Getter) (any, error) {
+	switch x := v.(type) {
+	case map[string]any:
+		// in sorted order, so errors and assignments don't vary
+		for _, k := range sortedKeys(x) {
+			item, err := e.expandJSONValue(x[k], jsonPath(path, k), mapping)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = item
+		}
+	case []any:
+		for i, item := range x {
+			item, err := e.expandJSONValue(item, jsonPath(path, strconv.Itoa(i)), mapping)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = item
+		}
+	case string:
+		v, err := e.expandJSONString(x, mapping)
+		if err != nil && path != "" {
+			err = fmt.Errorf("%s: %w", path, err)
+		}
+		return v, err
+	}
+	return v, nil
+}
+
+// expandJSONString expands a string value, returning the JSON value of the
+// expansion instead with JSONTypes, if the string is a single reference.
+func (e *Expander) expandJSONString(s string, mapping Getter) (any, error) {
+	if e.literal(s) {
+		return s, nil
+	}
+	t, err := e.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	out, err := t.Execute(mapping)
+	if err != nil {
+		return nil, err
+	}
+	if !e.JSONTypes || len(t.Nodes) != 1 {
+		return out, nil
+	}
+	switch t.Nodes[0].(type) {
+	case *ParamNode, *ParamOpNode, *LengthNode:
+		if json.Valid([]byte(out)) {
+			d := json.NewDecoder(bytes.NewReader([]byte(out)))
+			d.UseNumber()
+			var v any
+			if d.Decode(&v) == nil {
+				return v, nil
+			}
+		}
+	}
+	return out, nil
+}
+
+// jsonPath appends the key to the dotted path.
+func jsonPath(path, k string) string {
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
@@ -0,0 +1,54 @@
+package posix
+
+import "testing"
+
+func TestExpandJSON(t *testing.T) {
+	mapping := Map{
+		"HOST":  "db",
+		"PORT":  "8080",
+		"DEBUG": "true",
+		"TAGS":  `["a", "b"]`,
+		"NAME":  "api",
+		"HTML":  "<b>&</b>",
+	}
+	cases := []struct {
+		in   string
+		out  string
+		opts []Option
+	}{
+		{`{"url": "http://${HOST}:$PORT", "$NAME": 1.50, "n": null}`,
+			"{\n  \"$NAME\": 1.50,\n  \"n\": null,\n  \"url\": \"http://db:8080\"\n}\n", nil},
+		{`["$PORT", "${DEBUG}", "$HTML", {"a": ["${MISSING:-x}"]}]`,
+			"[\n  \"8080\",\n  \"true\",\n  \"<b>&</b>\",\n  {\n    \"a\": [\n      \"x\"\n    ]\n  }\n]\n", nil},
+		{`{"port": "$PORT", "debug": "${DEBUG}", "tags": "$TAGS", "name": "$NAME", "url": "$HOST:$PORT"}`,
+			"{\n  \"debug\": true,\n  \"name\": \"api\",\n  \"port\": 8080,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ],\n  \"url\": \"db:8080\"\n}\n",
+			[]Option{WithJSONTypes()}},
+		{`{"n": "${MISSING:-12}", "len": "${#NAME}", "s": "${PORT}}"}`,
+			"{\n  \"len\": 3,\n  \"n\": 12,\n  \"s\": \"8080}\"\n}\n", []Option{WithJSONTypes()}},
+		{`["\"$PORT\""]`, "[\n  \"8080\"\n]\n", []Option{WithJSONTypes(), WithQuoteRemoval()}},
+		{`"$NAME"`, "\"api\"\n", nil},
+	}
+	for _, c := range cases {
+		out, err := ExpandJSON([]byte(c.in), mapping, c.opts...)
+		ok(t, err)
+		equals(t, c.out, string(out))
+	}
+}
+
+func TestExpandJSON_errors(t *testing.T) {
+	cases := []struct {
+		in  string
+		err string
+	}{
+		{`{"servers": [{"host": "a"}, {"host": "${HOST:?required}"}]}`, "servers.1.host: required"},
+		{`"${HOST:?required}"`, "required"},
+		{`{"a": "${"}`, "a: unexpected EOF while looking for matching `}'"},
+		{`{"a": }`, "invalid character '}' looking for beginning of value"},
+		{`{} {}`, "json: unexpected data after the top-level value"},
+		{`{} }`, "invalid character '}' looking for beginning of value"},
+	}
+	for _, c := range cases {
+		_, err := ExpandJSON([]byte(c.in), Map{})
+		equals(t, c.err, err.Error())
+	}
+}
@@ -0,0 +1,168 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ExpandMap expands each value of the map, where values may reference the
+// other keys of the map, such as {"HOME": "/home/me", "PATH": "$HOME/bin"}.
+// Values are expanded after the values they reference, and references to
+// names that are not in the map are resolved with mapping, which may be nil.
+// A reference cycle between values is reported as a CycleError.
+func ExpandMap(vars map[string]string, mapping Getter) (map[string]string, error) {
+	return new(Expander).ExpandMap(vars, mapping)
+}
+
+// ExpandMap expands the values of the map like ExpandMap, using the options
+// set on the Expander.
+func (e *Expander) ExpandMap(vars map[string]string, mapping Getter) (map[string]string, error) {
+	m := &mapExpander{
+		e:        e,
+		vars:     vars,
+		mapping:  mapping,
+		done:     make(map[string]string, len(vars)),
+		visiting: make(map[string]bool),
+	}
+	var errs []error
+	for _, k := range sortedKeys(vars) {
+		if err := m.resolve(k); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return m.done, nil
+}
+
+// CycleError reports values of a map that reference each other in a cycle.
+type CycleError struct {
+	// Cycle lists the keys in the cycle, starting and ending with the same
+	// key.
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "reference cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+type mapExpander struct {
+	e        *Expander
+	vars     map[string]string
+	mapping  Getter
+	done     map[string]string
+	failed   map[string]bool
+	visiting map[string]bool
+	stack    []string
+}
+
+// resolve expands the value of the key after the values it references.
+func (m *mapExpander) resolve(k string) error {
+	if _, ok := m.done[k]; ok || m.failed[k] {
+		return nil
+	}
+	if m.visiting[k] {
+		start := len(m.stack) - 1
+		for m.stack[start] != k {
+			start--
+		}
+		cycle := append(append([]string(nil), m.stack[start:]...), k)
+		return &CycleError{Cycle: cycle}
+	}
+
+	m.visiting[k] = true
+	m.stack = append(m.stack, k)
+	defer func() {
+		m.stack = m.stack[:len(m.stack)-1]
+		delete(m.visiting, k)
+	}()
+
+	t, err := m.e.Parse(m.vars[k])
+	if err != nil {
+		return m.fail(k, err)
+	}
+	for _, name := range t.Vars() {
+		if _, ok := m.vars[name]; ok {
+			if err := m.resolve(name); err != nil {
+				return m.fail(k, err)
+			}
+		}
+	}
+	v, err := t.Execute(mapGetter{m})
+	if err != nil {
+		return m.fail(k, err)
+	}
+	m.done[k] = v
+	return nil
+}
+
+// fail records that the key could not be expanded, so the error is only
+// reported once.
+func (m *mapExpander) fail(k string, err error) error {
+	if m.failed == nil {
+		m.failed = make(map[string]bool)
+	}
+	m.failed[k] = true
+	var cycle *CycleError
+	if errors.As(err, &cycle) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", k, err)
+}
+
+// mapGetter looks up the expanded values of the map, then the mapping,
+// passing lookup errors, special and positional parameters, and secrets
+// through to the mapping.
+type mapGetter struct {
+	m *mapExpander
+}
+
+func (g mapGetter) Get(k string) (string, bool) {
+	if v, ok := g.m.done[k]; ok {
+		return v, true
+	}
+	if g.m.mapping != nil {
+		return g.m.mapping.Get(k)
+	}
+	return "", false
+}
+
+func (g mapGetter) Lookup(k string) (string, bool, error) {
+	if v, ok := g.m.done[k]; ok || g.m.mapping == nil {
+		return v, ok, nil
+	}
+	return lookupIn(g.m.mapping, k)
+}
+
+func (g mapGetter) GetContext(ctx context.Context, k string) (string, bool, error) {
+	if v, ok := g.m.done[k]; ok || g.m.mapping == nil {
+		return v, ok, nil
+	}
+	return getContext(ctx, g.m.mapping, k)
+}
+
+func (g mapGetter) IsSecret(k string) bool {
+	return g.m.mapping != nil && isSecret(g.m.mapping, k)
+}
+
+func (g mapGetter) GetSpecial(k string) (string, bool) {
+	if g.m.mapping == nil {
+		return "", false
+	}
+	return getSpecial(g.m.mapping, k)
+}
+
+func (g mapGetter) Positional() []string {
+	args, _ := g.positional()
+	return args
+}
+
+func (g mapGetter) positional() ([]string, bool) {
+	if g.m.mapping == nil {
+		return nil, false
+	}
+	return positional(g.m.mapping)
+}
@@ -0,0 +1,65 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandMap(t *testing.T) {
+	out, err := ExpandMap(map[string]string{
+		"PATH":   "$BIN:$SBIN",
+		"BIN":    "$HOME/bin",
+		"SBIN":   "${PREFIX:-/usr}/sbin",
+		"HOME":   "/home/$USER",
+		"PLAIN":  "text",
+		"PREFIX": "",
+	}, Map{"USER": "me"})
+	ok(t, err)
+	equals(t, map[string]string{
+		"PATH":   "/home/me/bin:/usr/sbin",
+		"BIN":    "/home/me/bin",
+		"SBIN":   "/usr/sbin",
+		"HOME":   "/home/me",
+		"PLAIN":  "text",
+		"PREFIX": "",
+	}, out)
+}
+
+func TestExpandMap_nilMapping(t *testing.T) {
+	out, err := ExpandMap(map[string]string{"A": "$B-$C", "B": "b"}, nil)
+	ok(t, err)
+	equals(t, map[string]string{"A": "b-", "B": "b"}, out)
+}
+
+func TestExpandMap_cycle(t *testing.T) {
+	_, err := ExpandMap(map[string]string{
+		"A": "$B",
+		"B": "${C:-x}",
+		"C": "$A",
+		"D": "ok",
+	}, nil)
+	equals(t, "reference cycle: A -> B -> C -> A", err.Error())
+
+	var cycle *CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected a CycleError, got: %#v", err)
+	}
+	equals(t, []string{"A", "B", "C", "A"}, cycle.Cycle)
+}
+
+func TestExpandMap_selfReference(t *testing.T) {
+	_, err := ExpandMap(map[string]string{"PATH": "$PATH:/bin"}, Map{"PATH": "/usr/bin"})
+	equals(t, "reference cycle: PATH -> PATH", err.Error())
+}
+
+func TestExpandMap_errors(t *testing.T) {
+	_, err := ExpandMap(map[string]string{
+		"A": "${X:?required}",
+		"B": "$A",
+		"C": "${",
+	}, nil)
+	equals(t, "A: required\nC: unexpected EOF while looking for matching `}'", err.Error())
+
+	_, err = ExpandMap(map[string]string{"A": "$FAIL"}, remoteMap{})
+	equals(t, true, errors.Is(err, errLookup))
+}
@@ -0,0 +1,107 @@
+package posix
+
+import "strings"
+
+const defaultIFS = " \t\n"
+
+// ExpandFields expands the string like Expand, and then splits the result
+// into fields the way a shell splits the words of a command.
+//
+// Quotes and backslashes are interpreted throughout the string and removed
+// from the fields. Unquoted text is split on blanks, and the unquoted results
+// of expansions are split using the characters in IFS, which is looked up in
+// the mapping, or defaults to space, tab and newline. An empty IFS disables
+// splitting of expansions. Quoted text is never split, so "" produces an
+// empty field, while an unquoted expansion to an empty string produces none.
+func ExpandFields(s string, mapping Getter) ([]string, error) {
+	return new(Expander).ExpandFields(s, mapping)
+}
+
+// ExpandFields expands and splits the string into fields like ExpandFields,
+// using the options set on the Expander.
+func (e *Expander) ExpandFields(s string, mapping Getter) ([]string, error) {
+	nodes, src, err := parse(s, e, true)
+	if err != nil {
+		return nil, e.wrapError(err)
+	}
+	ev := &evaluator{mapping: mapping, opts: e, src: src}
+	segs, err := ev.evalAll(nodes)
+	if err != nil && !e.AllErrors {
+		return nil, e.wrapError(err)
+	}
+	return splitFields(segs, ev.ifs()), e.wrapError(err)
+}
+
+// ifs returns the field separators from the mapping or the options.
+func (ev *evaluator) ifs() string {
+	if ifs, ok := ev.mapping.Get("IFS"); ok {
+		return ifs
+	}
+	if ev.opts.IFS != "" {
+		return ev.opts.IFS
+	}
+	return defaultIFS
+}
+
+// splitFields splits the segments into fields, following the shell rules
+// that adjacent IFS whitespace forms a single separator, while each other
+// IFS character delimits a field, even an empty one.
+func splitFields(segs segments, ifs string) []string {
+	var (
+		fields []string
+		field  strings.Builder
+		// the current field has text or a quoted part
+		inField bool
+		// the previous field ended with whitespace, which may be part of a
+		// separator with a following IFS character
+		afterSpace bool
+	)
+
+	endField := func() {
+		fields = append(fields, field.String())
+		field.Reset()
+		inField = false
+	}
+
+	for _, seg := range segs {
+		if seg.fieldSep || seg.starSep && !seg.quoted {
+			if inField {
+				endField()
+			}
+			afterSpace = false
+			continue
+		}
+		if seg.quoted {
+			field.WriteString(seg.text)
+			inField = true
+			afterSpace = false
+			continue
+		}
+		seps := defaultIFS
+		if seg.expanded {
+			seps = ifs
+		}
+		for _, c := range seg.text {
+			switch {
+			case !strings.ContainsRune(seps, c):
+				field.WriteRune(c)
+				inField = true
+				afterSpace = false
+			case strings.ContainsRune(defaultIFS, c):
+				if inField {
+					endField()
+					afterSpace = true
+				}
+			case afterSpace && !inField:
+				afterSpace = false
+			default:
+				endField()
+			}
+		}
+	}
+	if inField {
+		endField()
+	}
+
+	return fields
+}
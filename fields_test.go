@@ -0,0 +1,93 @@
+package posix
+
+import "testing"
+
+var fieldtests = []struct {
+	in  string
+	ifs string
+	out []string
+}{
+	{"", "", nil},
+	{"a b  c", "", []string{"a", "b", "c"}},
+	{"  a\tb\n", "", []string{"a", "b"}},
+	{"$words", "", []string{"one", "two", "three"}},
+	{"x${words}y", "", []string{"xone", "two", "threey"}},
+	{`"$words"`, "", []string{"one two  three"}},
+	{`'$words'`, "", []string{"$words"}},
+	{`a\ b`, "", []string{"a b"}},
+	{`"a"'b'c`, "", []string{"abc"}},
+	{`"it's"`, "", []string{"it's"}},
+
+	// empty fields
+	{"$null", "", nil},
+	{`"$null"`, "", []string{""}},
+	{`'' ""`, "", []string{"", ""}},
+	{"a $null b", "", []string{"a", "b"}},
+
+	// nested words keep their own quoting
+	{"${unset:-a b}", "", []string{"a", "b"}},
+	{`${unset:-"a b"}`, "", []string{"a b"}},
+	{`"${unset:-a b}"`, "", []string{"a b"}},
+	{"${set:+a b}", "", []string{"a", "b"}},
+	{"${unset+a b}c", "", []string{"c"}},
+
+	// custom IFS
+	{"$path", ":", []string{"/bin", "/usr/bin", "", "/opt bin"}},
+	{"a:b $path", ":", []string{"a:b", "/bin", "/usr/bin", "", "/opt bin"}},
+	{"$spaced", " :", []string{"a", "b", "", "c"}},
+	{"$words", ":", []string{"one two  three"}},
+}
+
+func TestExpandFields(t *testing.T) {
+	for _, tt := range fieldtests {
+		mapping := Map{
+			"set":    "yes",
+			"null":   "",
+			"words":  "one two  three",
+			"path":   "/bin:/usr/bin::/opt bin",
+			"spaced": " a : b :: c ",
+		}
+		if tt.ifs != "" {
+			mapping["IFS"] = tt.ifs
+		}
+		x, err := ExpandFields(tt.in, mapping)
+		if err != nil {
+			t.Errorf("pattern %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if !equalStrings(x, tt.out) {
+			t.Errorf("pattern %#v should split into %#v, but got %#v", tt.in, tt.out, x)
+		}
+	}
+}
+
+func TestExpandFields_emptyIFS(t *testing.T) {
+	x, err := ExpandFields("a $words", Map{"IFS": "", "words": "b c"})
+	ok(t, err)
+	equals(t, []string{"a", "b c"}, x)
+}
+
+func TestExpandFields_optionIFS(t *testing.T) {
+	e := &Expander{IFS: ","}
+	x, err := e.ExpandFields("$list", Map{"list": "a,b c"})
+	ok(t, err)
+	equals(t, []string{"a", "b c"}, x)
+}
+
+func TestExpandFields_error(t *testing.T) {
+	_, err := ExpandFields(`"abc`, Map{})
+	equals(t, "unexpected EOF while looking for matching `\"'", err.Error())
+	_, err = ExpandFields("${unset:?missing}", Map{})
+	equals(t, "missing", err.Error())
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package posix
+
+import "flag"
+
+// Flags returns a Getter and Setter for the flags of a parsed FlagSet, so
+// ${out} expands to the value of the -out flag. Only flags that were set on
+// the command line are set, so ${out:-default} can substitute a default
+// that depends on other parameters. Assignments set the flag's value with
+// FlagSet.Set, and fail for flags that are not defined.
+//
+// Flag names that are not valid parameter names, such as "dry-run", cannot
+// be referenced.
+func Flags(fs *flag.FlagSet) Getter {
+	return flags{fs}
+}
+
+type flags struct {
+	fs *flag.FlagSet
+}
+
+func (f flags) Get(k string) (string, bool) {
+	set := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		set = set || fl.Name == k
+	})
+	if !set {
+		return "", false
+	}
+	return f.fs.Lookup(k).Value.String(), true
+}
+
+func (f flags) Set(k, v string) error {
+	return f.fs.Set(k, v)
+}
@@ -0,0 +1,30 @@
+package posix
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	name := fs.String("name", "report", "")
+	fs.String("dir", "out", "")
+	fs.Int("n", 1, "")
+	fs.Bool("v", false, "")
+	ok(t, fs.Parse([]string{"-dir", "/tmp", "-n", "3", "-v"}))
+
+	mapping := Flags(fs)
+	out, err := Expand("${dir}/${name:-default}-$n.${ext-txt} $v", mapping)
+	ok(t, err)
+	equals(t, "/tmp/default-3.txt true", out)
+
+	out, err = Expand("${name:=summary}", mapping)
+	ok(t, err)
+	equals(t, "summary", out)
+	equals(t, "summary", *name)
+
+	_, err = Expand("${n:=x}", Flags(flag.NewFlagSet("empty", flag.ContinueOnError)))
+	equals(t, "no such flag -n", err.Error())
+}
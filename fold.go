@@ -0,0 +1,60 @@
+package posix
+
+import (
+	"sort"
+	"strings"
+)
+
+// FoldGetter implements the Getter and Setter interfaces for variables
+// whose names are case-insensitive, like environment variables on Windows,
+// so $Path and $PATH refer to the same variable. A variable keeps the
+// spelling of its name from when it was first set.
+type FoldGetter struct {
+	vars map[string]foldVar
+}
+
+type foldVar struct {
+	name  string
+	value string
+}
+
+// NewFoldGetter returns a FoldGetter initialized from a list of
+// "key=value" strings, such as the result of os.Environ. When names differ
+// only in case, the last value is used.
+func NewFoldGetter(env []string) *FoldGetter {
+	f := &FoldGetter{vars: make(map[string]foldVar, len(env))}
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			f.Set(k, v)
+		}
+	}
+	return f
+}
+
+func (f *FoldGetter) Get(k string) (string, bool) {
+	v, ok := f.vars[strings.ToUpper(k)]
+	return v.value, ok
+}
+
+func (f *FoldGetter) Set(k, v string) error {
+	if f.vars == nil {
+		f.vars = make(map[string]foldVar)
+	}
+	key := strings.ToUpper(k)
+	if x, ok := f.vars[key]; ok {
+		k = x.name
+	}
+	f.vars[key] = foldVar{name: k, value: v}
+	return nil
+}
+
+// Environ returns the variables as "key=value" strings in sorted order,
+// using the spelling each name was first set with.
+func (f *FoldGetter) Environ() []string {
+	env := make([]string, 0, len(f.vars))
+	for _, v := range f.vars {
+		env = append(env, v.name+"="+v.value)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,36 @@
+package posix
+
+import "testing"
+
+func TestFoldGetter(t *testing.T) {
+	f := NewFoldGetter([]string{"Path=C:\\bin", "HOME=C:\\Users\\me", "home=C:\\Users\\other", "invalid"})
+
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"$Path", "C:\\bin"},
+		{"$PATH", "C:\\bin"},
+		{"${path}", "C:\\bin"},
+		{"$Home", "C:\\Users\\other"},
+		{"${MISSING-unset}", "unset"},
+	}
+	for _, c := range cases {
+		out, err := Expand(c.in, f)
+		ok(t, err)
+		equals(t, c.out, out)
+	}
+
+	_, err := Expand("${PATH:=x}${new:=y}${NEW:=z}", f)
+	ok(t, err)
+	equals(t, []string{"HOME=C:\\Users\\other", "Path=C:\\bin", "new=y"}, f.Environ())
+}
+
+func TestFoldGetter_zero(t *testing.T) {
+	var f FoldGetter
+	_, set := f.Get("A")
+	equals(t, false, set)
+	f.Set("a", "1")
+	v, _ := f.Get("A")
+	equals(t, "1", v)
+}
@@ -0,0 +1,149 @@
+package posix
+
+import (
+	"strings"
+)
+
+// String returns shell syntax that parses to an equivalent template.
+// Parameters are written as $name unless braces are needed, and text is
+// escaped as needed for where it appears.
+func (t *Template) String() string {
+	return t.format(false)
+}
+
+// Canonical returns the template in a normalized form, like String, except
+// that every parameter is written with braces, as ${name}.
+func (t *Template) Canonical() string {
+	return t.format(true)
+}
+
+func (t *Template) format(braces bool) string {
+	f, ctx := newFormatter(&t.opts, braces)
+	f.nodes(t.Nodes, ctx)
+	return f.buf.String()
+}
+
+// newFormatter returns a formatter for the syntax of the options, and the
+// context for the top level of a template.
+func newFormatter(opts *Expander, braces bool) (*formatter, formatContext) {
+	f := &formatter{braces: braces, dialect: opts.Dialect}
+	return f, formatContext{quoting: opts.QuoteRemoval && f.quotes()}
+}
+
+// quotes reports whether quotes and backslashes are interpreted by the
+// dialect.
+func (f *formatter) quotes() bool {
+	return f.dialect == POSIX || f.dialect == Bash
+}
+
+type formatter struct {
+	buf     strings.Builder
+	braces  bool
+	dialect Dialect
+}
+
+// Where text is being written, which determines how it must be escaped.
+type formatContext struct {
+	quoting bool // quotes and backslashes are interpreted
+	double  bool // within double-quotes
+	bracket bool // within the word of a ${name:-word} expansion
+}
+
+func (f *formatter) nodes(nodes []Node, ctx formatContext) {
+	for i, n := range nodes {
+		switch n := n.(type) {
+		case *TextNode:
+			f.text(n.Text, ctx)
+		case *QuotedNode:
+			f.quoted(n.Text, ctx)
+		case *DoubleQuotedNode:
+			inner := ctx
+			inner.double = true
+			f.buf.WriteByte('"')
+			f.nodes(n.Nodes, inner)
+			f.buf.WriteByte('"')
+		case *ParamNode:
+			if f.dialect == Kubernetes || f.dialect == Make {
+				f.buf.WriteString("$(" + n.Name + ")")
+			} else if f.dialect == Windows {
+				f.buf.WriteString("%" + n.Name + "%")
+			} else if f.braces || needsBraces(n.Name, nodes[i+1:]) {
+				f.buf.WriteString("${" + n.Name + "}")
+			} else {
+				f.buf.WriteString("$" + n.Name)
+			}
+		case *LengthNode:
+			f.buf.WriteString("${#" + n.Name + "}")
+		case *ParamOpNode:
+			f.buf.WriteString("${" + n.Name)
+			if n.Colon {
+				f.buf.WriteByte(':')
+			}
+			f.buf.WriteRune(n.Op)
+			inner := ctx
+			inner.quoting = f.quotes()
+			inner.bracket = true
+			f.nodes(n.Word, inner)
+			f.buf.WriteByte('}')
+		case *TildeNode:
+			f.buf.WriteString("~" + n.User)
+		}
+	}
+}
+
+// needsBraces reports whether the parameter must be written as ${name}
+// rather than $name, given the nodes that follow it.
+func needsBraces(name string, rest []Node) bool {
+	if len(name) > 1 && !isName(name) {
+		// positional parameters after $9
+		return true
+	}
+	if len(name) == 1 && !isAlpha(rune(name[0])) {
+		return false
+	}
+	if len(rest) > 0 {
+		if text, ok := rest[0].(*TextNode); ok && text.Text != "" {
+			return isAlphaNum(rune(text.Text[0]))
+		}
+	}
+	return false
+}
+
+// text writes literal text, escaping the characters that would otherwise be
+// interpreted.
+func (f *formatter) text(s string, ctx formatContext) {
+	for _, c := range s {
+		switch {
+		case c == '$' && (f.dialect == Kubernetes || f.dialect == Compose || f.dialect == Make):
+			f.buf.WriteString("$$")
+		case c == '%' && f.dialect == Windows:
+			f.buf.WriteString("%%")
+		case c == '$' && f.dialect == Windows:
+			f.buf.WriteRune(c)
+		case c == '$':
+			f.buf.WriteString(`\$`)
+		case c == '}' && ctx.bracket && ctx.double:
+			// a backslash does not escape a brace within double-quotes
+			f.buf.WriteString(`"\}"`)
+		case c == '}' && ctx.bracket:
+			f.buf.WriteString(`\}`)
+		case ctx.double && strings.ContainsRune("`\"\\", c):
+			f.buf.WriteByte('\\')
+			f.buf.WriteRune(c)
+		case ctx.quoting && !ctx.double && strings.ContainsRune(`'"\`, c):
+			f.buf.WriteByte('\\')
+			f.buf.WriteRune(c)
+		default:
+			f.buf.WriteRune(c)
+		}
+	}
+}
+
+// quoted writes text that is protected from field splitting.
+func (f *formatter) quoted(s string, ctx formatContext) {
+	if !ctx.quoting || ctx.double || s == "" {
+		f.text(s, ctx)
+		return
+	}
+	f.buf.WriteString("'" + strings.ReplaceAll(s, "'", `'\''`) + "'")
+}
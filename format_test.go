@@ -0,0 +1,67 @@
+package posix
+
+import "testing"
+
+var formattests = []struct {
+	in        string
+	out       string
+	canonical string
+}{
+	{"plain text", "plain text", "plain text"},
+	{"$a ${b}", "$a $b", "${a} ${b}"},
+	{"${a}b $a-b", "${a}b $a-b", "${a}b ${a}-b"},
+	{"$1 ${10} $@", "$1 ${10} $@", "${1} ${10} ${@}"},
+	{"${#a}", "${#a}", "${#a}"},
+	{"${a:-$b}", "${a:-$b}", "${a:-${b}}"},
+	{"${a+x}", "${a+x}", "${a+x}"},
+	{"${a:-'x y'}", "${a:-'x y'}", "${a:-'x y'}"},
+	{`${a:-"$b"}`, `${a:-"$b"}`, `${a:-"${b}"}`},
+	{`${a:-\}}`, `${a:-'}'}`, `${a:-'}'}`},
+	{`\$a`, `\$a`, `\$a`},
+	{`'$a' "b"`, `'$a' "b"`, `'${a}' "b"`},
+}
+
+func TestTemplate_String(t *testing.T) {
+	for _, test := range formattests {
+		tmpl, err := Parse(test.in)
+		ok(t, err)
+		equals(t, test.out, tmpl.String())
+		equals(t, test.canonical, tmpl.Canonical())
+
+		// the formatted template parses to an equivalent template
+		again, err := Parse(tmpl.String())
+		ok(t, err)
+		equals(t, tmpl.String(), again.String())
+	}
+}
+
+func TestTemplate_StringQuoteRemoval(t *testing.T) {
+	e := &Expander{QuoteRemoval: true}
+	for _, in := range []string{
+		`'a b' "c $d" \'`,
+		`"${a:-x}" "\"\$\\"`,
+		`'it'\''s'`,
+	} {
+		tmpl, err := e.Parse(in)
+		ok(t, err)
+		again, err := e.Parse(tmpl.String())
+		ok(t, err)
+		out, err := tmpl.Execute(Map{"d": "D"})
+		ok(t, err)
+		outAgain, err := again.Execute(Map{"d": "D"})
+		ok(t, err)
+		equals(t, out, outAgain)
+	}
+}
+
+func TestTemplate_StringRewritten(t *testing.T) {
+	tmpl, err := Parse("$host:$port")
+	ok(t, err)
+	inlined := tmpl.Rewrite(func(n Node) []Node {
+		if p, isParam := n.(*ParamNode); isParam && p.Name == "host" {
+			return []Node{&TextNode{"$HOME"}}
+		}
+		return []Node{n}
+	})
+	equals(t, `\$HOME:$port`, inlined.String())
+}
@@ -0,0 +1,77 @@
+package posix
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+)
+
+// ExpandFS returns a filesystem whose file contents are expanded like Expand
+// when they are opened. Directories are returned unchanged. The files
+// support io.Seeker and io.ReaderAt, and Stat reports the size of the
+// expanded contents, so the filesystem can be served with http.FileServer.
+// Directory listings are not expanded, so the sizes of their entries are the
+// sizes of the files before expansion.
+//
+// An error expanding a file is returned by Open as an *fs.PathError.
+func ExpandFS(fsys fs.FS, mapping Getter) fs.FS {
+	return new(Expander).ExpandFS(fsys, mapping)
+}
+
+// ExpandFS returns a filesystem that expands file contents like ExpandFS,
+// using the options set on the Expander.
+func (e *Expander) ExpandFS(fsys fs.FS, mapping Getter) fs.FS {
+	return &expandFS{fsys: fsys, e: e, mapping: mapping}
+}
+
+type expandFS struct {
+	fsys    fs.FS
+	e       *Expander
+	mapping Getter
+}
+
+func (f *expandFS) Open(name string) (fs.File, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return file, nil
+	}
+
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	out, err := f.e.ExpandBytes(data, f.mapping)
+	if err != nil {
+		return nil, &fs.PathError{Op: "expand", Path: name, Err: err}
+	}
+	return &expandedFile{
+		Reader: bytes.NewReader(out),
+		info:   expandedInfo{FileInfo: info, size: int64(len(out))},
+	}, nil
+}
+
+// expandedFile is an open file with its expanded contents.
+type expandedFile struct {
+	*bytes.Reader
+	info expandedInfo
+}
+
+func (f *expandedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *expandedFile) Close() error               { return nil }
+
+// expandedInfo reports the size of the expanded contents of a file.
+type expandedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i expandedInfo) Size() int64 { return i.size }
@@ -0,0 +1,68 @@
+package posix
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExpandFS(t *testing.T) {
+	fsys := ExpandFS(fstest.MapFS{
+		"config.ini":    {Data: []byte("home=$HOME\nname=${NAME:-default}\n")},
+		"sub/plain.txt": {Data: []byte("no vars")},
+	}, Map{"HOME": "/home/me"})
+
+	data, err := fs.ReadFile(fsys, "config.ini")
+	ok(t, err)
+	equals(t, "home=/home/me\nname=default\n", string(data))
+
+	info, err := fs.Stat(fsys, "config.ini")
+	ok(t, err)
+	equals(t, int64(len(data)), info.Size())
+	equals(t, "config.ini", info.Name())
+
+	entries, err := fs.ReadDir(fsys, "sub")
+	ok(t, err)
+	equals(t, 1, len(entries))
+	equals(t, "plain.txt", entries[0].Name())
+}
+
+func TestExpandFS_seek(t *testing.T) {
+	fsys := ExpandFS(fstest.MapFS{"a.txt": {Data: []byte("a=$A")}}, Map{"A": "value"})
+	f, err := fsys.Open("a.txt")
+	ok(t, err)
+	defer f.Close()
+
+	seeker := f.(io.ReadSeeker)
+	_, err = seeker.Seek(2, io.SeekStart)
+	ok(t, err)
+	rest, err := io.ReadAll(seeker)
+	ok(t, err)
+	equals(t, "value", string(rest))
+}
+
+func TestExpandFS_error(t *testing.T) {
+	fsys := ExpandFS(fstest.MapFS{"bad.txt": {Data: []byte("${A")}}, Map{})
+	_, err := fsys.Open("bad.txt")
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected a PathError, got: %#v", err)
+	}
+	equals(t, "expand bad.txt: unexpected EOF while looking for matching `}'", err.Error())
+
+	_, err = fsys.Open("missing.txt")
+	equals(t, true, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestExpandFS_http(t *testing.T) {
+	fsys := ExpandFS(fstest.MapFS{"page.html": {Data: []byte("<p>$GREETING</p>")}}, Map{"GREETING": "hello"})
+	rec := httptest.NewRecorder()
+	http.FileServer(http.FS(fsys)).ServeHTTP(rec, httptest.NewRequest("GET", "/page.html", nil))
+	equals(t, 200, rec.Code)
+	equals(t, "<p>hello</p>", rec.Body.String())
+	equals(t, "12", rec.Header().Get("Content-Length"))
+}
@@ -0,0 +1,121 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mgood/go-posix/corpus"
+)
+
+// fuzzTimeout is how long a single input may take before the fuzz targets
+// report it as a hang.
+const fuzzTimeout = time.Second
+
+// fuzzSeeds adds the inputs from the corpus as seeds for the fuzz target.
+func fuzzSeeds(f *testing.F) {
+	cases, err := corpus.Load()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, c := range cases {
+		f.Add(c.Input, false)
+		f.Add(c.Input, true)
+	}
+}
+
+func fuzzExpander(bash bool) *Expander {
+	e := &Expander{MaxOutput: 1 << 16}
+	if bash {
+		e.Dialect = Bash
+	}
+	return e
+}
+
+func fuzzMapping() Getter {
+	return RWMap{"HOME": "/home/me", "USER": "me", "EMPTY": "", "IFS": " \t\n"}
+}
+
+// checkError fails if the error does not have a message, or its position is
+// outside the input as lexed, after any brace expansion.
+func checkError(t *testing.T, s string, bash bool, err error) {
+	t.Helper()
+	lexed := s
+	if bash {
+		lexed, _ = expandBraceWords(s, false, 0)
+	}
+	if err.Error() == "" {
+		t.Errorf("%q: error %#v has an empty message", s, err)
+	}
+	var perr *PosError
+	if errors.As(err, &perr) {
+		if perr.Pos < 0 || int(perr.Pos) > len(lexed) || perr.Line < 1 || perr.Column < 1 {
+			t.Errorf("%q: error %q has an invalid position %+v", s, err, perr)
+		}
+	}
+}
+
+// withTimeout runs fn, failing if it doesn't return within fuzzTimeout.
+func withTimeout(t *testing.T, s string, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(fuzzTimeout):
+		t.Fatalf("%q: did not finish within %s", s, fuzzTimeout)
+	}
+}
+
+func FuzzExpand(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, s string, bash bool) {
+		var err error
+		withTimeout(t, s, func() {
+			_, err = fuzzExpander(bash).Expand(s, fuzzMapping())
+		})
+		if err != nil {
+			checkError(t, s, bash, err)
+		}
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, s string, bash bool) {
+		e := fuzzExpander(bash)
+		var (
+			tmpl            *Template
+			parseErr        error
+			want, got       string
+			wantErr, gotErr error
+		)
+		withTimeout(t, s, func() {
+			tmpl, parseErr = e.Parse(s)
+			want, wantErr = e.Expand(s, fuzzMapping())
+			if parseErr == nil {
+				got, gotErr = tmpl.Execute(fuzzMapping())
+			}
+		})
+		if parseErr != nil {
+			checkError(t, s, bash, parseErr)
+			// a syntax error is reported the same way by Expand
+			if wantErr == nil || wantErr.Error() != parseErr.Error() {
+				t.Fatalf("%q: Parse failed with %q, but Expand returned %q, %v", s, parseErr, want, wantErr)
+			}
+			return
+		}
+		if (gotErr == nil) != (wantErr == nil) || got != want {
+			t.Fatalf("%q: Execute returned %q, %v, but Expand returned %q, %v", s, got, gotErr, want, wantErr)
+		}
+		if gotErr != nil {
+			checkError(t, s, bash, gotErr)
+			if gotErr.Error() != wantErr.Error() {
+				t.Fatalf("%q: Execute failed with %q, but Expand failed with %q", s, gotErr, wantErr)
+			}
+		}
+	})
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package posix
+
+import (
+	"strings"
+	"syscall"
+)
+
+// getenv looks up an environment variable.
+func getenv(k string) (string, bool) {
+	return syscall.Getenv(k)
+}
+
+// environGetter returns a Getter for a list of "key=value" strings.
+func environGetter(env []string) Getter {
+	m := make(Map, len(env))
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			if _, dup := m[k]; !dup {
+				m[k] = v
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package posix
+
+import "syscall"
+
+// getenv looks up an environment variable. Names are already
+// case-insensitive on Windows.
+func getenv(k string) (string, bool) {
+	return syscall.Getenv(k)
+}
+
+// environGetter returns a Getter for a list of "key=value" strings, with
+// case-insensitive names.
+func environGetter(env []string) Getter {
+	return NewFoldGetter(env)
+}
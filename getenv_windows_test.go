@@ -0,0 +1,15 @@
+//go:build windows
+
+package posix
+
+import "testing"
+
+func TestGetenv_caseInsensitive(t *testing.T) {
+	t.Setenv("POSIX_TEST_VAR", "x")
+	v, ok := getenv("posix_Test_var")
+	equals(t, true, ok)
+	equals(t, "x", v)
+
+	_, ok = getenv("POSIX_TEST_UNSET")
+	equals(t, false, ok)
+}
@@ -0,0 +1,134 @@
+package posix
+
+import (
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs returns a template.FuncMap with a "posix" function that
+// expands its argument against the mapping, so a text/template can use
+// POSIX-style defaults such as {{ posix "${PORT:-8080}" }}.
+func TemplateFuncs(mapping Getter) template.FuncMap {
+	return new(Expander).TemplateFuncs(mapping)
+}
+
+// TemplateFuncs returns a template.FuncMap like TemplateFuncs, expanding
+// with the options set on the Expander.
+func (e *Expander) TemplateFuncs(mapping Getter) template.FuncMap {
+	return template.FuncMap{
+		"posix": func(s string) (string, error) {
+			return e.Expand(s, mapping)
+		},
+	}
+}
+
+// PreprocessTemplate rewrites each expansion in the text of a text/template
+// into a call to the "posix" function of TemplateFuncs, so ${VAR:-default}
+// can be written directly alongside {{ }} actions:
+//
+//	listen {{ .Host }}:${PORT:-8080}
+//
+// becomes
+//
+//	listen {{ .Host }}:{{posix "${PORT:-8080}"}}
+//
+// The actions are copied unchanged, so template variables such as $x are
+// not expanded. An expansion can't contain an action, and the template
+// must use the default delimiters.
+func PreprocessTemplate(text string) (string, error) {
+	return new(Expander).PreprocessTemplate(text)
+}
+
+// PreprocessTemplate rewrites the text like PreprocessTemplate, parsing
+// the expansions with the options set on the Expander, which should match
+// the Expander of TemplateFuncs.
+func (e *Expander) PreprocessTemplate(text string) (string, error) {
+	var b strings.Builder
+	for text != "" {
+		i := strings.Index(text, "{{")
+		if i < 0 {
+			i = len(text)
+		}
+		if err := e.preprocessText(&b, text[:i]); err != nil {
+			return "", err
+		}
+		text = text[i:]
+		n := actionLen(text)
+		b.WriteString(text[:n])
+		text = text[n:]
+	}
+	return b.String(), nil
+}
+
+// preprocessText writes the text between actions with each expansion
+// replaced by a call to the "posix" function.
+func (e *Expander) preprocessText(b *strings.Builder, s string) error {
+	if e.literal(s) {
+		b.WriteString(s)
+		return nil
+	}
+	t, err := e.Parse(s)
+	if err != nil {
+		return err
+	}
+	var lit strings.Builder
+	for _, n := range t.Nodes {
+		switch n := n.(type) {
+		case *TextNode:
+			lit.WriteString(n.Text)
+		case *QuotedNode:
+			lit.WriteString(n.Text)
+		default:
+			writeTemplateText(b, lit.String())
+			lit.Reset()
+			f, ctx := newFormatter(e, false)
+			f.nodes([]Node{n}, ctx)
+			b.WriteString("{{posix " + strconv.Quote(f.buf.String()) + "}}")
+		}
+	}
+	writeTemplateText(b, lit.String())
+	return nil
+}
+
+// writeTemplateText writes literal text that is followed by an action, so
+// neither a "{{" in the text nor a "{" at its end can start an action.
+func writeTemplateText(b *strings.Builder, s string) {
+	s = strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+	if strings.HasSuffix(s, "{") {
+		s = s[:len(s)-1] + `{{"{"}}`
+	}
+	b.WriteString(s)
+}
+
+// actionLen returns the length of the text/template action at the start of
+// s, skipping over strings and comments that may contain "}}", or len(s) if
+// it is not closed, leaving text/template to report the error.
+func actionLen(s string) int {
+	for i := 2; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "}}"):
+			return i + 2
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return len(s)
+			}
+			i += 2 + end + 1
+		case s[i] == '`':
+			end := strings.IndexByte(s[i+1:], '`')
+			if end < 0 {
+				return len(s)
+			}
+			i += 1 + end
+		case s[i] == '"' || s[i] == '\'':
+			q := s[i]
+			for i++; i < len(s) && s[i] != q; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return len(s)
+}
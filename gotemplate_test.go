@@ -0,0 +1,55 @@
+package posix
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+var preprocesstests = []struct {
+	in, out string
+}{
+	{"plain {{ .Host }}", "plain {{ .Host }}"},
+	{"{{ .Host }}:${PORT:-8080}", `{{ .Host }}:{{posix "${PORT:-8080}"}}`},
+	{"$HOME/bin", `{{posix "$HOME"}}/bin`},
+	{"{{ $x := .Host }}{{ $x }} $USER", `{{ $x := .Host }}{{ $x }} {{posix "$USER"}}`},
+	{`{{ "}}$X" }}$X`, `{{ "}}$X" }}{{posix "$X"}}`},
+	{"{{/* $X }} */}}{{`$Y}}`}}", "{{/* $X }} */}}{{`$Y}}`}}"},
+	{`\$HOME \{ {$X`, `$HOME \{ {{"{"}}{{posix "$X"}}`},
+	{"${A:+\"{x\"}", `{{posix "${A:+\"{x\"}"}}`},
+	{"{{ .Unclosed $X", "{{ .Unclosed $X"},
+}
+
+func TestPreprocessTemplate(t *testing.T) {
+	for _, tt := range preprocesstests {
+		out, err := PreprocessTemplate(tt.in)
+		ok(t, err)
+		equals(t, tt.out, out)
+	}
+
+	_, err := PreprocessTemplate("${X:-{{ .Default }}}")
+	equals(t, "unexpected EOF while looking for matching `}'", err.Error())
+}
+
+func TestPreprocessTemplate_quoteRemoval(t *testing.T) {
+	e := NewExpander(WithQuoteRemoval())
+	out, err := e.PreprocessTemplate(`'{'{{ .X }} "$A" \{\{`)
+	ok(t, err)
+	equals(t, `{{"{"}}{{ .X }} {{posix "\"$A\""}} {{"{{"}}`, out)
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	mapping := RWMap{"USER": "me"}
+	text, err := PreprocessTemplate("{{ .Greeting }} ${USER}, port ${PORT:=8080} {{ posix \"$PORT\" }}")
+	ok(t, err)
+	tmpl, err := template.New("").Funcs(TemplateFuncs(mapping)).Parse(text)
+	ok(t, err)
+
+	var b strings.Builder
+	ok(t, tmpl.Execute(&b, map[string]string{"Greeting": "hello"}))
+	equals(t, "hello me, port 8080 8080", b.String())
+
+	tmpl = template.Must(template.New("").Funcs(TemplateFuncs(mapping)).Parse(`{{ posix "${MISSING:?is required}" }}`))
+	err = tmpl.Execute(&b, nil)
+	equals(t, true, err != nil && strings.Contains(err.Error(), "is required"))
+}
@@ -0,0 +1,103 @@
+package posix
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Graph is the dependency graph of a set of named templates. Each name maps
+// to the sorted names of the other templates in the set that it references.
+type Graph map[string][]string
+
+// Dependencies returns the dependency graph of the named templates, where a
+// template depends on each template in the set whose name it references.
+// References to names that are not in the set are not included.
+func Dependencies(templates map[string]string) (Graph, error) {
+	return new(Expander).Dependencies(templates)
+}
+
+// Dependencies returns the dependency graph like Dependencies, parsing the
+// templates with the options set on the Expander.
+func (e *Expander) Dependencies(templates map[string]string) (Graph, error) {
+	g := make(Graph, len(templates))
+	var errs []error
+	for _, k := range sortedKeys(templates) {
+		t, err := e.Parse(templates[k])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", k, err))
+			continue
+		}
+		deps := []string{}
+		for _, name := range t.Vars() {
+			if _, ok := templates[name]; ok {
+				deps = append(deps, name)
+			}
+		}
+		sort.Strings(deps)
+		g[k] = deps
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return g, nil
+}
+
+// Order returns the names in the graph in topological order, with each name
+// after the names it depends on. Names that do not depend on each other are
+// in sorted order. A cycle in the graph is reported as a CycleError.
+func (g Graph) Order() ([]string, error) {
+	s := &graphSorter{g: g, state: make(map[string]int, len(g))}
+	for _, k := range sortedKeys(g) {
+		if err := s.visit(k); err != nil {
+			return nil, err
+		}
+	}
+	return s.order, nil
+}
+
+const (
+	unvisited = iota
+	visiting
+	visited
+)
+
+type graphSorter struct {
+	g     Graph
+	state map[string]int
+	stack []string
+	order []string
+}
+
+func (s *graphSorter) visit(k string) error {
+	switch s.state[k] {
+	case visited:
+		return nil
+	case visiting:
+		start := len(s.stack) - 1
+		for s.stack[start] != k {
+			start--
+		}
+		return &CycleError{Cycle: append(append([]string(nil), s.stack[start:]...), k)}
+	}
+	s.state[k] = visiting
+	s.stack = append(s.stack, k)
+	for _, dep := range s.g[k] {
+		if err := s.visit(dep); err != nil {
+			return err
+		}
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+	s.state[k] = visited
+	s.order = append(s.order, k)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
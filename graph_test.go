@@ -0,0 +1,49 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	g, err := Dependencies(map[string]string{
+		"PATH":   "$BIN:${SBIN}:$EXTERNAL",
+		"BIN":    "$HOME/bin",
+		"SBIN":   "${PREFIX:-/usr}/sbin",
+		"HOME":   "/home/$USER",
+		"PREFIX": "",
+	})
+	ok(t, err)
+	equals(t, Graph{
+		"PATH":   {"BIN", "SBIN"},
+		"BIN":    {"HOME"},
+		"SBIN":   {"PREFIX"},
+		"HOME":   {},
+		"PREFIX": {},
+	}, g)
+
+	order, err := g.Order()
+	ok(t, err)
+	equals(t, []string{"HOME", "BIN", "PREFIX", "SBIN", "PATH"}, order)
+}
+
+func TestDependencies_parseError(t *testing.T) {
+	_, err := Dependencies(map[string]string{"A": "${", "B": "$A", "C": "${C"})
+	equals(t, "A: unexpected EOF while looking for matching `}'\nC: unexpected EOF while looking for matching `}'", err.Error())
+}
+
+func TestGraphOrder_cycle(t *testing.T) {
+	g, err := Dependencies(map[string]string{
+		"A": "$B",
+		"B": "$C",
+		"C": "$A",
+		"D": "$A",
+	})
+	ok(t, err)
+	_, err = g.Order()
+	var cycle *CycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected a CycleError, got: %#v", err)
+	}
+	equals(t, "reference cycle: A -> B -> C -> A", err.Error())
+}
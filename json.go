@@ -0,0 +1,69 @@
+package posix
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// JSONGetter returns a Getter for a JSON object, where dotted names like
+// ${server.port} look up nested values, and numeric parts index arrays, as
+// in ${servers.0.host}. Dotted names are only recognized with the
+// DottedNames option.
+//
+// Strings expand to their value, and numbers and booleans to their JSON
+// form. Objects and arrays expand to their compact JSON encoding. Null and
+// missing values are unset, as a nil value is in a NestedMap.
+func JSONGetter(data []byte) (Getter, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var obj map[string]any
+	if err := d.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return JSONObject(obj), nil
+}
+
+// JSONObject returns a Getter for a decoded JSON object, like JSONGetter.
+func JSONObject(obj map[string]any) Getter {
+	return jsonObject(obj)
+}
+
+type jsonObject map[string]any
+
+func (o jsonObject) Get(k string) (string, bool) {
+	var v any = map[string]any(o)
+	for _, part := range strings.Split(k, ".") {
+		switch x := v.(type) {
+		case map[string]any:
+			v = x[part]
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(x) {
+				return "", false
+			}
+			v = x[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return x, true
+	case json.Number:
+		return x.String(), true
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(x), true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
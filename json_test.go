@@ -0,0 +1,85 @@
+package posix
+
+import "testing"
+
+func TestJSONGetter(t *testing.T) {
+	mapping, err := JSONGetter([]byte(`{
+		"name": "api",
+		"server": {"host": "localhost", "port": 8080, "tls": false, "ratio": 0.5},
+		"servers": [{"host": "a"}, {"host": "b"}],
+		"big": 12345678901234567890,
+		"nothing": null,
+		"tags": ["x", "y"]
+	}`))
+	ok(t, err)
+
+	cases := []struct {
+		in  string
+		out string
+	}{
+		{"$name", "api"},
+		{"${server.host}:${server.port}", "localhost:8080"},
+		{"${server.tls} ${server.ratio}", "false 0.5"},
+		{"${servers.1.host}", "b"},
+		{"$big", "12345678901234567890"},
+		{"$tags ${tags.0}", `["x","y"] x`},
+		{"${nothing-unset} ${server.missing-unset} ${servers.2.host-unset}", "unset unset unset"},
+		{"${name.x-unset} ${tags.x-unset}", "unset unset"},
+		{"${server.port:+set}", "set"},
+		{"$server.port", `{"host":"localhost","port":8080,"ratio":0.5,"tls":false}.port`},
+	}
+	for _, c := range cases {
+		out, err := Expand(c.in, mapping, WithDottedNames())
+		ok(t, err)
+		equals(t, c.out, out)
+	}
+
+	_, err = JSONGetter([]byte(`[1, 2]`))
+	equals(t, "json: cannot unmarshal array into Go value of type map[string]interface {}", err.Error())
+}
+
+func TestJSONObject(t *testing.T) {
+	mapping := JSONObject(map[string]any{"a": map[string]any{"n": 1.5, "i": float64(3)}})
+	out, err := Expand("${a.n} ${a.i}", mapping, WithDottedNames())
+	ok(t, err)
+	equals(t, "1.5 3", out)
+}
+
+func TestDottedNames(t *testing.T) {
+	mapping := Map{"a.b": "x"}
+
+	_, err := Expand("${a.b}", mapping)
+	equals(t, "${a.b}: bad substitution", err.Error())
+
+	cases := []struct {
+		in  string
+		out string
+		err string
+	}{
+		{in: "${a.b} ${#a.b} ${a.c-d}", out: "x 1 d"},
+		{in: "${a.0.c_1-ok}", out: "ok"},
+		{in: "${a..b}", err: "${a..b}: bad substitution"},
+		{in: "${a.}", err: "${a.}: bad substitution"},
+		{in: "${0.a}", err: "${0.a}: bad substitution"},
+		{in: "${a.b-c}", out: "x"},
+	}
+	for _, c := range cases {
+		out, err := Expand(c.in, mapping, WithDottedNames())
+		if c.err != "" {
+			equals(t, c.err, err.Error())
+			continue
+		}
+		ok(t, err)
+		equals(t, c.out, out)
+	}
+
+	out, err := Expand("${a.b} ${x.y}", mapping, WithDottedNames(), WithKeepUnset())
+	ok(t, err)
+	equals(t, "x ${x.y}", out)
+
+	rw := RWMap{}
+	out, err = Expand("${a.b:=v}", rw, WithDottedNames())
+	ok(t, err)
+	equals(t, "v", out)
+	equals(t, RWMap{"a.b": "v"}, rw)
+}
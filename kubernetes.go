@@ -0,0 +1,59 @@
+package posix
+
+import (
+	"bytes"
+	"strings"
+)
+
+// lexKubernetes lexes the input for the Kubernetes dialect, where the only
+// expansions are $(name) references and $$ escapes. Like Kubernetes, an
+// unterminated "$(" and any other "$" are literal text.
+func lexKubernetes(l *lexer) stateFn {
+	for {
+		i := strings.IndexByte(l.input[l.pos:], '$')
+		if i < 0 || int(l.pos)+i+1 >= len(l.input) {
+			l.pos = Pos(len(l.input))
+			l.emitText()
+			return nil
+		}
+		l.pos += Pos(i)
+		rest := l.input[l.pos+1:]
+		switch {
+		case rest[0] == '$':
+			l.emitText()
+			l.pos += 2
+			l.emit(itemQuotedText("$"))
+			l.ignore()
+			continue
+		case rest[0] == '(':
+			if end := strings.IndexByte(rest, ')'); end >= 0 {
+				l.emitText()
+				l.exprStart = l.pos
+				l.emit(itemReadParam(rest[1:end]))
+				l.pos += Pos(end) + 2
+				l.ignore()
+				continue
+			}
+		}
+		l.pos++
+	}
+}
+
+// parenRefEnd returns the length of the $(name) reference or $$ escape at
+// the start of the input, 1 if the "$" does not start either, or -1 if it
+// may continue past the end of the input.
+func parenRefEnd(in []byte) int {
+	if len(in) < 2 {
+		return -1
+	}
+	switch in[1] {
+	case '$':
+		return 2
+	case '(':
+		if end := bytes.IndexByte(in, ')'); end >= 0 {
+			return end + 1
+		}
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,30 @@
+package posix
+
+import "testing"
+
+var kubernetestests = []dialecttest{
+	{"", ""},
+	{"plain", "plain"},
+	{"$(HOST):$(PORT)", "example.com:8080"},
+	{"$(UNSET) and $(EMPTY).", "$(UNSET) and ."},
+	{"$$(HOST) $$$(HOST) $$$$", "$(HOST) $example.com $$"},
+	{"$HOST ${HOST} $ $", "$HOST ${HOST} $ $"},
+	{"$(HOST", "$(HOST"},
+	{"$(HOST$(PORT)", "$(HOST$(PORT)"},
+	{"x$(HOST)$", "xexample.com$"},
+	{"$()", "$()"},
+	{`\$(HOST) '$(HOST)' "$(HOST)" ~`, `\example.com 'example.com' "example.com" ~`},
+	{"$(with space)", "spaced"},
+}
+
+func TestExpand_kubernetes(t *testing.T) {
+	mapping := Map{"HOST": "example.com", "PORT": "8080", "EMPTY": "", "with space": "spaced"}
+	testDialect(t, Kubernetes, mapping, kubernetestests)
+}
+
+func TestTemplate_kubernetesString(t *testing.T) {
+	tmpl, err := NewExpander(WithDialect(Kubernetes)).Parse("$(A)$B $$(C) $")
+	ok(t, err)
+	equals(t, "$(A)$$B $$(C) $$", tmpl.String())
+	equals(t, []string{"A"}, tmpl.Vars())
+}
@@ -0,0 +1,76 @@
+package posix
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// noLeaks fails the test if fn leaves more goroutines running than there
+// were before it was called.
+func noLeaks(t *testing.T, fn func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	fn()
+	for i := 0; i < 50 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("leaked %d goroutines:\n%s", after-before, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+type panicGetter struct{}
+
+func (panicGetter) Get(string) (string, bool) {
+	panic("lookup failed")
+}
+
+func TestNoLeaks(t *testing.T) {
+	inputs := []string{
+		"${A:?required} ${B} ${C}",
+		"${A} ${",
+		"${A:-${B",
+		"${A%%x} $B",
+		"${A^^} $B",
+		`"${A} unclosed`,
+		"${A:=x} ${B}",
+	}
+	noLeaks(t, func() {
+		for _, in := range inputs {
+			Expand(in, Map{})
+			Expand(in, Map{}, WithAllErrors())
+			Validate(in)
+			ExpandFields(in, Map{})
+		}
+	})
+}
+
+func TestNoLeaks_panic(t *testing.T) {
+	noLeaks(t, func() {
+		for i := 0; i < 10; i++ {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatal("expected the getter to panic")
+					}
+				}()
+				Expand("$A ${B} ${C:-d}", panicGetter{})
+			}()
+		}
+	})
+}
+
+func TestNoLeaks_streams(t *testing.T) {
+	noLeaks(t, func() {
+		r := NewReader(strings.NewReader("a $A ${B:?missing} c"), Map{"A": "a"})
+		io.ReadAll(r)
+
+		w := NewWriter(io.Discard, Map{})
+		io.WriteString(w, "${A:?missing} ${B")
+		w.Close()
+	})
+}
@@ -1,10 +1,6 @@
 package posix
 
 import (
-	"bytes"
-	"errors"
-	"fmt"
-	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -14,159 +10,109 @@ type stateFn func(*lexer) stateFn
 type Pos int
 
 type lexer struct {
-	stream       chan item
+	tokens       []token // the tokens emitted but not yet returned by nextToken
+	head         int     // the index of the next token to return
 	input        string
 	state        stateFn
 	pos          Pos
 	start        Pos
 	width        Pos
 	depth        int
+	brackets     []Pos // the start of each open ${ expansion
+	exprStart    Pos   // the start of the current $ expansion
+	quoteStart   Pos   // the start of the current quoted string
 	doubleQuotes bool
-	closed       chan struct{}
+	wordStart    Pos
+	blank        bool // whether an unquoted blank ended the leading word
+	words        bool
+	opts         *Expander
 }
 
-type item interface {
-	Eval(mapping Getter, stream chan item) (string, error)
+// An item is a token emitted by the lexer.
+type item interface{}
+
+// A token is an item with the position in the input that it relates to.
+type token struct {
+	item item
+	pos  Pos
 }
 
 // A text value
 type itemText string
 
-func (p itemText) Eval(mapping Getter, stream chan item) (string, error) {
-	return string(p), nil
-}
+// A text value from single-quotes or a backslash escape
+type itemQuotedText string
+
+// Marks the start or end of a double-quoted string
+type itemDoubleQuote struct{}
 
 // Sentinel value included to mark the end of a bracketed block
 type itemEndBracket struct{}
 
-func (x itemEndBracket) Eval(mapping Getter, stream chan item) (string, error) {
-	return "", nil
-}
-
-// Reached the end of the string while looking for a closing token. Evaluates to an error.
+// Reached the end of the string while looking for a closing token.
 type itemUnexpectedEOF rune
 
-func (i itemUnexpectedEOF) Eval(mapping Getter, stream chan item) (string, error) {
-	return "", fmt.Errorf("unexpected EOF while looking for matching `%c'", i)
-}
-
-// Evaluates to the value of the parameter
+// Reads the value of the parameter
 type itemReadParam string
 
-func (p itemReadParam) Eval(mapping Getter, stream chan item) (string, error) {
-	v, _ := mapping.Get(string(p))
-	return v, nil
-}
-
-// Evaluates to the length of the parameter
+// Reads the length of the parameter
 type itemParamLen string
 
-func (p itemParamLen) Eval(mapping Getter, stream chan item) (string, error) {
-	v, _ := mapping.Get(string(p))
-	return strconv.Itoa(len(v)), nil
-}
-
-// Evaluates a parameter with one of the operators applied
+// Reads a parameter with one of the operators applied, followed by the
+// items of its word up to an itemEndBracket
 type itemParamOp struct {
 	parameter   string
 	op          rune
 	nullIsEmpty bool
 }
 
-func (p itemParamOp) Eval(mapping Getter, stream chan item) (string, error) {
-	paramVal, paramSet := mapping.Get(p.parameter)
-	if p.nullIsEmpty {
-		paramSet = paramVal != ""
+// An operator that is recognized but not supported, which may require the
+// Bash dialect, or a bad substitution that is not an operator at all
+type itemBadOp struct {
+	parameter string
+	op        string
+	bashOnly  bool
+	badSubst  bool
+}
+
+// A tilde-prefix with the user name, if any
+type itemTilde string
+
+// lex returns a lexer for the input, which produces tokens as they are
+// requested with nextToken. When words is set, quotes and backslashes are
+// also interpreted outside of parameter expansions, as in a shell word.
+func lex(s string, opts *Expander, words bool) *lexer {
+	state := lexText
+	switch opts.Dialect {
+	case Kubernetes:
+		state = lexKubernetes
+	case Windows:
+		state = lexWindows
+	case Make:
+		state = lexMake
 	}
-
-	if p.op == '+' {
-		if paramSet {
-			return evalStream(mapping, bracketedStream(stream))
-		}
-		skipStream(stream)
-		return "", nil
+	return &lexer{
+		input: s,
+		state: state,
+		opts:  opts,
+		words: words,
 	}
-
-	if paramSet {
-		skipStream(bracketedStream(stream))
-		return paramVal, nil
-	}
-
-	val, err := evalStream(mapping, bracketedStream(stream))
-	if err != nil {
-		return "", err
-	}
-
-	switch p.op {
-	case '-':
-		return val, nil
-	case '=':
-		if setter, ok := mapping.(Setter); ok {
-			err := setter.Set(p.parameter, val)
-			if err != nil {
-				return "", err
-			}
-			return val, nil
-		}
-		return "", fmt.Errorf("mapping type %T does not support assignment", mapping)
-	case '?':
-		if val == "" {
-			val = fmt.Sprintf("%s: parameter null or not set", p.parameter)
-		}
-		return "", errors.New(val)
-	}
-
-	return "", fmt.Errorf("unexpected op: %q", p.op)
 }
 
-// Returns the evaluation of the stream items against the given mapping.
-//
-// If all items are evaluated without errors, returns the concatenated results,
-// or it returns the first error encountered.
-func evalStream(mapping Getter, stream chan item) (string, error) {
-	var buf bytes.Buffer
-
-	for item := range stream {
-		text, err := item.Eval(mapping, stream)
-		if err != nil {
-			return "", err
+// nextToken returns the next token, running the state functions until one
+// is emitted. It returns false at the end of the input.
+func (l *lexer) nextToken() (token, bool) {
+	for l.head == len(l.tokens) {
+		if l.state == nil {
+			return token{}, false
 		}
-		buf.WriteString(text)
-	}
-
-	return buf.String(), nil
-}
-
-// Returns a sub-stream with the items up until the next end bracket.
-func bracketedStream(stream chan item) chan item {
-	c := make(chan item)
-	go func() {
-		for item := range stream {
-			if _, ok := item.(itemEndBracket); ok {
-				close(c)
-				return
-			}
-			c <- item
-		}
-		c <- itemUnexpectedEOF('}')
-	}()
-	return c
-}
-
-// Consumes the remaining items in the stream
-func skipStream(stream chan item) {
-	for range stream {
-	}
-}
-
-func lex(s string) *lexer {
-	l := &lexer{
-		stream: make(chan item),
-		closed: make(chan struct{}),
-		input:  s,
+		// reuse the queue once it has been drained
+		l.tokens, l.head = l.tokens[:0], 0
+		l.state = l.state(l)
 	}
-	go l.run()
-	return l
+	tok := l.tokens[l.head]
+	l.head++
+	return tok, true
 }
 
 const eof = -1
@@ -183,6 +129,13 @@ func (l *lexer) next() rune {
 	return r
 }
 
+// peek returns but does not consume the next rune in the input.
+func (l *lexer) peek() rune {
+	r := l.next()
+	l.backup()
+	return r
+}
+
 // backup steps back one rune. Can only be called once per call of next.
 func (l *lexer) backup() {
 	l.pos -= l.width
@@ -198,12 +151,31 @@ func (l *lexer) emitLastToken() {
 	l.ignore()
 }
 
+// emitText emits the pending input as text, if there is any.
+func (l *lexer) emitText() {
+	if l.pos > l.start {
+		l.emit(itemText(l.token()))
+		l.start = l.pos
+	}
+}
+
 func (l *lexer) token() string {
 	return l.input[l.start:l.pos]
 }
 
 func (l *lexer) emit(item item) {
-	l.stream <- item
+	pos := l.start
+	switch item := item.(type) {
+	case itemReadParam, itemParamLen, itemParamOp, itemBadOp:
+		pos = l.exprStart
+	case itemUnexpectedEOF:
+		if item == '}' {
+			pos = l.brackets[len(l.brackets)-1]
+		} else {
+			pos = l.quoteStart
+		}
+	}
+	l.tokens = append(l.tokens, token{item, pos})
 }
 
 // ignore skips over the pending input before this point.
@@ -211,28 +183,21 @@ func (l *lexer) ignore() {
 	l.start = l.pos
 }
 
-func (l *lexer) run() {
-	defer close(l.stream)
-	for l.state = lexText; l.state != nil; {
-		select {
-		case <-l.closed:
-			return
-		default:
-		}
-		l.state = l.state(l)
-	}
-}
-
-func (l *lexer) Close() {
-	close(l.closed)
-	skipStream(l.stream)
-}
-
 func lexText(l *lexer) stateFn {
+	stop := l.textStop()
 	for {
+		// skip over literal text in one step
+		if i := strings.IndexAny(l.input[l.pos:], stop); i > 0 {
+			l.pos += Pos(i)
+		} else if i < 0 {
+			l.pos = Pos(len(l.input))
+		}
 		switch l.next() {
 		case eof:
 			l.emitLastToken()
+			if l.doubleQuotes && l.depth == 0 {
+				l.emit(itemUnexpectedEOF('"'))
+			}
 			return nil
 		case '}':
 			if l.depth > 0 {
@@ -244,20 +209,37 @@ func lexText(l *lexer) stateFn {
 			l.emitLastToken()
 			return lexStartExpansion
 		case '\'':
-			if l.depth > 0 {
+			if l.quoting() && !l.doubleQuotes {
 				l.emitLastToken()
+				l.quoteStart = l.pos - 1
 				return lexSingleQuoteString
 			}
 		case '\\':
 			l.emitLastToken()
 			c := l.next()
-			if (l.depth == 0 && c != '$') || (l.doubleQuotes && !strings.ContainsRune("$`\"\\", c)) {
+			if (!l.quoting() && c != '$') || (l.doubleQuotes && !strings.ContainsRune("$`\"\\", c)) {
 				l.emit(itemText("\\"))
+			} else if c != eof {
+				l.emit(itemQuotedText(l.token()))
+				l.ignore()
 			}
 		case '"':
-			if l.depth > 0 {
+			if l.quoting() {
 				l.emitLastToken()
+				if !l.doubleQuotes {
+					l.quoteStart = l.pos - 1
+				}
 				l.doubleQuotes = !l.doubleQuotes
+				l.emit(itemDoubleQuote{})
+			}
+		case ' ', '\t', '\n':
+			if l.depth == 0 && !l.doubleQuotes {
+				l.blank = true
+			}
+		case '~':
+			if l.opts.Tilde && l.tildeAllowed(l.pos-l.width) {
+				l.emitLastToken()
+				return lexTilde
 			}
 		}
 	}
@@ -270,30 +252,72 @@ func lexSingleQuoteString(l *lexer) stateFn {
 			l.emit(itemUnexpectedEOF('\''))
 			return nil
 		case '\'':
-			l.emitLastToken()
+			l.backup()
+			l.emit(itemQuotedText(l.token()))
+			l.next()
+			l.ignore()
 			return lexText
 		}
 	}
 }
 
+// quoting reports whether quotes and backslashes are interpreted at the
+// current position, rather than passed through literally.
+func (l *lexer) quoting() bool {
+	return (l.depth > 0 || l.words) && l.opts.Dialect != Compose
+}
+
+// textStop returns the characters that may end a run of literal text.
+func (l *lexer) textStop() string {
+	switch quoting := l.quoting(); {
+	case l.opts.Dialect == Compose:
+		return "$}"
+	case quoting && l.opts.Tilde:
+		// blanks end the assignment where a tilde may follow a ":"
+		return "$}\\'\"~ \t\n"
+	case quoting:
+		return "$}\\'\""
+	case l.opts.Tilde:
+		return "$}\\~ \t\n"
+	}
+	return "$}\\"
+}
+
 func lexStartExpansion(l *lexer) stateFn {
+	l.exprStart = l.pos - 1
 	c := l.next()
 	switch {
 	case c == eof:
 		l.emit(itemText("$"))
 		return nil
+	case c == '$' && l.opts.Dialect == Compose:
+		l.emit(itemQuotedText("$"))
+		l.ignore()
+		return lexText
 	case c == '{':
 		l.ignore()
 		l.depth++
+		l.brackets = append(l.brackets, l.exprStart)
 		return lexBracketName
 	case isAlpha(c):
 		return lexSimpleName
+	case (isSpecialParam(c) || isNum(c)) && l.opts.Dialect != Compose:
+		// a positional parameter without brackets is a single digit, so
+		// $10 is ${1} followed by 0
+		l.emit(itemReadParam(l.token()))
+		l.ignore()
+		return lexText
 	}
-	return nil // FIXME
+	// not an expansion, so the "$" is literal, like in a shell
+	l.backup()
+	l.emit(itemText("$"))
+	l.ignore()
+	return lexText
 }
 
 func lexEndBracket(l *lexer) stateFn {
 	l.depth--
+	l.brackets = l.brackets[:len(l.brackets)-1]
 	l.ignore()
 	return lexText
 }
@@ -311,18 +335,30 @@ func lexSimpleName(l *lexer) stateFn {
 }
 
 func lexBracketName(l *lexer) stateFn {
-	if l.next() == '#' {
+	if l.opts.Dialect == Compose {
+		// there are no lengths or special parameters
+		return lexBracketVarName
+	}
+	// ${#} is the number of positional parameters, not a length
+	if l.next() == '#' && l.peek() != '}' {
 		l.ignore()
 		return lexParamLength
 	}
 	l.backup()
+	if isSpecialParam(l.next()) {
+		return lexParamOp
+	}
+	l.backup()
+	return lexBracketVarName
+}
+
+func lexBracketVarName(l *lexer) stateFn {
 	for {
 		switch l.next() {
 		case eof:
 			l.emit(itemUnexpectedEOF('}'))
 			return nil
-		// FIXME what if ':' is not followed by an op?
-		case '}', ':', '-', '?', '+', '=':
+		case '}', ':', '-', '?', '+', '=', '%', '#', '/', '^', ',', '@':
 			l.backup()
 			return lexParamOp
 		}
@@ -342,12 +378,48 @@ func lexParamOp(l *lexer) stateFn {
 	if nullIsEmpty {
 		op = l.next()
 	}
+	if !strings.ContainsRune("-=?+", op) || op == '=' && l.opts.Dialect == Compose {
+		return lexBadOp(l, paramName, nullIsEmpty, op)
+	}
 	l.ignore()
+	l.wordStart = l.pos
 
 	l.emit(itemParamOp{paramName, op, nullIsEmpty})
 	return lexText
 }
 
+// lexBadOp reports an operator that is not supported, noting the bash
+// extensions when using the POSIX dialect.
+func lexBadOp(l *lexer, paramName string, colon bool, op rune) stateFn {
+	var opText string
+	bashOp, badSubst := false, false
+	switch {
+	case colon && op != eof && !strings.ContainsRune("}%#/^,@", op):
+		// ${name:offset:length}
+		opText = ":"
+		bashOp = true
+	case op == '%', op == '#', op == '/', op == '^', op == ',':
+		opText = string(op)
+		if l.peek() == op {
+			opText += string(op)
+		}
+		bashOp = strings.ContainsRune("/^,", op)
+		// these operators never follow a colon
+		badSubst = colon
+	case op == '@':
+		opText = "@"
+		bashOp = true
+	case op == eof:
+		l.emit(itemUnexpectedEOF('}'))
+		return nil
+	default:
+		opText = string(op)
+		badSubst = true
+	}
+	l.emit(itemBadOp{paramName, opText, bashOp && l.opts.Dialect == POSIX, badSubst})
+	return nil
+}
+
 func lexParamLength(l *lexer) stateFn {
 	for {
 		switch l.next() {
@@ -379,3 +451,35 @@ func isNum(c rune) bool {
 func isAlphaNum(c rune) bool {
 	return isAlpha(c) || isNum(c)
 }
+
+// isName reports whether the string is a valid shell variable name
+func isName(s string) bool {
+	for i, c := range s {
+		if !isAlphaNum(c) || i == 0 && isNum(c) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isDottedName reports whether the string is a variable name followed by
+// parts separated by dots, where each part is letters, numbers, or
+// underscores.
+func isDottedName(s string) bool {
+	name, rest, ok := strings.Cut(s, ".")
+	if !ok || !isName(name) {
+		return false
+	}
+	for _, part := range strings.Split(rest, ".") {
+		if part == "" || strings.IndexFunc(part, func(c rune) bool { return !isAlphaNum(c) }) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isVarName reports whether the string names a variable, rather than a
+// positional or special parameter.
+func isVarName(s string) bool {
+	return isName(s) || isDottedName(s)
+}
@@ -0,0 +1,15 @@
+package posix
+
+// output appends the segments to dst as part of the result, returning
+// ErrOutputLimit once the result is longer than the MaxOutput option.
+func (ev *evaluator) output(dst segments, segs ...segment) (segments, error) {
+	if ev.opts.MaxOutput > 0 {
+		for _, seg := range segs {
+			ev.size += len(seg.text)
+		}
+		if ev.size > ev.opts.MaxOutput {
+			return nil, ErrOutputLimit
+		}
+	}
+	return append(dst, segs...), nil
+}
@@ -0,0 +1,57 @@
+package posix
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var limittests = []struct {
+	in  string
+	out string
+	err error
+}{
+	{"$a$a", "xxxxxxxx", nil},
+	{"$a$a$a", "", ErrOutputLimit},
+	{"${unset:-$a$a}", "xxxxxxxx", nil},
+	{"${a:+$a$a$a}", "", ErrOutputLimit},
+	{"0123456789", "", ErrOutputLimit},
+	{`"$a" "$a"`, "", ErrOutputLimit},
+}
+
+func TestExpand_maxOutput(t *testing.T) {
+	mapping := Map{"a": "xxxx"}
+	for _, test := range limittests {
+		out, err := Expand(test.in, mapping, WithMaxOutput(8))
+		equals(t, test.err, err)
+		equals(t, test.out, out)
+	}
+}
+
+func TestExpand_maxOutputAllErrors(t *testing.T) {
+	mapping := Map{"a": strings.Repeat("x", 100)}
+	_, err := Expand("${b:?} $a $a ${c:?}", mapping, WithMaxOutput(150), WithAllErrors())
+	equals(t, ErrOutputLimit, err)
+}
+
+func TestExpand_maxOutputBraces(t *testing.T) {
+	e := NewExpander(WithDialect(Bash), WithMaxOutput(100))
+	out, err := e.Expand("{a,b}{c,d}", Map{})
+	ok(t, err)
+	equals(t, "ac ad bc bd", out)
+
+	// 2^22 words would take about 100 MiB, so the limit has to stop the
+	// brace expansion before it builds them
+	s := strings.Repeat("{a,b}", 22)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = e.Expand(s, Map{})
+	runtime.ReadMemStats(&after)
+	equals(t, ErrOutputLimit, err)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("expanding %q allocated %d bytes", s, n)
+	}
+
+	_, err = e.Expand("{1..1000}", Map{})
+	equals(t, ErrOutputLimit, err)
+}
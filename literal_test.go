@@ -0,0 +1,37 @@
+package posix
+
+import "testing"
+
+func TestExpand_literal(t *testing.T) {
+	inputs := []string{"", "plain text", `a\b "q" 'c'`, "~/bin", "{a,b}", "a}b", "a long string"}
+	opts := [][]Option{
+		nil,
+		{WithQuoteRemoval()},
+		{WithTilde(nil)},
+		{WithDialect(Bash)},
+		{WithMaxOutput(5)},
+	}
+	for _, in := range inputs {
+		for _, o := range opts {
+			e := NewExpander(o...)
+			expect, expectErr := "", error(nil)
+			if tmpl, err := e.Parse(in); err != nil {
+				expectErr = err
+			} else {
+				expect, expectErr = tmpl.Execute(Map{"HOME": "/home/me"})
+			}
+			out, err := Expand(in, Map{"HOME": "/home/me"}, o...)
+			equals(t, expectErr, err)
+			equals(t, expect, out)
+		}
+	}
+}
+
+func TestExpand_literalAllocs(t *testing.T) {
+	mapping := Map{}
+	allocs := testing.AllocsPerRun(100, func() {
+		Expand("no expansions here", mapping)
+		ExpandEnv("or here")
+	})
+	equals(t, 0.0, allocs)
+}
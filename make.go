@@ -0,0 +1,70 @@
+package posix
+
+import "strings"
+
+// lexMake lexes the input for the Make dialect, where the only expansions
+// are $(name) and ${name} references and $$ escapes. Any other "$" is
+// literal text, including a reference whose name is not valid, such as a
+// Make function like $(shell ls).
+func lexMake(l *lexer) stateFn {
+	for {
+		i := strings.IndexByte(l.input[l.pos:], '$')
+		if i < 0 {
+			l.pos = Pos(len(l.input))
+			l.emitText()
+			return nil
+		}
+		l.pos += Pos(i)
+		switch end := makeRefEnd(l.input[l.pos:]); {
+		case end == 2:
+			l.emitText()
+			l.pos += 2
+			l.emit(itemQuotedText("$"))
+			l.ignore()
+		case end > 2:
+			l.emitText()
+			l.exprStart = l.pos
+			l.emit(itemReadParam(l.input[l.pos+2 : l.pos+Pos(end)-1]))
+			l.pos += Pos(end)
+			l.ignore()
+		default:
+			l.pos++
+		}
+	}
+}
+
+// makeRefEnd returns the length of the $(name) or ${name} reference or $$
+// escape at the start of the input, 1 if the "$" does not start one, or -1
+// if it may continue past the end of the input.
+func makeRefEnd(in string) int {
+	if len(in) < 2 {
+		return -1
+	}
+	var closer byte
+	switch in[1] {
+	case '$':
+		return 2
+	case '(':
+		closer = ')'
+	case '{':
+		closer = '}'
+	default:
+		return 1
+	}
+	for i := 2; i < len(in); i++ {
+		switch c := in[i]; {
+		case c == closer && i > 2:
+			return i + 1
+		case !isMakeNameChar(c):
+			return 1
+		}
+	}
+	return -1
+}
+
+// isMakeNameChar reports whether the byte may be part of a variable name in
+// the Make dialect, which excludes whitespace and the characters of other
+// Make syntax.
+func isMakeNameChar(c byte) bool {
+	return c > ' ' && c != 0x7f && strings.IndexByte("$(){}:#=", c) < 0
+}
@@ -0,0 +1,32 @@
+package posix
+
+import "testing"
+
+var maketests = []dialecttest{
+	{"plain", "plain"},
+	{"$(CC) -o ${OUT}", "gcc -o a.out"},
+	{"$$(CC) $${OUT} $$$(CC) $$", "$(CC) ${OUT} $gcc $"},
+	{"$(UNSET)|$(EMPTY)|", "||"},
+	{"$(build.dir)/$(go-version)", "out/1.21"},
+	{"$CC $ $(shell ls) $(CC:.c=.o) ${a b} $() ${}", "$CC $ $(shell ls) $(CC:.c=.o) ${a b} $() ${}"},
+	{"$(shell echo $(CC))", "$(shell echo gcc)"},
+	{"$(CC", "$(CC"},
+	{"${CC)", "${CC)"},
+	{`\$(CC) '$(CC)' "$(CC)" ~`, `\gcc 'gcc' "gcc" ~`},
+}
+
+func TestExpand_make(t *testing.T) {
+	mapping := Map{"CC": "gcc", "OUT": "a.out", "EMPTY": "", "build.dir": "out", "go-version": "1.21"}
+	testDialect(t, Make, mapping, maketests)
+}
+
+func TestExpand_makeKeepUnset(t *testing.T) {
+	out, err := Expand("$(CC) $(UNSET) ${UNSET} $$", Map{"CC": "gcc"}, WithDialect(Make), WithKeepUnset())
+	ok(t, err)
+	equals(t, "gcc $(UNSET) ${UNSET} $", out)
+
+	tmpl, err := NewExpander(WithDialect(Make)).Parse("$(A)${B} $$ $C")
+	ok(t, err)
+	equals(t, "$(A)$(B) $$ $$C", tmpl.String())
+	equals(t, []string{"A", "B"}, tmpl.Vars())
+}
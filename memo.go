@@ -0,0 +1,33 @@
+package posix
+
+type memoValue struct {
+	value string
+	set   bool
+}
+
+// get returns the value of a parameter like fetch, remembering the values
+// of variables for the rest of the expansion with the Memoize option.
+func (ev *evaluator) get(name string, pos Pos) (string, bool, error) {
+	if !ev.opts.Memoize || !isVarName(name) {
+		return ev.fetch(name, pos)
+	}
+	if m, ok := ev.memo[name]; ok {
+		return m.value, m.set, nil
+	}
+	v, ok, err := ev.fetch(name, pos)
+	if err == nil {
+		ev.remember(name, v, ok)
+	}
+	return v, ok, err
+}
+
+// remember records the value of a variable with the Memoize option.
+func (ev *evaluator) remember(name, value string, set bool) {
+	if !ev.opts.Memoize {
+		return
+	}
+	if ev.memo == nil {
+		ev.memo = make(map[string]memoValue)
+	}
+	ev.memo[name] = memoValue{value, set}
+}
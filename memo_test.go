@@ -0,0 +1,50 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoize(t *testing.T) {
+	m := &countingMap{Map: Map{"DB_URL": "postgres://db"}}
+	out, err := Expand("$DB_URL ${DB_URL} ${#DB_URL} ${DB_URL:+set} ${X-a} ${X-b}", m, WithMemoize())
+	ok(t, err)
+	equals(t, "postgres://db postgres://db 13 set a b", out)
+	equals(t, 1, m.count("DB_URL"))
+	equals(t, 1, m.count("X"))
+
+	// values are not shared between expansions
+	_, err = Expand("$DB_URL", m, WithMemoize())
+	ok(t, err)
+	equals(t, 2, m.count("DB_URL"))
+
+	// without the option every reference is looked up
+	_, err = Expand("$DB_URL $DB_URL", m)
+	ok(t, err)
+	equals(t, 4, m.count("DB_URL"))
+}
+
+func TestMemoize_assign(t *testing.T) {
+	out, err := Expand("${A-unset} ${A=x} $A", RWMap{}, WithMemoize())
+	ok(t, err)
+	equals(t, "unset x x", out)
+}
+
+func TestMemoize_errors(t *testing.T) {
+	m := &countingMap{}
+	_, err := Expand("$FAIL $FAIL", m, WithMemoize(), WithAllErrors())
+	equals(t, true, errors.Is(err, errLookup))
+	equals(t, 2, m.count("FAIL"))
+}
+
+func TestMemoize_onUnset(t *testing.T) {
+	calls := 0
+	onUnset := func(name string) (string, error) {
+		calls++
+		return "prompted", nil
+	}
+	out, err := Expand("$A $A", Map{}, WithMemoize(), WithOnUnset(onUnset))
+	ok(t, err)
+	equals(t, "prompted prompted", out)
+	equals(t, 1, calls)
+}
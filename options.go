@@ -0,0 +1,109 @@
+package posix
+
+// Option sets an option on an Expander, for composing options per call to
+// Expand.
+type Option func(*Expander)
+
+// NewExpander returns an Expander with the options applied.
+func NewExpander(opts ...Option) *Expander {
+	e := new(Expander)
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// WithDialect sets the Dialect option.
+func WithDialect(d Dialect) Option {
+	return func(e *Expander) { e.Dialect = d }
+}
+
+// WithTilde enables tilde expansion, resolving "~user" with the function,
+// which may be nil to only expand "~".
+func WithTilde(userHome UserHomeFunc) Option {
+	return func(e *Expander) {
+		e.Tilde = true
+		e.UserHome = userHome
+	}
+}
+
+// WithQuoteRemoval enables the QuoteRemoval option.
+func WithQuoteRemoval() Option {
+	return func(e *Expander) { e.QuoteRemoval = true }
+}
+
+// WithStableErrors enables the StableErrors option.
+func WithStableErrors() Option {
+	return func(e *Expander) { e.StableErrors = true }
+}
+
+// WithAllErrors enables the AllErrors option.
+func WithAllErrors() Option {
+	return func(e *Expander) { e.AllErrors = true }
+}
+
+// WithAssign sets the Assign option.
+func WithAssign(mode AssignMode) Option {
+	return func(e *Expander) { e.Assign = mode }
+}
+
+// WithNoUnset enables the NoUnset option.
+func WithNoUnset() Option {
+	return func(e *Expander) { e.NoUnset = true }
+}
+
+// WithNoEmpty enables the NoEmpty option.
+func WithNoEmpty() Option {
+	return func(e *Expander) { e.NoEmpty = true }
+}
+
+// WithKeepUnset enables the KeepUnset option.
+func WithKeepUnset() Option {
+	return func(e *Expander) { e.KeepUnset = true }
+}
+
+// WithOnUnset sets the OnUnset callback.
+func WithOnUnset(fn func(name string) (string, error)) Option {
+	return func(e *Expander) { e.OnUnset = fn }
+}
+
+// WithAllow restricts expansion to the listed variables, leaving other
+// references in the result. See Expander.Allow.
+func WithAllow(names ...string) Option {
+	return func(e *Expander) { e.Allow = append(append([]string{}, e.Allow...), names...) }
+}
+
+// WithDisallowError enables the DisallowError option.
+func WithDisallowError() Option {
+	return func(e *Expander) { e.DisallowError = true }
+}
+
+// WithVarsOnly enables the VarsOnly option.
+func WithVarsOnly() Option {
+	return func(e *Expander) { e.VarsOnly = true }
+}
+
+// WithMaxOutput limits the length of the result. See Expander.MaxOutput.
+func WithMaxOutput(n int) Option {
+	return func(e *Expander) { e.MaxOutput = n }
+}
+
+// WithIFS sets the default field separators used by ExpandFields.
+func WithIFS(ifs string) Option {
+	return func(e *Expander) { e.IFS = ifs }
+}
+
+// WithDottedNames enables the DottedNames option.
+func WithDottedNames() Option {
+	return func(e *Expander) { e.DottedNames = true }
+}
+
+// WithMemoize enables the Memoize option.
+func WithMemoize() Option {
+	return func(e *Expander) { e.Memoize = true }
+}
+
+// WithJSONTypes enables the JSONTypes option.
+func WithJSONTypes() Option {
+	return func(e *Expander) { e.JSONTypes = true }
+}
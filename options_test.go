@@ -0,0 +1,31 @@
+package posix
+
+import "testing"
+
+func TestExpand_options(t *testing.T) {
+	out, err := Expand(`~/ '$a' {x,y}`, Map{"a": "A", "HOME": "/home/me"},
+		WithQuoteRemoval(),
+		WithTilde(nil),
+		WithDialect(Bash),
+	)
+	ok(t, err)
+	equals(t, "/home/me/ $a x y", out)
+}
+
+func TestExpand_optionsErrors(t *testing.T) {
+	out, err := Expand("${a:=x}-${b}", Map{}, WithAllErrors(), WithKeepUnset())
+	equals(t, "${a:=x}-${b}", out)
+	ok(t, err)
+
+	_, err = Expand("${a:=x}", Map{}, WithStableErrors())
+	equals(t, "a: cannot assign in this way", err.Error())
+}
+
+func TestNewExpander(t *testing.T) {
+	userHome := func(string) (string, bool) { return "", false }
+	e := NewExpander(WithIFS(":"), WithTilde(userHome))
+	equals(t, ":", e.IFS)
+	equals(t, true, e.Tilde)
+	equals(t, true, e.UserHome != nil)
+	equals(t, *new(Expander), *NewExpander(WithIFS(":"), WithIFS("")))
+}
@@ -0,0 +1,53 @@
+package posix
+
+import "strings"
+
+// keepUnset reports whether an unset parameter should be kept in the
+// result with the KeepUnset option, or the Kubernetes dialect.
+func (ev *evaluator) keepUnset(name string) bool {
+	if ev.opts.Dialect == Kubernetes {
+		return true
+	}
+	return ev.opts.KeepUnset && (isVarName(name) || ev.opts.Dialect == Windows || ev.opts.Dialect == Make)
+}
+
+// verbatim returns the syntax of the expansion as a quoted segment, so it
+// is kept intact by field splitting.
+func (ev *evaluator) verbatim(n Node) segments {
+	f := &formatter{dialect: ev.opts.Dialect}
+	if p, ok := n.(*ParamNode); ok {
+		f.buf.WriteString(ev.paramRef(p))
+	} else {
+		f.nodes([]Node{n}, formatContext{})
+	}
+	return segments{{text: f.buf.String(), quoted: true}}
+}
+
+// paramRef returns the reference to the parameter as "$name" when it is
+// written that way in the input and followed by literal text that cannot
+// continue the name. Otherwise the text that follows in the result is not
+// known, so the reference uses braces.
+func (ev *evaluator) paramRef(p *ParamNode) string {
+	switch ev.opts.Dialect {
+	case Kubernetes:
+		return "$(" + p.Name + ")"
+	case Windows:
+		return "%" + p.Name + "%"
+	case Make:
+		if p.Pos >= 0 && int(p.Pos)+1 < len(ev.src) && ev.src[p.Pos+1] == '{' {
+			return "${" + p.Name + "}"
+		}
+		return "$(" + p.Name + ")"
+	}
+	ref := "$" + p.Name
+	end := int(p.Pos) + len(ref)
+	if p.Pos < 0 || end > len(ev.src) || ev.src[p.Pos:end] != ref {
+		return "${" + p.Name + "}"
+	}
+	if end < len(ev.src) {
+		if c := ev.src[end]; isAlphaNum(rune(c)) || strings.IndexByte(".${}\\'\"~", c) >= 0 {
+			return "${" + p.Name + "}"
+		}
+	}
+	return ref
+}
@@ -0,0 +1,51 @@
+package posix
+
+import "testing"
+
+var keepunsettests = []struct {
+	in  string
+	out string
+}{
+	{"$set", "yes"},
+	{"$unset", "$unset"},
+	{"${unset}x", "${unset}x"},
+	{"${#unset}", "${#unset}"},
+	{"${unset:-default}", "${unset:-default}"},
+	{"${unset:-$set}", "${unset:-$set}"},
+	{"${unset:=x}", "${unset:=x}"},
+	{"${unset:?required}", "${unset:?required}"},
+	{"${unset+x}", "${unset+x}"},
+	{"${empty:-default}", "default"},
+	{"${empty:+x}", ""},
+	{"${set:+$unset}", "${unset}"},
+	{"${set:-$unset}", "yes"},
+	{"$1 $# $?", " 0 "},
+}
+
+func TestExpand_keepUnset(t *testing.T) {
+	e := &Expander{KeepUnset: true}
+	mapping := Map{"set": "yes", "empty": ""}
+	for _, test := range keepunsettests {
+		out, err := e.Expand(test.in, mapping)
+		ok(t, err)
+		equals(t, test.out, out)
+	}
+}
+
+func TestExpand_keepUnsetStages(t *testing.T) {
+	e := &Expander{KeepUnset: true}
+	first, err := e.Expand("$host:${port:-80}/$path", Map{"host": "example.com"})
+	ok(t, err)
+	equals(t, "example.com:${port:-80}/$path", first)
+
+	second, err := Expand(first, Map{"path": "index.html"})
+	ok(t, err)
+	equals(t, "example.com:80/index.html", second)
+}
+
+func TestExpandFields_keepUnset(t *testing.T) {
+	e := &Expander{KeepUnset: true}
+	fields, err := e.ExpandFields("a ${unset:-b c}", Map{})
+	ok(t, err)
+	equals(t, []string{"a", "${unset:-b c}"}, fields)
+}
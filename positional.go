@@ -0,0 +1,188 @@
+package posix
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Positional is the interface for mappings that provide the positional
+// parameters, for expanding $1 through $n, $@, $* and $#.
+type Positional interface {
+	Positional() []string
+}
+
+// Params wraps a Getter with a list of positional parameters, for expanding
+// $1 through $n, $@, $* and $# from an argument list such as os.Args[1:].
+//
+// Assignments, special parameters, lookups that can fail or take a
+// context, and secrets are passed through to the Getter when it supports
+// them.
+type Params struct {
+	Getter
+	Args []string
+}
+
+func (p Params) Positional() []string {
+	return p.Args
+}
+
+// Shift removes the first n positional parameters, like the shell's shift
+// command, so $n+1 becomes $1. It returns an error without changing the
+// parameters if n is negative or greater than the number of parameters.
+func (p *Params) Shift(n int) error {
+	if n < 0 || n > len(p.Args) {
+		return fmt.Errorf("shift count out of range: %d", n)
+	}
+	p.Args = p.Args[n:]
+	return nil
+}
+
+// Slice returns a copy of the Params with the positional parameters
+// p.Args[from:to], where the bounds are clamped to the available
+// parameters.
+func (p Params) Slice(from, to int) Params {
+	to = min(max(to, 0), len(p.Args))
+	from = min(max(from, 0), to)
+	args := make([]string, to-from)
+	copy(args, p.Args[from:to])
+	return Params{p.Getter, args}
+}
+
+func (p Params) Set(k, v string) error {
+	if setter, ok := p.Getter.(Setter); ok {
+		return setter.Set(k, v)
+	}
+	return assignError{k, p.Getter}
+}
+
+func (p Params) Lookup(k string) (string, bool, error) {
+	if eg, ok := p.Getter.(ErrGetter); ok {
+		return eg.Lookup(k)
+	}
+	v, ok := p.Getter.Get(k)
+	return v, ok, nil
+}
+
+func (p Params) GetContext(ctx context.Context, k string) (string, bool, error) {
+	return getContext(ctx, p.Getter, k)
+}
+
+func (p Params) IsSecret(k string) bool {
+	return isSecret(p.Getter, k)
+}
+
+func (p Params) GetSpecial(k string) (string, bool) {
+	if sg, ok := p.Getter.(SpecialGetter); ok {
+		return sg.GetSpecial(k)
+	}
+	return "", false
+}
+
+// ExpandWithArgs expands the string like Expand, using args for the
+// positional parameters.
+func ExpandWithArgs(s string, mapping Getter, args []string) (string, error) {
+	return Expand(s, Params{mapping, args})
+}
+
+// lookup returns the value of a parameter, resolving the positional
+// parameters from a Positional mapping, and the special parameters from a
+// SpecialGetter.
+func (ev *evaluator) lookup(name string) (string, bool) {
+	if v, ok := ev.lookupSpecial(name); ok {
+		return v, true
+	}
+	if args, ok := positional(ev.mapping); ok {
+		switch {
+		case name == "@":
+			return strings.Join(args, " "), len(args) > 0
+		case name == "*":
+			return strings.Join(args, ev.starSep()), len(args) > 0
+		case name == "#":
+			return strconv.Itoa(len(args)), true
+		case isPositional(name):
+			n, err := strconv.Atoi(name)
+			if err != nil || n > len(args) {
+				return "", false
+			}
+			return args[n-1], true
+		}
+	}
+	v, ok := ev.mapping.Get(name)
+	if !ok {
+		return specialDefault(name)
+	}
+	return v, ok
+}
+
+// positionalFields returns the segments for "$@" or "$*", separating each
+// positional parameter so they expand to separate fields.
+func (ev *evaluator) positionalFields(name string) segments {
+	args, ok := positional(ev.mapping)
+	if !ok {
+		v, _ := ev.mapping.Get(name)
+		return segments{{text: v, expanded: true, param: name}}
+	}
+
+	if len(args) == 0 {
+		if name == "*" {
+			return segments{{expanded: true}}
+		}
+		return segments{{noField: true}}
+	}
+	sep := segment{text: " ", fieldSep: true}
+	if name == "*" {
+		sep = segment{text: ev.starSep(), starSep: true}
+	}
+	segs := make(segments, 0, 2*len(args)-1)
+	for i, arg := range args {
+		if i > 0 {
+			segs = append(segs, sep)
+		}
+		segs = append(segs, segment{text: arg, expanded: true, param: strconv.Itoa(i + 1)})
+	}
+	return segs
+}
+
+// positionalSource is implemented by the wrappers in this package, which
+// only provide positional parameters when a mapping they wrap does.
+type positionalSource interface {
+	positional() ([]string, bool)
+}
+
+// positional returns the positional parameters of the mapping, or false if
+// it does not provide them, so they are looked up with Get.
+func positional(mapping Getter) ([]string, bool) {
+	if ps, ok := mapping.(positionalSource); ok {
+		return ps.positional()
+	}
+	if p, ok := mapping.(Positional); ok {
+		return p.Positional(), true
+	}
+	return nil, false
+}
+
+// starSep returns the separator for joining the fields of $*, which is the
+// first character of IFS.
+func (ev *evaluator) starSep() string {
+	ifs := ev.ifs()
+	for _, c := range ifs {
+		return string(c)
+	}
+	return ""
+}
+
+// isPositional reports whether the name is a positional parameter, 1 or
+// greater. Leading zeros are allowed, so ${010} is the same as ${10}.
+func isPositional(name string) bool {
+	if strings.Trim(name, "0") == "" {
+		return false
+	}
+	for _, c := range name {
+		if !isNum(c) {
+			return false
+		}
+	}
+	return true
+}
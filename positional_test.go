@@ -0,0 +1,170 @@
+package posix
+
+import (
+	"context"
+	"testing"
+)
+
+// argsMap is a Map with positional parameters.
+type argsMap struct {
+	Map
+	args []string
+}
+
+func (m argsMap) Positional() []string {
+	return m.args
+}
+
+var positionaltests = []struct {
+	in   string
+	args []string
+	out  string
+}{
+	{"$@", []string{"a", "b c"}, "a b c"},
+	{"$*", []string{"a", "b c"}, "a b c"},
+	{"${@}|${*}", []string{"a", "b"}, "a b|a b"},
+	{"${1}-${2}-${3}", []string{"a", "b"}, "a-b-"},
+	{"${3-unset}", []string{"a", "b"}, "unset"},
+	{"${#1}", []string{"abc"}, "3"},
+	{"${@:-none}", nil, "none"},
+	{"${@:+some}", []string{"a"}, "some"},
+	{"${*-none}", nil, "none"},
+	{"x$@y", nil, "xy"},
+	{"$1-$2-$3", []string{"a", "b"}, "a-b-"},
+	{"${10}|${11-unset}|$10", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "ten"}, "ten|unset|10"},
+	{"${010}", []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "ten"}, "ten"},
+	{"$#", []string{"a", "b"}, "2"},
+	{"${#}", nil, "0"},
+	{"$#-${#}-${#1}", []string{"abc"}, "1-1-3"},
+}
+
+func TestExpand_positional(t *testing.T) {
+	for _, tt := range positionaltests {
+		x, err := Expand(tt.in, argsMap{Map{"1": "not positional"}, tt.args})
+		if err != nil {
+			t.Errorf("pattern %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if x != tt.out {
+			t.Errorf("pattern %#v with args %#v should expand to %#v, but got %#v", tt.in, tt.args, tt.out, x)
+		}
+	}
+}
+
+func TestExpand_positionalStarIFS(t *testing.T) {
+	x, err := Expand(`$*`, argsMap{Map{"IFS": ":,"}, []string{"a", "b", "c"}})
+	ok(t, err)
+	equals(t, "a:b:c", x)
+
+	x, err = Expand(`$*`, argsMap{Map{"IFS": ""}, []string{"a", "b", "c"}})
+	ok(t, err)
+	equals(t, "abc", x)
+}
+
+var positionalfieldtests = []struct {
+	in   string
+	args []string
+	out  []string
+}{
+	{`"$@"`, []string{"a", "b c", ""}, []string{"a", "b c", ""}},
+	{`$@`, []string{"a", "b c", ""}, []string{"a", "b", "c"}},
+	{`"x$@y"`, []string{"a", "b"}, []string{"xa", "by"}},
+	{`"$@"`, nil, nil},
+	{`"" "$@"`, nil, []string{""}},
+	{`"${@}"`, []string{"a b"}, []string{"a b"}},
+	{`"$*"`, []string{"a", "b c"}, []string{"a b c"}},
+	{`$*`, []string{"a", "b c"}, []string{"a", "b", "c"}},
+	{`"$*"`, nil, []string{""}},
+	{`"${@:-x y}"`, nil, []string{"x y"}},
+	{`"${@:+$@}"`, []string{"a", "b"}, []string{"a", "b"}},
+}
+
+func TestExpandFields_positional(t *testing.T) {
+	for _, tt := range positionalfieldtests {
+		x, err := ExpandFields(tt.in, argsMap{Map{}, tt.args})
+		if err != nil {
+			t.Errorf("pattern %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if !equalStrings(x, tt.out) {
+			t.Errorf("pattern %#v with args %#v should split into %#v, but got %#v", tt.in, tt.args, tt.out, x)
+		}
+	}
+}
+
+func TestExpandFields_positionalStarIFS(t *testing.T) {
+	x, err := ExpandFields(`"$*" $*`, argsMap{Map{"IFS": ":"}, []string{"a", "b c"}})
+	ok(t, err)
+	equals(t, []string{"a:b c", "a", "b c"}, x)
+}
+
+func TestExpand_countWithoutPositional(t *testing.T) {
+	x, err := Expand("$# ${#}", Map{})
+	ok(t, err)
+	equals(t, "0 0", x)
+}
+
+func TestExpandWithArgs(t *testing.T) {
+	x, err := ExpandWithArgs("$# $1 ${2:-none} [$*] ${set}", Map{"set": "yes", "1": "ignored"}, []string{"a"})
+	ok(t, err)
+	equals(t, "1 a none [a] yes", x)
+}
+
+func TestParams_passthrough(t *testing.T) {
+	mapping := RWMap{}
+	x, err := Expand("${x:=word} ${1}", Params{mapping, []string{"a"}})
+	ok(t, err)
+	equals(t, "word a", x)
+	equals(t, RWMap{"x": "word"}, mapping)
+
+	_, err = Expand("${x:=word}", Params{Map{}, nil})
+	equals(t, "mapping type posix.Map does not support assignment", err.Error())
+
+	x, err = Expand("$?", Params{statusMap{Map{}, "3"}, nil})
+	ok(t, err)
+	equals(t, "3", x)
+}
+
+func TestExpandFields_params(t *testing.T) {
+	x, err := ExpandFields(`cmd "$@"`, Params{Map{}, []string{"a b", "c"}})
+	ok(t, err)
+	equals(t, []string{"cmd", "a b", "c"}, x)
+}
+
+func TestParams_shift(t *testing.T) {
+	p := &Params{Map{}, []string{"a", "b", "c"}}
+	ok(t, p.Shift(1))
+	x, err := Expand("$# $1", p)
+	ok(t, err)
+	equals(t, "2 b", x)
+
+	ok(t, p.Shift(0))
+	equals(t, []string{"b", "c"}, p.Args)
+	if err := p.Shift(3); err == nil {
+		t.Error("shifting past the end should fail")
+	}
+	if err := p.Shift(-1); err == nil {
+		t.Error("shifting a negative count should fail")
+	}
+	equals(t, []string{"b", "c"}, p.Args)
+	ok(t, p.Shift(2))
+	equals(t, []string{}, p.Args)
+}
+
+func TestParams_slice(t *testing.T) {
+	p := Params{Map{}, []string{"a", "b", "c", "d"}}
+	equals(t, []string{"b", "c"}, p.Slice(1, 3).Args)
+	equals(t, []string{"c", "d"}, p.Slice(2, 10).Args)
+	equals(t, []string{}, p.Slice(3, 1).Args)
+	equals(t, []string{"a"}, p.Slice(-1, 1).Args)
+
+	// slices do not share the original arguments
+	s := p.Slice(0, 2)
+	s.Args[0] = "changed"
+	equals(t, "a", p.Args[0])
+}
+
+func TestParams_context(t *testing.T) {
+	tenant := context.WithValue(context.Background(), tenantKey{}, "acme")
+	out, err := ExpandContext(tenant, "$1 $TENANT", Params{Getter: secretStore{}, Args: []string{"a"}})
+	ok(t, err)
+	equals(t, "a acme", out)
+}
@@ -1,8 +1,9 @@
 package posix
 
 import (
+	"net/url"
 	"os"
-	"syscall"
+	"strings"
 )
 
 // Getter is the interface for mapping key to value lookups.
@@ -23,6 +24,25 @@ func (f Func) Get(s string) (string, bool) {
 	return f(s), true
 }
 
+// LookupFunc implements the Getter interface for lookup functions that
+// report whether the key is set, such as os.LookupEnv.
+type LookupFunc func(string) (string, bool)
+
+func (f LookupFunc) Get(s string) (string, bool) {
+	return f(s)
+}
+
+// AsExpandFunc adapts a Getter to the mapping function used by os.Expand,
+// returning missing for names that are unset.
+func AsExpandFunc(g Getter, missing string) func(string) string {
+	return func(k string) string {
+		if v, ok := g.Get(k); ok {
+			return v
+		}
+		return missing
+	}
+}
+
 // Map implements the Getter interface for map[string]string
 type Map map[string]string
 
@@ -31,7 +51,23 @@ func (m Map) Get(k string) (string, bool) {
 	return v, ok
 }
 
+// Values returns a Getter for url.Values, such as a query string or form
+// data. A key uses its first value, and keys without a value are unset.
+func Values(v url.Values) Getter {
+	return values(v)
+}
+
+type values url.Values
+
+func (v values) Get(k string) (string, bool) {
+	if vs := v[k]; len(vs) > 0 {
+		return vs[0], true
+	}
+	return "", false
+}
+
 // RWMap implements the Getter and Setter interfaces for map[string]string.
+// Use SyncRWMap to assign to a map from concurrent expansions.
 type RWMap map[string]string
 
 func (m RWMap) Get(k string) (string, bool) {
@@ -54,28 +90,299 @@ func (m RWMap) Set(k, v string) error {
 //
 // Alternative: ${param:+word} ${param+word}
 //
+// Options may be passed to enable other behaviors, the same as setting them
+// on an Expander.
+//
 // See: http://pubs.opengroup.org/onlinepubs/9699919799/utilities/V3_chap02.html
-func Expand(s string, mapping Getter) (string, error) {
-	lexer := lex(s)
-	val, err := evalStream(mapping, lexer.stream)
-	lexer.Close()
-	return val, err
+func Expand(s string, mapping Getter, opts ...Option) (string, error) {
+	if len(opts) == 0 && noOptions.literal(s) {
+		// return strings without expansions before allocating an Expander
+		return s, nil
+	}
+	return NewExpander(opts...).Expand(s, mapping)
+}
+
+// noOptions is the Expander used by Expand without options.
+var noOptions Expander
+
+// Expander holds the options for expansion behaviors that are not enabled by
+// default. The zero value expands the same as Expand.
+type Expander struct {
+	// Dialect selects the shell syntax to recognize, defaulting to POSIX.
+	Dialect Dialect
+
+	// Tilde enables tilde expansion of an unquoted "~" or "~user" prefix at
+	// the start of the string, at the start of the word in a parameter
+	// expansion, or after the "=" or an unquoted ":" in the value of a
+	// leading assignment, like PATH=~/bin:~/sbin. The home directory for
+	// "~" is looked up like a reference to $HOME.
+	Tilde bool
+
+	// UserHome resolves the home directory for "~user" prefixes. When nil,
+	// only "~" is expanded, using the value of HOME from the mapping.
+	UserHome UserHomeFunc
+
+	// QuoteRemoval interprets quotes and backslashes in the whole string,
+	// removing them from the result the same as a shell word, instead of
+	// only inside of parameter expansions. For example, with QuoteRemoval
+	// `"$x" '$y'` expands to the value of x followed by the text " $y".
+	QuoteRemoval bool
+
+	// StableErrors returns errors with messages that are kept the same
+	// between versions of this package, for callers that match on the text
+	// of error messages. The messages do not depend on the locale, and the
+	// original error is still available with errors.Unwrap.
+	//
+	// The stable messages are listed below, where c is the closing
+	// character that was missing, and [$] is a $ written only before a
+	// positional or special parameter, such as $1:
+	//
+	//	unexpected EOF while looking for matching `c'
+	//	bad substitution
+	//	name: parameter null or not set
+	//	[$]name: cannot assign in this way
+	//	name: readonly variable
+	//	[$]name: unbound variable
+	//	[$]name: parameter is empty
+	//	name: variable not allowed
+	//	Option: option can only be set when parsing
+	//
+	// The message of ${name?message} is used as is, and the Compose dialect
+	// keeps the messages of Compose. Errors from the mapping, and
+	// ErrOutputLimit, are returned unchanged.
+	StableErrors bool
+
+	// AllErrors continues expanding after an error, replacing the failed
+	// expansion with an empty string, and returns all of the errors joined
+	// with errors.Join. Syntax errors still stop the expansion.
+	AllErrors bool
+
+	// Assign controls whether the "=" operator assigns to the mapping.
+	Assign AssignMode
+
+	// NoUnset makes a reference to a parameter that is not set an error,
+	// like "set -u" in a shell. Operators that handle unset parameters, such
+	// as ${name:-word}, are still allowed, and so are $@ and $* without any
+	// positional parameters.
+	NoUnset bool
+
+	// NoEmpty makes a reference to a parameter that is set to an empty value
+	// an error. Operators that replace an empty value, such as
+	// ${name:-word}, are still allowed. Combined with NoUnset, every
+	// reference without a default must have a value.
+	NoEmpty bool
+
+	// KeepUnset leaves references to variables that are not set in the
+	// mapping in the result, including any operator and its word, instead
+	// of expanding them. This allows expanding a string in stages, where a
+	// later stage fills in the remaining variables. A reference written as
+	// $name is kept that way when the following text can't continue the
+	// name, and otherwise written as ${name}, with the word of an operator
+	// in an equivalent form. Positional and special parameters are always
+	// expanded.
+	KeepUnset bool
+
+	// OnUnset is called for a variable that is not set in the mapping, and
+	// returns the value to use for it. It may return ErrUnset to leave the
+	// variable unset, or any other error to stop the expansion.
+	OnUnset func(name string) (string, error)
+
+	// Allow restricts expansion to the listed variables, like the argument
+	// to envsubst. References to other variables are left in the result
+	// unchanged, or are an error with DisallowError. When nil, all
+	// variables are expanded. Positional and special parameters are always
+	// expanded.
+	Allow []string
+
+	// DisallowError makes a reference to a variable that is not in the
+	// Allow list an error, rather than leaving it in the result.
+	DisallowError bool
+
+	// VarsOnly leaves references to positional and special parameters,
+	// such as $1, $# and $$, in the result unchanged, like envsubst, which
+	// only expands variables.
+	VarsOnly bool
+
+	// MaxOutput limits the length in bytes of the result. When the
+	// expansion produces more output, it stops and returns ErrOutputLimit.
+	// When zero, the output is not limited.
+	MaxOutput int
+
+	// IFS is the set of field separators used by ExpandFields when the
+	// mapping does not set IFS. When empty, the default of space, tab and
+	// newline is used.
+	IFS string
+
+	// DottedNames allows names made of parts separated by dots in braced
+	// references, such as ${server.port}, for mappings with nested values
+	// like JSONGetter. Parts after the first may be numbers, such as
+	// ${items.0}.
+	DottedNames bool
+
+	// Memoize remembers the value of each variable the first time it is
+	// looked up in an expansion, so a mapping backed by remote calls is
+	// only asked once even when a template references a variable many
+	// times. Assignments update the remembered values, and lookup errors
+	// are not remembered. Values are not shared between expansions, see
+	// CachedGetter for that.
+	Memoize bool
+
+	// JSONTypes is a type hint for ExpandJSON, which replaces a string that
+	// is exactly one reference, such as "${PORT}", with the JSON value of
+	// its expansion, such as the number 8080. An expansion that is not
+	// valid JSON stays a string.
+	JSONTypes bool
+}
+
+// Dialect selects the shell syntax recognized by an Expander.
+type Dialect int
+
+const (
+	// POSIX recognizes the expansions specified by POSIX, and reports
+	// bash extensions like ${name^^} as errors.
+	POSIX Dialect = iota
+
+	// Bash also recognizes bash extensions, such as brace expansion of
+	// "a{b,c}" into "ab ac", or "{1..3}" into "1 2 3".
+	Bash
+
+	// Kubernetes recognizes the $(name) references of container
+	// environment variables, commands and arguments, where $$ is an
+	// escaped "$". Any other "$" is literal, and a reference to a variable
+	// that is not set is left unchanged, like with KeepUnset. Quotes,
+	// backslashes and tildes are always literal, so the QuoteRemoval and
+	// Tilde options have no effect.
+	Kubernetes
+
+	// Compose recognizes the interpolation of the Compose specification,
+	// used by docker compose: $name and ${name} references with the "-",
+	// "?" and "+" operators, and $$ as an escaped "$". There are no
+	// positional or special parameters and no assignments, and quotes,
+	// backslashes and tildes are literal. Errors have the same messages as
+	// Compose, such as "required variable NAME is missing a value".
+	Compose
+
+	// Windows recognizes the %name% references of the Windows command
+	// prompt, where %% is an escaped "%". A "%" without a closing "%" on
+	// the same line is literal, and names may contain any other
+	// characters, such as spaces. Like in a batch file, a variable that is
+	// not set expands to an empty string, or is left unchanged with
+	// KeepUnset. Use NewFoldGetter for names that are not case-sensitive.
+	// Quotes, backslashes and tildes are always literal.
+	Windows
+
+	// Make recognizes the $(name) and ${name} references of Makefiles and
+	// many CI systems, where $$ is an escaped "$". Any other "$" is
+	// literal, including single-letter references like $x and Make
+	// functions like $(shell ls), whose names are not valid. Like in Make,
+	// a variable that is not set expands to an empty string, or is left
+	// unchanged with KeepUnset. Quotes, backslashes and tildes are always
+	// literal.
+	Make
+)
+
+// sigil returns the character that starts the expansions of the dialect.
+func (d Dialect) sigil() byte {
+	if d == Windows {
+		return '%'
+	}
+	return '$'
+}
+
+// AssignMode selects how an Expander handles assignments like ${name=word}.
+type AssignMode int
+
+const (
+	// AssignMapping assigns to the mapping when it implements Setter, and
+	// otherwise returns an error.
+	AssignMapping AssignMode = iota
+
+	// AssignError returns an error for any assignment, even when the
+	// mapping implements Setter, so templates cannot change the mapping.
+	AssignError
+
+	// AssignIgnore expands assignments the same as ${name-word}, using the
+	// word without assigning it.
+	AssignIgnore
+)
+
+// Expand replaces ${var} or $var in the string based on the mapping, using
+// the options set on the Expander.
+func (e *Expander) Expand(s string, mapping Getter) (string, error) {
+	if e.literal(s) {
+		return s, nil
+	}
+	t, err := e.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(mapping)
+}
+
+// literal reports whether the string expands to itself with the options,
+// so it does not need to be parsed, such as when it contains no "$".
+func (e *Expander) literal(s string) bool {
+	if strings.IndexByte(s, e.Dialect.sigil()) >= 0 {
+		return false
+	}
+	if e.QuoteRemoval && strings.ContainsAny(s, `'"\`) {
+		return false
+	}
+	if e.Tilde && strings.IndexByte(s, '~') >= 0 {
+		return false
+	}
+	if e.Dialect == Bash && strings.IndexByte(s, '{') >= 0 {
+		return false
+	}
+	return e.MaxOutput <= 0 || len(s) <= e.MaxOutput
+}
+
+// wrapError applies the error options of the Expander.
+func (e *Expander) wrapError(err error) error {
+	if err != nil && e.StableErrors {
+		return stabilize(err)
+	}
+	return err
 }
 
 // ExpandEnv replaces ${var} or $var in the string according to the values of
-// the current environment variables.
+// the current environment variables. On Windows, variable names are
+// case-insensitive, the same as with a FoldGetter.
 func ExpandEnv(s string) (string, error) {
 	return Expand(s, osEnviron)
 }
 
+// EnvironSnapshot returns a Getter for a copy of the current environment
+// variables, so a batch of expansions sees a consistent environment even if
+// it is changed concurrently with os.Setenv. The snapshot cannot be
+// assigned to.
+func EnvironSnapshot() Getter {
+	return environSnapshot{environGetter(os.Environ())}
+}
+
+type environSnapshot struct {
+	Getter
+}
+
+func (e environSnapshot) GetSpecial(k string) (string, bool) {
+	return osEnviron.GetSpecial(k)
+}
+
 type environGetSetter struct{}
 
 func (e environGetSetter) Get(k string) (string, bool) {
-	return syscall.Getenv(k)
+	return getenv(k)
 }
 
 func (e environGetSetter) Set(k, v string) error {
 	return os.Setenv(k, v)
 }
 
+func (e environGetSetter) GetSpecial(k string) (string, bool) {
+	if k == "0" && len(os.Args) > 0 {
+		return os.Args[0], true
+	}
+	return "", false
+}
+
 var osEnviron environGetSetter = environGetSetter{}
@@ -2,9 +2,12 @@ package posix
 
 import (
 	"fmt"
+	"net/url"
+	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -40,7 +43,8 @@ var paramtests = []struct {
 	// Names, no brackets
 	{"$set", "yes", ""},
 	{"$set$set2", "yesyes-two", ""},
-	// {"$1X$2", "oneXtwo", ""}, // TODO(#3) parse numeric simple names
+	{"$1X$2", "oneXtwo", ""},
+	{"$10", "one0", ""},
 
 	// Default
 	{"${set:-word}", "yes", ""},
@@ -76,6 +80,7 @@ var paramtests = []struct {
 	{"${set+word}", "word", ""},
 	{"${null+word}", "word", ""},
 	{"${unset+word}", "", ""},
+	{"${unset+word}after", "after", ""},
 
 	// Assignment
 	{"${set:=word}", "yes", ""},
@@ -131,6 +136,9 @@ var paramtests = []struct {
 	{`${unset-"\\"}`, `\`, ""},
 	{"${unset-\"\\`\"}", "`", ""},
 
+	// single-quotes are not special in double-quotes
+	{`${unset-"'"}`, `'`, ""},
+
 	// in double-quotes, backslash escape does not apply to other characters:
 	{`${unset-"\a\b\c"}`, `\a\b\c`, ""},
 
@@ -206,3 +214,127 @@ func TestExpand_assign(t *testing.T) {
 	equals(t, "word", x)
 	equals(t, map[string]string{"unset": "word"}, mapping)
 }
+
+func TestExpand_assignError(t *testing.T) {
+	mapping := map[string]string{}
+	_, err := Expand("${unset:=word}", RWMap(mapping), WithAssign(AssignError))
+	equals(t, "unset: readonly variable", err.Error())
+	equals(t, map[string]string{}, mapping)
+
+	x, err := Expand("${set:=word}", RWMap{"set": "x"}, WithAssign(AssignError))
+	ok(t, err)
+	equals(t, "x", x)
+}
+
+func TestExpand_assignIgnore(t *testing.T) {
+	mapping := map[string]string{}
+	x, err := Expand("${unset:=word} ${unset}", RWMap(mapping), WithAssign(AssignIgnore))
+	ok(t, err)
+	equals(t, "word ", x)
+	equals(t, map[string]string{}, mapping)
+
+	x, err = Expand("${unset=word}", Map{}, WithAssign(AssignIgnore))
+	ok(t, err)
+	equals(t, "word", x)
+}
+
+func TestLookupFunc(t *testing.T) {
+	mapping := LookupFunc(func(k string) (string, bool) {
+		v, ok := map[string]string{"set": "yes", "null": ""}[k]
+		return v, ok
+	})
+	x, err := Expand("${set-a},${null-b},${unset-c},${null:-d}", mapping)
+	ok(t, err)
+	equals(t, "yes,,c,d", x)
+
+	_, err = Expand("${unset?is unset}", mapping)
+	equals(t, "is unset", err.Error())
+}
+
+func TestValues(t *testing.T) {
+	q, err := url.ParseQuery("to=a%40example.com&to=b%40example.com&empty=&id=42")
+	ok(t, err)
+	q["none"] = []string{}
+	x, err := Expand("mailto:$to?id=${id}&e=${empty-unset}&n=${none-unset}&m=${missing-unset}", Values(q))
+	ok(t, err)
+	equals(t, "mailto:a@example.com?id=42&e=&n=unset&m=unset", x)
+}
+
+func TestAsExpandFunc(t *testing.T) {
+	mapping := Map{"set": "yes", "null": ""}
+	equals(t, "yes,,?", os.Expand("$set,$null,$unset", AsExpandFunc(mapping, "?")))
+	equals(t, "yes,,", os.Expand("$set,$null,$unset", AsExpandFunc(mapping, "")))
+}
+
+var quoteremovaltests = []struct {
+	in  string
+	out string
+	err string
+}{
+	{`"$set"`, "yes", ""},
+	{`'$set'`, "$set", ""},
+	{`"a  b" c`, "a  b c", ""},
+	{`\"\'\\\$set`, `"'\$set`, ""},
+	{`"it's"`, "it's", ""},
+	{`"\a\$"`, `\a$`, ""},
+	{`"${unset:-"a b"}"`, "a b", ""},
+	{`'abc`, "", "unexpected EOF while looking for matching `''"},
+	{`"abc`, "", "unexpected EOF while looking for matching `\"'"},
+}
+
+func TestExpand_quoteRemoval(t *testing.T) {
+	mapping := Map{"set": "yes"}
+	e := &Expander{QuoteRemoval: true}
+
+	for _, tt := range quoteremovaltests {
+		x, err := e.Expand(tt.in, mapping)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("pattern %#v should have produced error %#v, but got: %s", tt.in, tt.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("pattern %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if x != tt.out {
+			t.Errorf("pattern %#v should expand to %#v, but got %#v", tt.in, tt.out, x)
+		}
+	}
+}
+
+func TestExpand_assignSpecial(t *testing.T) {
+	for _, in := range []string{"${3=foo}", "${?=x}", "${@:=x}", "${10:=x}"} {
+		mapping := RWMap{}
+		_, err := Expand(in, Params{mapping, nil})
+		if err == nil {
+			t.Errorf("pattern %#v should not allow assignment", in)
+			continue
+		}
+		equals(t, "$"+in[2:strings.IndexAny(in, ":=")]+": cannot assign in this way", err.Error())
+		equals(t, RWMap{}, mapping)
+	}
+
+	// set parameters expand without assignment
+	x, err := Expand("${1=foo}", Params{RWMap{}, []string{"a"}})
+	ok(t, err)
+	equals(t, "a", x)
+}
+
+var literaldollartests = []struct {
+	in  string
+	out string
+}{
+	{"$%", "$%"},
+	{"100$ and $set", "100$ and yes"},
+	{"$.$set", "$.yes"},
+	{"a $ b", "a $ b"},
+	{"$", "$"},
+	{"${unset:-$/}", "$/"},
+}
+
+func TestExpand_literalDollar(t *testing.T) {
+	for _, test := range literaldollartests {
+		out, err := Expand(test.in, Map{"set": "yes"})
+		ok(t, err)
+		equals(t, test.out, out)
+	}
+}
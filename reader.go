@@ -0,0 +1,283 @@
+package posix
+
+import (
+	"errors"
+	"io"
+)
+
+// NewReader returns a reader that expands the parameters in the data read
+// from r, like Expand. The data is expanded in parts as it is read, without
+// splitting an expansion across parts, so large inputs do not need to be
+// held in memory. Errors from the expansion are returned by Read, and error
+// positions are relative to the part being expanded.
+func NewReader(r io.Reader, mapping Getter) io.Reader {
+	return new(Expander).NewReader(r, mapping)
+}
+
+// NewReader returns a reader that expands the data read from r like
+// NewReader, using the options set on the Expander. With options that depend
+// on the surrounding text, such as QuoteRemoval, Tilde, or the Bash dialect,
+// the data is only split before a "$" or at the start of a word, outside of
+// any quotes or braces. Memory use is proportional to the largest expansion,
+// quoted string, or word, rather than the size of the input.
+//
+// MaxOutput, Memoize and AllErrors apply to the whole input rather than to
+// each part, and with AllErrors the errors are returned at the end.
+func (e *Expander) NewReader(r io.Reader, mapping Getter) io.Reader {
+	return &reader{r: r, split: e.splitter(), st: &stream{e: e, mapping: mapping}}
+}
+
+type reader struct {
+	r     io.Reader
+	split *splitter
+	st    *stream
+	in    []byte // input that has not been expanded yet
+	out   string // expanded output that has not been read yet
+	eof   bool
+	err   error
+}
+
+const readerChunk = 32 * 1024
+
+func (r *reader) Read(p []byte) (int, error) {
+	for {
+		if len(r.out) > 0 {
+			n := copy(p, r.out)
+			r.out = r.out[n:]
+			return n, nil
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.eof && len(r.in) == 0 {
+			if r.err = r.st.collected(); r.err != nil {
+				continue
+			}
+			return 0, io.EOF
+		}
+
+		if !r.eof {
+			n, err := r.r.Read(r.grow())
+			r.in = r.in[:len(r.in)+n]
+			if err == io.EOF {
+				r.eof = true
+			} else if err != nil {
+				r.err = err
+			}
+		}
+
+		if n := r.split.prefix(r.in, r.eof); n > 0 {
+			out, err := r.st.expand(string(r.in[:n]))
+			r.in = r.in[:copy(r.in, r.in[n:])]
+			r.out = out
+			if err != nil {
+				r.err = err
+			}
+		}
+	}
+}
+
+// grow returns the free space after the pending input, growing it as needed.
+func (r *reader) grow() []byte {
+	if cap(r.in)-len(r.in) < readerChunk {
+		in := make([]byte, len(r.in), 2*cap(r.in)+readerChunk)
+		copy(in, r.in)
+		r.in = in
+	}
+	return r.in[len(r.in):cap(r.in)]
+}
+
+// stream expands the parts of a stream with the state of a single
+// expansion, so the output limit, memoized values and collected errors are
+// shared by all of the parts.
+type stream struct {
+	e       *Expander
+	mapping Getter
+	memo    map[string]memoValue
+	size    int
+	errs    []error
+}
+
+// expand expands the next part of the stream. With AllErrors, expansion
+// errors are kept for collected rather than returned.
+func (s *stream) expand(part string) (string, error) {
+	if s.e.literal(part) {
+		s.size += len(part)
+		if s.e.MaxOutput > 0 && s.size > s.e.MaxOutput {
+			return "", ErrOutputLimit
+		}
+		return part, nil
+	}
+	t, err := s.e.Parse(part)
+	if err != nil {
+		return "", err
+	}
+	ev := &evaluator{mapping: s.mapping, opts: &t.opts, src: t.src, memo: s.memo, size: s.size}
+	segs, err := evalNodes(ev, nil, t.Nodes)
+	s.memo, s.size = ev.memo, ev.size
+	if err != nil {
+		return "", s.e.wrapError(err)
+	}
+	s.errs = append(s.errs, ev.errs...)
+	return segs.String(), nil
+}
+
+// collected returns the errors collected from all of the parts with the
+// AllErrors option, or nil if there were none.
+func (s *stream) collected() error {
+	if len(s.errs) == 0 {
+		return nil
+	}
+	err := s.e.wrapError(errors.Join(s.errs...))
+	s.errs = nil
+	return err
+}
+
+// splitter finds where input can be divided into parts that expand the same
+// separately as they would together.
+type splitter struct {
+	anywhere bool // text outside of expansions can be split at any point
+	quotes   bool // quotes must not be split
+	braces   bool // bash brace expressions and their words must not be split
+	tilde    bool // a part must not start with a tilde-prefix or assignment
+	escapes  bool // a backslash escapes the next character
+	dialect  Dialect
+}
+
+func (e *Expander) splitter() *splitter {
+	return &splitter{
+		anywhere: !e.QuoteRemoval && !e.Tilde && e.Dialect != Bash,
+		quotes:   e.QuoteRemoval,
+		braces:   e.Dialect == Bash,
+		tilde:    e.Tilde,
+		escapes:  e.Dialect == POSIX || e.Dialect == Bash,
+		dialect:  e.Dialect,
+	}
+}
+
+// prefix returns the length of the longest prefix of the input that can be
+// expanded by itself. At the end of the input, the whole input is returned.
+// Otherwise the input is split before an expansion or, when it can't be
+// split anywhere, at the start of a word, so only a single word or
+// expansion needs to be held in memory.
+func (s *splitter) prefix(in []byte, eof bool) int {
+	if eof {
+		return len(in)
+	}
+	last := 0
+	var quote byte
+	depth := 0
+	for i := 0; i < len(in); {
+		c := in[i]
+		neutral := quote == 0 && depth == 0
+		switch {
+		case c == '\\' && quote != '\'' && s.escapes:
+			if i+1 >= len(in) {
+				return last
+			}
+			i += 2
+		case s.quotes && quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+			i++
+		case s.quotes && quote == c:
+			quote = 0
+			i++
+		case c == s.dialect.sigil() && quote != '\'':
+			// brace expansion applies to whole words, and a tilde-prefix
+			// ends at the "$", so with either the input is only split at
+			// the start of a word, below
+			if neutral && i > 0 && !s.braces && !s.tilde {
+				last = i
+			}
+			end := s.refEnd(in[i:])
+			if end < 0 {
+				return last
+			}
+			i += end
+		case s.braces && quote == 0 && c == '{':
+			depth++
+			i++
+		case s.braces && quote == 0 && c == '}' && depth > 0:
+			depth--
+			i++
+		case !s.anywhere && neutral && (c == ' ' || c == '\t' || c == '\n'):
+			i++
+			if i == len(in) {
+				// whether the next word can start a part is not known yet
+				return last
+			}
+			if s.wordStart(in[i:]) {
+				last = i
+			}
+		default:
+			i++
+		}
+		if s.anywhere && quote == 0 && depth == 0 {
+			last = i
+		}
+	}
+	return last
+}
+
+// wordStart reports whether a part can start with the word at the start of
+// the input. With tilde expansion, a tilde-prefix or assignment is only
+// recognized at the start of the whole input, so it must not start a part.
+func (s *splitter) wordStart(in []byte) bool {
+	if !s.tilde {
+		return true
+	}
+	if in[0] == '~' {
+		return false
+	}
+	for i, c := range in {
+		if !isAlphaNum(rune(c)) {
+			return c != '='
+		}
+		if i == len(in)-1 {
+			// the name may continue as an assignment
+			return false
+		}
+	}
+	return true
+}
+
+// refEnd returns the length of the parameter reference at the start of the
+// input, like refEnd, for the references of the dialect.
+func (s *splitter) refEnd(in []byte) int {
+	switch s.dialect {
+	case Kubernetes:
+		return parenRefEnd(in)
+	case Windows:
+		return percentRefEnd(in)
+	case Make:
+		return makeRefEnd(string(in))
+	case Compose:
+		if len(in) >= 2 && in[1] == '$' {
+			return 2
+		}
+	}
+	return refEnd(in)
+}
+
+// refEnd returns the length of the parameter reference at the start of the
+// input, 1 if the "$" does not start a reference, or -1 if the reference may
+// continue past the end of the input.
+func refEnd(in []byte) int {
+	if len(in) < 2 {
+		return -1
+	}
+	switch {
+	case in[1] == '{':
+		return scriptRefEnd(string(in))
+	case isAlpha(rune(in[1])):
+		i := 2
+		for i < len(in) && isAlphaNum(rune(in[i])) {
+			i++
+		}
+		if i == len(in) {
+			return -1
+		}
+		return i
+	}
+	return 1
+}
@@ -0,0 +1,167 @@
+package posix
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var readertests = []struct {
+	in  string
+	out string
+}{
+	{"plain text", "plain text"},
+	{"$a ${b} ${c:-$a}", "1 2 1"},
+	{"${unset:-${b}x}$a", "2x1"},
+	{`\$a $a\`, `$a 1\`},
+	{"$", "$"},
+	{"$abc", ""},
+}
+
+func TestNewReader(t *testing.T) {
+	mapping := Map{"a": "1", "b": "2"}
+	for _, test := range readertests {
+		// read one byte at a time, so expansions are split across reads
+		r := NewReader(iotest.OneByteReader(strings.NewReader(test.in)), mapping)
+		out, err := io.ReadAll(r)
+		ok(t, err)
+		equals(t, test.out, string(out))
+	}
+}
+
+func TestNewReader_options(t *testing.T) {
+	e := NewExpander(WithQuoteRemoval(), WithTilde(nil), WithDialect(Bash))
+	in := `~/x "$a b" '$a' {1,2}$a $a{3,4}`
+	want, err := e.Expand(in, Map{"a": "A", "HOME": "/home/me"})
+	ok(t, err)
+
+	r := e.NewReader(iotest.OneByteReader(strings.NewReader(in)), Map{"a": "A", "HOME": "/home/me"})
+	out, err := io.ReadAll(r)
+	ok(t, err)
+	equals(t, want, string(out))
+}
+
+func TestNewReader_large(t *testing.T) {
+	line := "${a}-$b\n"
+	in := strings.Repeat(line, 20000)
+	r := NewReader(strings.NewReader(in), Map{"a": "1", "b": "2"})
+	out, err := io.ReadAll(r)
+	ok(t, err)
+	equals(t, strings.Repeat("1-2\n", 20000), string(out))
+}
+
+func TestNewReader_error(t *testing.T) {
+	r := NewReader(strings.NewReader("ok $a ${b:?missing}"), Map{"a": "1"})
+	out, err := io.ReadAll(r)
+	equals(t, "missing", err.Error())
+	equals(t, "", string(out))
+}
+
+func TestNewReader_sharedState(t *testing.T) {
+	// the options apply to the whole input, not to each part read
+	in := strings.Repeat("$a ", 1000)
+	e := NewExpander(WithMaxOutput(5000))
+	r := e.NewReader(iotest.OneByteReader(strings.NewReader(in)), Map{"a": "0123456789"})
+	out, err := io.ReadAll(r)
+	equals(t, ErrOutputLimit, err)
+	equals(t, true, len(out) <= 5000)
+
+	m := &countingMap{Map: Map{"a": "1"}}
+	r = NewExpander(WithMemoize()).NewReader(iotest.OneByteReader(strings.NewReader("$a $a $a")), m)
+	out, err = io.ReadAll(r)
+	ok(t, err)
+	equals(t, "1 1 1", string(out))
+	equals(t, 1, m.count("a"))
+
+	r = NewExpander(WithAllErrors()).NewReader(iotest.OneByteReader(strings.NewReader("${b:?} $a ${c:?}")), Map{"a": "1"})
+	out, err = io.ReadAll(r)
+	equals(t, " 1 ", string(out))
+	equals(t, "b: parameter null or not set\nc: parameter null or not set", err.Error())
+}
+
+func TestNewReader_unclosed(t *testing.T) {
+	r := NewReader(iotest.OneByteReader(strings.NewReader("$a ${b")), Map{"a": "1"})
+	out, err := io.ReadAll(r)
+	equals(t, "unexpected EOF while looking for matching `}'", err.Error())
+	equals(t, "1 ", string(out))
+}
+
+var readeroptiontests = []string{
+	"a ~ b ~/x c:~ d=~",
+	"x=~ y=~",
+	"x ~us er ~user ~user.name-x/y",
+	`"a b" 'c d' e\ f`,
+	"{a,b}\\ {c,d} {e,f}  {g,h}",
+	"word\n~\nname=~/x\n\tfoo:~/y",
+	"~$HOME x",
+	"{a,}\\ $HOME",
+}
+
+func TestNewReader_optionsSplit(t *testing.T) {
+	e := NewExpander(WithQuoteRemoval(), WithTilde(func(name string) (string, bool) {
+		return "/home/" + name, true
+	}), WithDialect(Bash))
+	mapping := Map{"HOME": "/home/me"}
+	for _, in := range readeroptiontests {
+		want, err := e.Expand(in, mapping)
+		ok(t, err)
+
+		r := e.NewReader(iotest.OneByteReader(strings.NewReader(in)), mapping)
+		out, err := io.ReadAll(r)
+		ok(t, err)
+		equals(t, want, string(out))
+	}
+}
+
+// repeatReader reads the text repeatedly, up to n bytes.
+type repeatReader struct {
+	text string
+	pos  int
+	n    int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, io.EOF
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.text[r.pos:])
+		r.pos = (r.pos + c) % len(r.text)
+		n += c
+	}
+	r.n -= n
+	return n, nil
+}
+
+func TestNewReader_bounded(t *testing.T) {
+	// text without expansions is split between words with any options, so
+	// only a part of the input is held at a time
+	text := "Some static text, with ~ and \"quotes\" and {a,b}.\n"
+	for i, e := range []*Expander{
+		{},
+		{QuoteRemoval: true},
+		{Tilde: true},
+		{Dialect: Bash},
+	} {
+		r := e.NewReader(&repeatReader{text: text, n: 1 << 20}, Map{"a": "1"}).(*reader)
+		buf := make([]byte, 4096)
+		total, maxPending := 0, 0
+		for {
+			n, err := r.Read(buf)
+			total += n
+			maxPending = max(maxPending, cap(r.in))
+			if err == io.EOF {
+				break
+			}
+			ok(t, err)
+		}
+		if total == 0 || maxPending > 4*readerChunk {
+			t.Errorf("expander %d: read %d bytes, holding up to %d bytes of input", i, total, maxPending)
+		}
+	}
+}
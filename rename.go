@@ -0,0 +1,87 @@
+package posix
+
+import (
+	"context"
+	"strings"
+)
+
+// Rename wraps a mapping so the names of variables are rewritten with the
+// function before they are looked up or assigned, such as EnvStyle to
+// expand templates written with ${server.port} from an environment with
+// SERVER_PORT. Special and positional parameters are not renamed.
+//
+// Assignments, special and positional parameters, lookups that can fail or
+// take a context, and secrets are passed through to the mapping when it
+// supports them.
+func Rename(mapping Getter, rename func(string) string) Getter {
+	return &renamed{Getter: mapping, rename: rename}
+}
+
+// EnvStyle converts a name to the style of environment variables, in upper
+// case with dots and dashes replaced by underscores, so "server.port"
+// becomes "SERVER_PORT".
+func EnvStyle(name string) string {
+	return envStyleReplacer.Replace(strings.ToUpper(name))
+}
+
+var envStyleReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+type renamed struct {
+	Getter
+	rename func(string) string
+}
+
+// name returns the renamed name of a variable, leaving the names of
+// positional and special parameters unchanged.
+func (r *renamed) name(k string) string {
+	if isParamName(k) && !isName(k) {
+		return k
+	}
+	return r.rename(k)
+}
+
+func (r *renamed) Get(k string) (string, bool) {
+	return r.Getter.Get(r.name(k))
+}
+
+func (r *renamed) Set(k, v string) error {
+	if setter, ok := r.Getter.(Setter); ok {
+		return setter.Set(r.name(k), v)
+	}
+	return assignError{k, r.Getter}
+}
+
+func (r *renamed) Lookup(k string) (string, bool, error) {
+	if eg, ok := r.Getter.(ErrGetter); ok {
+		return eg.Lookup(r.name(k))
+	}
+	v, ok := r.Get(k)
+	return v, ok, nil
+}
+
+func (r *renamed) GetContext(ctx context.Context, k string) (string, bool, error) {
+	if cg, ok := r.Getter.(ContextGetter); ok {
+		return cg.GetContext(ctx, r.name(k))
+	}
+	return r.Lookup(k)
+}
+
+func (r *renamed) IsSecret(k string) bool {
+	return isSecret(r.Getter, r.name(k))
+}
+
+func (r *renamed) GetSpecial(k string) (string, bool) {
+	if sg, ok := r.Getter.(SpecialGetter); ok {
+		return sg.GetSpecial(k)
+	}
+	return "", false
+}
+
+func (r *renamed) Positional() []string {
+	args, _ := r.positional()
+	return args
+}
+
+func (r *renamed) positional() ([]string, bool) {
+	return positional(r.Getter)
+}
@@ -0,0 +1,47 @@
+package posix
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	env := RWMap{"SERVER_PORT": "8080", "LOG_LEVEL": "debug"}
+	mapping := Rename(env, EnvStyle)
+
+	out, err := Expand("${server.port} ${missing.key-unset} $log_level ${new.key:=x}", mapping, WithDottedNames())
+	ok(t, err)
+	equals(t, "8080 unset debug x", out)
+	equals(t, "x", env["NEW_KEY"])
+}
+
+func TestRename_passThrough(t *testing.T) {
+	mapping := Rename(Params{Getter: secretStore{"DB_PASSWORD": "pw"}, Args: []string{"a"}}, strings.ToUpper)
+	out, err := Expand("$1 $db_password", mapping)
+	ok(t, err)
+	equals(t, "a pw", out)
+
+	tenant := context.WithValue(context.Background(), tenantKey{}, "acme")
+	out, err = ExpandContext(tenant, "$tenant", Rename(secretStore{}, strings.ToUpper))
+	ok(t, err)
+	equals(t, "acme", out)
+
+	_, err = Expand("${x:?$s}", Rename(Secrets(Map{"S": "s"}, "S"), strings.ToUpper))
+	equals(t, Redacted, err.Error())
+
+	_, err = Expand("${x:=y}", Rename(Map{}, strings.ToUpper))
+	equals(t, "mapping type posix.Map does not support assignment", err.Error())
+
+	// positional and special parameters from a Map are not renamed
+	prefixed := func(name string) string { return "APP_" + name }
+	out, err = Expand("$? $1 $# $name", Rename(Map{"?": "0", "1": "one", "#": "1", "APP_name": "x"}, prefixed))
+	ok(t, err)
+	equals(t, "0 one 1 x", out)
+}
+
+func TestEnvStyle(t *testing.T) {
+	equals(t, "SERVER_PORT", EnvStyle("server.port"))
+	equals(t, "DRY_RUN", EnvStyle("dry-run"))
+	equals(t, "PATH", EnvStyle("PATH"))
+}
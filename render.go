@@ -0,0 +1,192 @@
+package posix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FileSpec describes a template file for RenderFiles.
+type FileSpec struct {
+	// Source is the path of the template to expand.
+	Source string
+
+	// Destination is the path to write the expanded template to.
+	Destination string
+
+	// Mode is the permission of the written file. When zero, the permission
+	// of the source file is used.
+	Mode fs.FileMode
+}
+
+// Manifest describes the files processed by RenderFiles.
+type Manifest struct {
+	// Written lists the destinations that were created or updated.
+	Written []string
+
+	// Skipped lists the destinations that already had the expanded content
+	// and permissions, and were left unchanged.
+	Skipped []string
+
+	// Vars lists the variables referenced by each file, in the order they
+	// were first used, keyed by destination.
+	Vars map[string][]string
+}
+
+// RenderFiles expands each source file against the mapping and writes the
+// result to its destination, creating missing directories.
+//
+// Files are processed in order and share the mapping, so variables assigned
+// by one file are visible to the following files. Each destination is
+// written by renaming a temporary file, so it is never left partially
+// written. Rendering stops at the first error, returning the manifest of the
+// files processed so far.
+func RenderFiles(specs []FileSpec, mapping Getter) (Manifest, error) {
+	m := Manifest{Vars: make(map[string][]string)}
+	for _, spec := range specs {
+		written, vars, err := renderFile(spec, mapping)
+		if err != nil {
+			return m, err
+		}
+		m.Vars[spec.Destination] = vars
+		if written {
+			m.Written = append(m.Written, spec.Destination)
+		} else {
+			m.Skipped = append(m.Skipped, spec.Destination)
+		}
+	}
+	return m, nil
+}
+
+func renderFile(spec FileSpec, mapping Getter) (bool, []string, error) {
+	src, err := os.ReadFile(spec.Source)
+	if err != nil {
+		return false, nil, err
+	}
+	mode := spec.Mode.Perm()
+	if mode == 0 {
+		info, err := os.Stat(spec.Source)
+		if err != nil {
+			return false, nil, err
+		}
+		mode = info.Mode().Perm()
+	}
+
+	recorder, recording := newVarRecorder(mapping)
+	out, err := Expand(string(src), recording)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", spec.Source, err)
+	}
+	vars := recorder.names
+
+	if info, err := os.Stat(spec.Destination); err == nil && info.Mode().Perm() == mode {
+		existing, err := os.ReadFile(spec.Destination)
+		if err == nil && bytes.Equal(existing, []byte(out)) {
+			return false, vars, nil
+		}
+	}
+
+	if err := writeFileAtomic(spec.Destination, []byte(out), mode); err != nil {
+		return false, vars, err
+	}
+	return true, vars, nil
+}
+
+// writeFileAtomic writes the file by renaming a temporary file over it.
+func writeFileAtomic(name string, data []byte, mode fs.FileMode) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// varRecorder is a Getter recording the names of the variables looked up.
+// Lookup errors, special and positional parameters, and secrets are passed
+// through to the mapping.
+type varRecorder struct {
+	mapping Getter
+	names   []string
+	seen    map[string]bool
+}
+
+// varRecordSetter is a varRecorder for mappings that support assignment.
+type varRecordSetter struct {
+	*varRecorder
+	setter Setter
+}
+
+func (r varRecordSetter) Set(k, v string) error {
+	return r.setter.Set(k, v)
+}
+
+// newVarRecorder wraps the mapping to record the variables looked up,
+// returning the recorder and the Getter to expand with, which keeps support
+// for assignment if the mapping is a Setter.
+func newVarRecorder(mapping Getter) (*varRecorder, Getter) {
+	r := &varRecorder{mapping: mapping, seen: make(map[string]bool)}
+	if setter, ok := mapping.(Setter); ok {
+		return r, varRecordSetter{r, setter}
+	}
+	return r, r
+}
+
+func (r *varRecorder) Get(k string) (string, bool) {
+	r.record(k)
+	return r.mapping.Get(k)
+}
+
+func (r *varRecorder) Lookup(k string) (string, bool, error) {
+	r.record(k)
+	return lookupIn(r.mapping, k)
+}
+
+func (r *varRecorder) GetContext(ctx context.Context, k string) (string, bool, error) {
+	r.record(k)
+	return getContext(ctx, r.mapping, k)
+}
+
+func (r *varRecorder) IsSecret(k string) bool {
+	return isSecret(r.mapping, k)
+}
+
+func (r *varRecorder) GetSpecial(k string) (string, bool) {
+	return getSpecial(r.mapping, k)
+}
+
+func (r *varRecorder) Positional() []string {
+	args, _ := r.positional()
+	return args
+}
+
+func (r *varRecorder) positional() ([]string, bool) {
+	return positional(r.mapping)
+}
+
+// record adds the name to the variables looked up, if it is new.
+func (r *varRecorder) record(k string) {
+	if !r.seen[k] {
+		r.seen[k] = true
+		r.names = append(r.names, k)
+	}
+}
@@ -0,0 +1,76 @@
+package posix
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderFiles(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	out := filepath.Join(dir, "out")
+	ok(t, os.Mkdir(src, 0o755))
+	ok(t, os.WriteFile(filepath.Join(src, "a.conf"), []byte("host=${host}\nport=${port:=80}\n"), 0o644))
+	ok(t, os.WriteFile(filepath.Join(src, "b.sh"), []byte("echo $port $host\n"), 0o755))
+
+	specs := []FileSpec{
+		{Source: filepath.Join(src, "a.conf"), Destination: filepath.Join(out, "a.conf")},
+		{Source: filepath.Join(src, "b.sh"), Destination: filepath.Join(out, "bin", "b.sh")},
+		{Source: filepath.Join(src, "b.sh"), Destination: filepath.Join(out, "b.txt"), Mode: 0o600},
+	}
+	mapping := RWMap{"host": "example.com"}
+
+	m, err := RenderFiles(specs, mapping)
+	ok(t, err)
+	equals(t, []string{specs[0].Destination, specs[1].Destination, specs[2].Destination}, m.Written)
+	equals(t, []string(nil), m.Skipped)
+	equals(t, []string{"host", "port"}, m.Vars[specs[0].Destination])
+	equals(t, []string{"port", "host"}, m.Vars[specs[1].Destination])
+
+	data, err := os.ReadFile(specs[0].Destination)
+	ok(t, err)
+	equals(t, "host=example.com\nport=80\n", string(data))
+	data, err = os.ReadFile(specs[1].Destination)
+	ok(t, err)
+	equals(t, "echo 80 example.com\n", string(data))
+
+	info, err := os.Stat(specs[1].Destination)
+	ok(t, err)
+	equals(t, os.FileMode(0o755), info.Mode().Perm())
+	info, err = os.Stat(specs[2].Destination)
+	ok(t, err)
+	equals(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// rendering again leaves the files unchanged
+	m, err = RenderFiles(specs, mapping)
+	ok(t, err)
+	equals(t, []string(nil), m.Written)
+	equals(t, []string{specs[0].Destination, specs[1].Destination, specs[2].Destination}, m.Skipped)
+
+	// changing the mode rewrites the file
+	specs[2].Mode = 0o640
+	m, err = RenderFiles(specs[2:], mapping)
+	ok(t, err)
+	equals(t, []string{specs[2].Destination}, m.Written)
+}
+
+func TestRenderFiles_error(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.tmpl")
+	ok(t, os.WriteFile(src, []byte("${missing:?required}"), 0o644))
+
+	_, err := RenderFiles([]FileSpec{{Source: src, Destination: filepath.Join(dir, "a")}}, Map{})
+	equals(t, src+": required", err.Error())
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Errorf("destination should not be written on error")
+	}
+
+	ok(t, os.WriteFile(src, []byte("pw=$FAIL\n"), 0o644))
+	_, err = RenderFiles([]FileSpec{{Source: src, Destination: filepath.Join(dir, "a")}}, remoteMap{})
+	equals(t, true, errors.Is(err, errLookup))
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Errorf("destination should not be written on a lookup error")
+	}
+}
@@ -0,0 +1,103 @@
+package posix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Scanner expands its input line by line, like bufio.Scanner. Assignments
+// made by earlier lines are visible to later ones, as for ExpandAll.
+//
+// By default scanning stops at the first line that fails to expand. With
+// ContinueOnError set, the lines after a failure are still expanded, and Err
+// joins the errors of all the failed lines. Each error is a LineError.
+type Scanner struct {
+	// ContinueOnError continues scanning after a line fails to expand.
+	ContinueOnError bool
+
+	s       *bufio.Scanner
+	e       *Expander
+	mapping Getter
+	line    int
+	text    string
+	lineErr error
+	errs    []error
+}
+
+// NewScanner returns a Scanner expanding the lines read from r.
+func NewScanner(r io.Reader, mapping Getter) *Scanner {
+	return new(Expander).NewScanner(r, mapping)
+}
+
+// NewScanner returns a Scanner like NewScanner, using the options set on the
+// Expander.
+func (e *Expander) NewScanner(r io.Reader, mapping Getter) *Scanner {
+	if _, ok := mapping.(Setter); !ok {
+		mapping = &overlay{Getter: mapping, vars: make(map[string]string)}
+	}
+	return &Scanner{s: bufio.NewScanner(r), e: e, mapping: mapping}
+}
+
+// Buffer sets the buffer used to read lines, as for bufio.Scanner.
+func (s *Scanner) Buffer(buf []byte, max int) {
+	s.s.Buffer(buf, max)
+}
+
+// Scan expands the next line, which is then available through Text. It
+// returns false when the input ends or scanning stops after an error.
+func (s *Scanner) Scan() bool {
+	if s.lineErr != nil && !s.ContinueOnError {
+		return false
+	}
+	s.text, s.lineErr = "", nil
+	if !s.s.Scan() {
+		return false
+	}
+	s.line++
+	text, err := s.e.Expand(s.s.Text(), s.mapping)
+	if err != nil {
+		s.lineErr = &LineError{Line: s.line, Err: err}
+		s.errs = append(s.errs, s.lineErr)
+		return s.ContinueOnError
+	}
+	s.text = text
+	return true
+}
+
+// Text returns the expanded text of the current line, without the line
+// ending. It is empty if the line failed to expand.
+func (s *Scanner) Text() string {
+	return s.text
+}
+
+// Line returns the 1-based number of the current line.
+func (s *Scanner) Line() int {
+	return s.line
+}
+
+// LineErr returns the error expanding the current line, if any.
+func (s *Scanner) LineErr() error {
+	return s.lineErr
+}
+
+// Err returns the errors of the lines that failed to expand, joined with any
+// error reading the input.
+func (s *Scanner) Err() error {
+	return errors.Join(append(s.errs, s.s.Err())...)
+}
+
+// LineError is an error expanding one line of the input of a Scanner.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,59 @@
+package posix
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	s := NewScanner(strings.NewReader("home=$HOME\n${DIR:=/tmp}\n\ndir=$DIR\n"), Map{"HOME": "/home/me"})
+	var lines []string
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	ok(t, s.Err())
+	equals(t, []string{"home=/home/me", "/tmp", "", "dir=/tmp"}, lines)
+	equals(t, 4, s.Line())
+}
+
+func TestScanner_stopOnError(t *testing.T) {
+	s := NewScanner(strings.NewReader("a\n${X:?is required}\nc\n"), Map{})
+	var lines []string
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	equals(t, []string{"a"}, lines)
+	equals(t, 2, s.Line())
+	equals(t, "line 2: is required", s.Err().Error())
+
+	var lineErr *LineError
+	if !errors.As(s.Err(), &lineErr) {
+		t.Fatalf("expected a LineError, got: %#v", s.Err())
+	}
+	equals(t, 2, lineErr.Line)
+}
+
+func TestScanner_lookupError(t *testing.T) {
+	s := NewScanner(strings.NewReader("$A\n$FAIL\n"), remoteMap{"A": "a"})
+	for s.Scan() {
+	}
+	equals(t, 2, s.Line())
+	equals(t, true, errors.Is(s.Err(), errLookup))
+}
+
+func TestScanner_continueOnError(t *testing.T) {
+	s := NewScanner(strings.NewReader("a\n${X:?is required}\n$Y\n${\n"), Map{"Y": "y"})
+	s.ContinueOnError = true
+	var lines []string
+	var lineErrs []int
+	for s.Scan() {
+		lines = append(lines, s.Text())
+		if s.LineErr() != nil {
+			lineErrs = append(lineErrs, s.Line())
+		}
+	}
+	equals(t, []string{"a", "", "y", ""}, lines)
+	equals(t, []int{2, 4}, lineErrs)
+	equals(t, "line 2: is required\nline 4: unexpected EOF while looking for matching `}'", s.Err().Error())
+}
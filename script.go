@@ -0,0 +1,520 @@
+package posix
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ExpandInScript expands the parameters in the source of a shell script that
+// are safe to resolve statically, leaving everything else in the script
+// unchanged.
+//
+// Only parameter expansions inside double-quoted strings, in the values of
+// variable assignments, and in the bodies of unquoted here-documents are
+// expanded, and only when every parameter they reference is set in the
+// mapping. Expanded values are quoted so they cannot change the structure of
+// the script. Other references, including positional and special parameters,
+// command substitutions, and expansions that would assign a value, are kept
+// as written so they are still evaluated when the script runs.
+func ExpandInScript(src string, mapping Getter) (string, error) {
+	s := &scriptExpander{src: src, mapping: mapping, cmdStart: true}
+	if err := s.run(); err != nil {
+		return "", err
+	}
+	return s.out.String(), nil
+}
+
+type heredoc struct {
+	delim  string
+	quoted bool
+	strip  bool
+}
+
+type scriptExpander struct {
+	src     string
+	pos     int
+	out     strings.Builder
+	mapping Getter
+
+	// the next word is at the start of a command, where it may be an assignment
+	cmdStart bool
+	// the words of the command are assignments, as for export or readonly
+	declaration bool
+	// the here-documents to read after the end of the current line
+	heredocs []heredoc
+}
+
+// Keywords after which a new command starts
+var scriptReserved = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "do": true,
+	"while": true, "until": true, "!": true, "{": true, "}": true,
+}
+
+// Commands whose arguments are assignments
+var scriptDeclarations = map[string]bool{
+	"export": true, "readonly": true, "local": true, "declare": true, "typeset": true,
+}
+
+func (s *scriptExpander) run() error {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			s.copy(1)
+			s.cmdStart = true
+			s.declaration = false
+			if err := s.heredocBodies(); err != nil {
+				return err
+			}
+		case c == ' ' || c == '\t':
+			s.copy(1)
+		case c == '#':
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end < 0 {
+				end = len(s.src) - s.pos
+			}
+			s.copy(end)
+		case strings.HasPrefix(s.src[s.pos:], "<<<"):
+			// a here-string's operand is an ordinary word, not a delimiter
+			s.copy(3)
+		case strings.HasPrefix(s.src[s.pos:], "<<"):
+			if err := s.heredocOperator(); err != nil {
+				return err
+			}
+		case strings.IndexByte(";&|()<>", c) >= 0:
+			s.copy(1)
+			if c != '<' && c != '>' {
+				s.cmdStart = true
+				s.declaration = false
+			}
+		default:
+			if err := s.word(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.heredocs) > 0 {
+		return fmt.Errorf("unexpected EOF while looking for here-document delimiter `%s'", s.heredocs[0].delim)
+	}
+	return nil
+}
+
+// word copies a word of the script, expanding it if it is an assignment.
+func (s *scriptExpander) word() error {
+	start := s.pos
+	assignment := false
+	if s.cmdStart || s.declaration {
+		if eq := strings.IndexByte(s.src[s.pos:], '='); eq > 0 && isName(s.src[s.pos:s.pos+eq]) {
+			assignment = true
+			s.copy(eq + 1)
+		}
+	}
+
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		if strings.IndexByte(" \t\n;&|()<>", c) >= 0 {
+			break
+		}
+		switch c {
+		case '\\':
+			s.copy(2)
+		case '\'':
+			if err := s.copyQuoted('\'', false); err != nil {
+				return err
+			}
+		case '`':
+			if err := s.copyQuoted('`', true); err != nil {
+				return err
+			}
+		case '"':
+			if err := s.doubleQuoted(); err != nil {
+				return err
+			}
+		case '$':
+			if err := s.dollar(assignment, quoteScriptWord); err != nil {
+				return err
+			}
+		default:
+			s.copy(1)
+		}
+	}
+
+	if !assignment {
+		w := s.src[start:s.pos]
+		s.declaration = s.declaration || s.cmdStart && scriptDeclarations[w]
+		s.cmdStart = scriptReserved[w]
+	}
+	return nil
+}
+
+// doubleQuoted copies a double-quoted string, expanding the parameters in it.
+func (s *scriptExpander) doubleQuoted() error {
+	start := s.pos
+	s.copy(1)
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '"':
+			s.copy(1)
+			return nil
+		case '\\':
+			s.copy(2)
+		case '`':
+			if err := s.copyQuoted('`', true); err != nil {
+				return err
+			}
+		case '$':
+			if err := s.dollar(true, quoteDoubleQuoted); err != nil {
+				return err
+			}
+		default:
+			s.copy(1)
+		}
+	}
+	return fmt.Errorf("unexpected EOF at offset %d while looking for matching `\"'", start)
+}
+
+// dollar copies or expands the text starting with a "$".
+func (s *scriptExpander) dollar(expand bool, quote func(string) string) error {
+	rest := s.src[s.pos:]
+	switch {
+	case strings.HasPrefix(rest, "$'"):
+		s.copy(1)
+		return s.copyQuoted('\'', true)
+	case strings.HasPrefix(rest, "$("):
+		end := matchingParen(rest)
+		if end < 0 {
+			return fmt.Errorf("unexpected EOF at offset %d while looking for matching `)'", s.pos)
+		}
+		s.copy(end)
+		return nil
+	}
+
+	end := scriptRefEnd(rest)
+	if end < 0 {
+		return fmt.Errorf("unexpected EOF at offset %d while looking for matching `}'", s.pos)
+	}
+	if end == 1 {
+		s.copy(1)
+		return nil
+	}
+	if expand {
+		val, ok, err := s.expandRef(rest[:end])
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.out.WriteString(quote(val))
+			s.pos += end
+			return nil
+		}
+	}
+	s.copy(end)
+	return nil
+}
+
+// expandRef expands a single parameter reference, or returns false if the
+// reference cannot be resolved statically. Lookup errors from the mapping
+// are returned.
+func (s *scriptExpander) expandRef(ref string) (string, bool, error) {
+	name := strings.TrimPrefix(strings.TrimPrefix(ref[1:], "{"), "#")
+	if i := strings.IndexFunc(name, func(c rune) bool { return !isAlphaNum(c) }); i >= 0 {
+		name = name[:i]
+	}
+	if !isName(name) || strings.Contains(ref, "$(") || strings.Contains(ref, "`") {
+		return "", false, nil
+	}
+	tracker := &missTracker{mapping: s.mapping}
+	val, err := Expand(ref, tracker)
+	if tracker.err != nil {
+		return "", false, err
+	}
+	if err != nil || tracker.missed {
+		return "", false, nil
+	}
+	return val, true, nil
+}
+
+// missTracker is a read-only Getter recording whether any lookups were
+// unset, and the first lookup error. Special and positional parameters and
+// secrets are passed through to the mapping.
+type missTracker struct {
+	mapping Getter
+	missed  bool
+	err     error
+}
+
+func (m *missTracker) Get(k string) (string, bool) {
+	v, ok := m.mapping.Get(k)
+	m.missed = m.missed || !ok
+	return v, ok
+}
+
+func (m *missTracker) Lookup(k string) (string, bool, error) {
+	return m.track(lookupIn(m.mapping, k))
+}
+
+func (m *missTracker) GetContext(ctx context.Context, k string) (string, bool, error) {
+	return m.track(getContext(ctx, m.mapping, k))
+}
+
+func (m *missTracker) IsSecret(k string) bool {
+	return isSecret(m.mapping, k)
+}
+
+func (m *missTracker) GetSpecial(k string) (string, bool) {
+	return getSpecial(m.mapping, k)
+}
+
+func (m *missTracker) Positional() []string {
+	args, _ := m.positional()
+	return args
+}
+
+func (m *missTracker) positional() ([]string, bool) {
+	return positional(m.mapping)
+}
+
+// track records the result of a lookup.
+func (m *missTracker) track(v string, ok bool, err error) (string, bool, error) {
+	m.missed = m.missed || !ok
+	if m.err == nil {
+		m.err = err
+	}
+	return v, ok, err
+}
+
+// heredocOperator copies a "<<" redirection and its delimiter word, queueing
+// the here-document to be read after the end of the line.
+func (s *scriptExpander) heredocOperator() error {
+	s.copy(2)
+	doc := heredoc{}
+	if s.pos < len(s.src) && s.src[s.pos] == '-' {
+		doc.strip = true
+		s.copy(1)
+	}
+	for s.pos < len(s.src) && (s.src[s.pos] == ' ' || s.src[s.pos] == '\t') {
+		s.copy(1)
+	}
+
+	var delim strings.Builder
+	for s.pos < len(s.src) && strings.IndexByte(" \t\n;&|()<>", s.src[s.pos]) < 0 {
+		switch c := s.src[s.pos]; c {
+		case '\'', '"':
+			end := strings.IndexByte(s.src[s.pos+1:], c)
+			if end < 0 {
+				return fmt.Errorf("unexpected EOF at offset %d while looking for matching `%c'", s.pos, c)
+			}
+			delim.WriteString(s.src[s.pos+1 : s.pos+1+end])
+			doc.quoted = true
+			s.copy(end + 2)
+		case '\\':
+			doc.quoted = true
+			if s.pos+1 < len(s.src) {
+				delim.WriteByte(s.src[s.pos+1])
+			}
+			s.copy(2)
+		default:
+			delim.WriteByte(c)
+			s.copy(1)
+		}
+	}
+	doc.delim = delim.String()
+	s.heredocs = append(s.heredocs, doc)
+	return nil
+}
+
+// heredocBodies copies the pending here-documents, expanding the parameters
+// in the ones with an unquoted delimiter.
+func (s *scriptExpander) heredocBodies() error {
+	for len(s.heredocs) > 0 {
+		doc := s.heredocs[0]
+		for {
+			if s.pos >= len(s.src) {
+				return nil
+			}
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end < 0 {
+				end = len(s.src) - s.pos
+			} else {
+				end++
+			}
+			line := s.src[s.pos : s.pos+end]
+			check := strings.TrimSuffix(line, "\n")
+			if doc.strip {
+				check = strings.TrimLeft(check, "\t")
+			}
+			if check == doc.delim {
+				s.copy(end)
+				break
+			}
+			if doc.quoted {
+				s.copy(end)
+				continue
+			}
+			lineEnd := s.pos + end
+			for s.pos < lineEnd {
+				switch s.src[s.pos] {
+				case '\\':
+					s.copy(2)
+				case '`':
+					if err := s.copyQuoted('`', true); err != nil {
+						return err
+					}
+				case '$':
+					if err := s.dollar(true, quoteHeredoc); err != nil {
+						return err
+					}
+				default:
+					s.copy(1)
+				}
+			}
+		}
+		s.heredocs = s.heredocs[1:]
+	}
+	return nil
+}
+
+// copyQuoted copies a quoted string up to the closing quote, optionally
+// skipping quotes escaped by a backslash.
+func (s *scriptExpander) copyQuoted(quote byte, escapes bool) error {
+	start := s.pos
+	for i := s.pos + 1; i < len(s.src); i++ {
+		switch s.src[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			s.copy(i + 1 - s.pos)
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected EOF at offset %d while looking for matching `%c'", start, quote)
+}
+
+// copy copies the next n bytes of the source to the output.
+func (s *scriptExpander) copy(n int) {
+	end := s.pos + n
+	if end > len(s.src) {
+		end = len(s.src)
+	}
+	s.out.WriteString(s.src[s.pos:end])
+	s.pos = end
+}
+
+// scriptRefEnd returns the length of the parameter reference at the start of
+// the string, 1 if the "$" does not start a reference, or -1 if a bracketed
+// reference is not closed.
+func scriptRefEnd(s string) int {
+	if len(s) < 2 {
+		return 1
+	}
+	if s[1] != '{' {
+		i := 1
+		for i < len(s) && isAlphaNum(rune(s[i])) {
+			i++
+		}
+		if i == 1 || isNum(rune(s[1])) {
+			return 1
+		}
+		return i
+	}
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case '"':
+			end := doubleQuoteEnd(s[i:])
+			if end < 0 {
+				return -1
+			}
+			i += end - 1
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// doubleQuoteEnd returns the length of the double-quoted string at the
+// start of s, skipping escaped quotes and quotes nested in ${...}, or -1 if
+// it is not closed.
+func doubleQuoteEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			return i + 1
+		case strings.HasPrefix(s[i:], "${"):
+			end := scriptRefEnd(s[i:])
+			if end < 0 {
+				return -1
+			}
+			i += end - 1
+		}
+	}
+	return -1
+}
+
+// matchingParen returns the length of the "$(...)" at the start of the
+// string, or -1 if it is not closed.
+func matchingParen(s string) int {
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\'', '"', '`':
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// quoteScriptWord quotes a value for use in an unquoted word.
+func quoteScriptWord(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_./:@%+,=-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var (
+	doubleQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	heredocEscaper     = strings.NewReplacer(`\`, `\\`, "$", `\$`, "`", "\\`")
+)
+
+// quoteDoubleQuoted escapes a value for use in a double-quoted string.
+func quoteDoubleQuoted(s string) string {
+	return doubleQuoteEscaper.Replace(s)
+}
+
+// quoteHeredoc escapes a value for use in an unquoted here-document.
+func quoteHeredoc(s string) string {
+	return heredocEscaper.Replace(s)
+}
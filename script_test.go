@@ -0,0 +1,74 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var scripttests = []struct {
+	in  string
+	out string
+}{
+	// double-quoted strings
+	{`echo "hello $name"`, `echo "hello world"`},
+	{`echo "${name:-x} ${#name}"`, `echo "world 5"`},
+	{`echo "$quotes"`, "echo \"a \\\"b\\\" \\$c \\\\ \\`d\\`\""},
+	{`echo "$unset"`, `echo "$unset"`},
+	{`echo "${unset:-$name}"`, `echo "${unset:-$name}"`},
+	{`echo "${name:=x}"`, `echo "world"`},
+	{`echo "$1 $@ $# $?"`, `echo "$1 $@ $# $?"`},
+	{`echo "$(cat $name) \$name"`, `echo "$(cat $name) \$name"`},
+	{`echo "${unset:-"}"}" ${x:-"}"}`, `echo "${unset:-"}"}" ${x:-"}"}`},
+
+	// unquoted words are left alone
+	{`echo $name`, `echo $name`},
+	{`echo '$name' # "$name"`, `echo '$name' # "$name"`},
+	{`cat <<'EOF'` + "\n$name\nEOF\n", `cat <<'EOF'` + "\n$name\nEOF\n"},
+
+	// assignments
+	{`x=$name`, `x=world`},
+	{`x=$spaced; echo $x`, `x='a b; c'\''d'; echo $x`},
+	{`a=1 b=$name cmd c=$name`, `a=1 b=world cmd c=$name`},
+	{`export PATH=$home/bin:$PATH`, `export PATH=/home/me/bin:$PATH`},
+	{"if true; then\n  x=$name\nfi", "if true; then\n  x=world\nfi"},
+	{`for x in $name; do y=$name; done`, `for x in $name; do y=world; done`},
+
+	// here-documents
+	{"cat <<EOF\n$name `date` $unset\nEOF\necho $name", "cat <<EOF\nworld `date` $unset\nEOF\necho $name"},
+	{"cat <<-EOF\n\t$quotes\n\tEOF\n", "cat <<-EOF\n\ta \"b\" \\$c \\\\ \\`d\\`\n\tEOF\n"},
+
+	// here-strings are words, and start no here-document
+	{"cat <<< \"$spaced\"\necho $spaced\nx=$spaced", "cat <<< \"a b; c'd\"\necho $spaced\nx='a b; c'\\''d'"},
+	{"cat <<<$name\necho \"$name\"", "cat <<<$name\necho \"world\""},
+}
+
+func TestExpandInScript(t *testing.T) {
+	mapping := Map{
+		"name":   "world",
+		"home":   "/home/me",
+		"spaced": "a b; c'd",
+		"quotes": "a \"b\" $c \\ `d`",
+	}
+
+	for _, tt := range scripttests {
+		x, err := ExpandInScript(tt.in, mapping)
+		if err != nil {
+			t.Errorf("script %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if x != tt.out {
+			t.Errorf("script %#v should expand to %#v, but got %#v", tt.in, tt.out, x)
+		}
+	}
+}
+
+func TestExpandInScript_errors(t *testing.T) {
+	for _, in := range []string{`echo "abc`, `echo 'abc`, `echo ${abc`, "cat <<EOF\nabc\n"} {
+		if _, err := ExpandInScript(in, Map{}); err == nil {
+			t.Errorf("script %#v should have produced an error", in)
+		}
+	}
+
+	if _, err := ExpandInScript(`x=$A; echo "$FAIL"`, remoteMap{"A": "a"}); !errors.Is(err, errLookup) {
+		t.Errorf("expected a lookup error, got: %v", err)
+	}
+}
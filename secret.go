@@ -0,0 +1,98 @@
+package posix
+
+import (
+	"context"
+	"slices"
+)
+
+// SecretGetter is an optional interface for mappings that hold secrets.
+// The values of variables it reports as secret are redacted from the
+// messages of ${name?word} errors, and an Audit does not record their
+// lengths.
+type SecretGetter interface {
+	IsSecret(name string) bool
+}
+
+// Redacted replaces the value of a secret in error messages.
+const Redacted = "[redacted]"
+
+// Secrets wraps a mapping to mark the named variables as secrets, so a
+// template like ${USER:?cannot log in with $PASSWORD} does not reveal the
+// password in its error.
+//
+// Assignments, special and positional parameters, and lookups that can
+// fail or take a context are passed through to the mapping when it
+// supports them.
+func Secrets(mapping Getter, names ...string) Getter {
+	return &secrets{Getter: mapping, names: append([]string(nil), names...)}
+}
+
+type secrets struct {
+	Getter
+	names []string
+}
+
+func (s *secrets) IsSecret(name string) bool {
+	if slices.Contains(s.names, name) {
+		return true
+	}
+	sg, ok := s.Getter.(SecretGetter)
+	return ok && sg.IsSecret(name)
+}
+
+func (s *secrets) Set(k, v string) error {
+	if setter, ok := s.Getter.(Setter); ok {
+		return setter.Set(k, v)
+	}
+	return assignError{k, s.Getter}
+}
+
+func (s *secrets) Lookup(k string) (string, bool, error) {
+	if eg, ok := s.Getter.(ErrGetter); ok {
+		return eg.Lookup(k)
+	}
+	v, ok := s.Getter.Get(k)
+	return v, ok, nil
+}
+
+func (s *secrets) GetContext(ctx context.Context, k string) (string, bool, error) {
+	return getContext(ctx, s.Getter, k)
+}
+
+func (s *secrets) GetSpecial(k string) (string, bool) {
+	if sg, ok := s.Getter.(SpecialGetter); ok {
+		return sg.GetSpecial(k)
+	}
+	return "", false
+}
+
+func (s *secrets) Positional() []string {
+	args, _ := s.positional()
+	return args
+}
+
+func (s *secrets) positional() ([]string, bool) {
+	return positional(s.Getter)
+}
+
+// isSecret reports whether the mapping marks the variable as a secret.
+func isSecret(mapping Getter, name string) bool {
+	sg, ok := mapping.(SecretGetter)
+	return ok && sg.IsSecret(name)
+}
+
+// redact returns the text of the segments with the values of secrets
+// replaced.
+func (ev *evaluator) redact(segs segments) string {
+	if _, ok := ev.mapping.(SecretGetter); !ok {
+		return segs.String()
+	}
+	redacted := make(segments, len(segs))
+	for i, seg := range segs {
+		if seg.param != "" && isSecret(ev.mapping, seg.param) {
+			seg.text = Redacted
+		}
+		redacted[i] = seg
+	}
+	return redacted.String()
+}
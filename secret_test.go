@@ -0,0 +1,73 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	mapping := Secrets(Map{"PASSWORD": "hunter2", "HOST": "db", "EMPTY": ""}, "PASSWORD")
+
+	out, err := Expand("$HOST:$PASSWORD", mapping)
+	ok(t, err)
+	equals(t, "db:hunter2", out)
+
+	cases := []struct {
+		in  string
+		err string
+	}{
+		{"${USER:?cannot log in to $HOST with $PASSWORD}", "cannot log in to db with [redacted]"},
+		{`${USER:?"pw=${PASSWORD}"}`, "pw=[redacted]"},
+		{"${USER:?${EMPTY:-$PASSWORD}}", "[redacted]"},
+		{"${USER:?${#PASSWORD}}", "7"},
+	}
+	for _, c := range cases {
+		_, err := Expand(c.in, mapping)
+		equals(t, c.err, err.Error())
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) {
+			t.Fatalf("expected an ExpandError, got: %#v", err)
+		}
+		equals(t, c.err, expandErr.Message)
+	}
+}
+
+func TestSecrets_passThrough(t *testing.T) {
+	rw := RWMap{}
+	mapping := Secrets(Params{Getter: rw, Args: []string{"a"}}, "S")
+	out, err := Expand("$1 ${S:=x}", mapping)
+	ok(t, err)
+	equals(t, "a x", out)
+	equals(t, RWMap{"S": "x"}, rw)
+
+	_, err = Expand("${S:=x}", Secrets(Map{}))
+	equals(t, "mapping type posix.Map does not support assignment", err.Error())
+
+	_, err = Expand("${X:?$S}", Chain(Map{}, Secrets(Map{"S": "s"}, "S")))
+	equals(t, Redacted, err.Error())
+
+	// positional parameters from a Map are still looked up with Get
+	out, err = Expand("$1 $#", Secrets(Map{"1": "one", "#": "1"}, "S"))
+	ok(t, err)
+	equals(t, "one 1", out)
+}
+
+func TestSecrets_audit(t *testing.T) {
+	a := NewAudit(Secrets(Map{"S": "secret", "P": "public"}, "S"))
+	_, err := Expand("$S $P ${X?$S}", a)
+	equals(t, Redacted, err.Error())
+	equals(t, []AuditEntry{
+		{Name: "S", Found: true, Pos: 0},
+		{Name: "P", Found: true, Len: 6, Pos: 3},
+		{Name: "X", Found: false, Pos: 6},
+		{Name: "S", Found: true, Pos: 10},
+	}, a.Log())
+}
+
+func TestSecrets_context(t *testing.T) {
+	tenant := context.WithValue(context.Background(), tenantKey{}, "acme")
+	out, err := ExpandContext(tenant, "$TENANT", Secrets(secretStore{}, "TENANT"))
+	ok(t, err)
+	equals(t, "acme", out)
+}
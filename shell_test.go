@@ -0,0 +1,111 @@
+package posix
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mgood/go-posix/corpus"
+)
+
+// shellDifferences are the cases where the package intentionally differs
+// from expanding the input in a here-document: quotes in the word of an
+// expansion are removed as in an unquoted word, rather than kept.
+var shellDifferences = map[string]bool{
+	`${unset-'${foo}'}`: true,
+	`${unset-\'}`:       true,
+	`${unset-\f}`:       true,
+	`${unset-"\a\b\c"}`: true,
+}
+
+// TestExpand_shell compares the package with a real shell on the POSIX
+// cases of the corpus. It only runs when POSIX_SHELL names the shell to
+// run, such as "dash" or "bash", and logs a conformance report of the shell
+// against the corpus:
+//
+//	POSIX_SHELL=dash go test -run TestExpand_shell -v
+func TestExpand_shell(t *testing.T) {
+	shell := os.Getenv("POSIX_SHELL")
+	if shell == "" {
+		t.Skip("set POSIX_SHELL to compare with a shell")
+	}
+	if _, err := exec.LookPath(shell); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := corpus.Run(corpus.POSIX, shellExpand(shell))
+	ok(t, err)
+	t.Logf("%s conformance:\n%s", shell, report)
+
+	cases, err := corpus.Dialect(corpus.POSIX)
+	ok(t, err)
+	for _, c := range cases {
+		if shellDifferences[c.Input] {
+			continue
+		}
+		want, wantErr := shellExpand(shell)(c.Input, c.Env)
+		got, gotErr := Expand(c.Input, RWMap(c.Env))
+		// the messages of errors vary between shells, so only whether
+		// there was an error is compared
+		if (gotErr != nil) != (wantErr != nil) || gotErr == nil && got != want {
+			t.Errorf("%s: %#v expanded to %s, but %s gave %s", c.Feature, c.Input, describeResult(got, gotErr), shell, describeResult(want, wantErr))
+		}
+	}
+}
+
+func describeResult(out string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("error %q", err)
+	}
+	return fmt.Sprintf("%q", out)
+}
+
+// shellPrefix matches the prefix of the shell's error messages, such as
+// "bash: line 3: " or "sh: 3: ".
+var shellPrefix = regexp.MustCompile(`^[^:]*: (line )?\d+: `)
+
+// shellExpand returns an ExpandFunc that expands the input in a here-document
+// of the shell, which keeps quotes like Expand does. The numeric variables
+// of the environment are set as the positional parameters.
+func shellExpand(shell string) corpus.ExpandFunc {
+	return func(input string, env map[string]string) (string, error) {
+		var script strings.Builder
+		var positional []string
+		for k := range env {
+			if isName(k) {
+				fmt.Fprintf(&script, "%s=%s\n", k, shellQuote(env[k]))
+			} else {
+				positional = append(positional, k)
+			}
+		}
+		sort.Strings(positional)
+		script.WriteString("set --")
+		for _, k := range positional {
+			script.WriteString(" " + shellQuote(env[k]))
+		}
+		fmt.Fprintf(&script, "\ncat <<__EOF__\n%s\n__EOF__\n", input)
+
+		cmd := exec.Command(shell, "-c", script.String())
+		cmd.Env = []string{}
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				return "", err
+			}
+			return "", fmt.Errorf("%s", shellPrefix.ReplaceAllString(msg, ""))
+		}
+		return strings.TrimSuffix(stdout.String(), "\n"), nil
+	}
+}
+
+// shellQuote quotes the string as a single word for the shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
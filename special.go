@@ -0,0 +1,58 @@
+package posix
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SpecialGetter is the interface for mappings that provide the special
+// parameters: $? for the last exit status, $$ and $! for the process IDs
+// of the shell and the last background command, $0 for the name of the
+// shell or script, and $- for the option flags, such as "hB". Parameters
+// it does not provide are looked up with Get, so Map{"?": "0"} also
+// provides $?.
+type SpecialGetter interface {
+	GetSpecial(name string) (value string, exists bool)
+}
+
+// getSpecial returns the value of a special parameter from a wrapped
+// mapping, if it implements SpecialGetter.
+func getSpecial(mapping Getter, k string) (string, bool) {
+	if sg, ok := mapping.(SpecialGetter); ok {
+		return sg.GetSpecial(k)
+	}
+	return "", false
+}
+
+// The special parameters provided by a SpecialGetter, the others are
+// provided by a Positional mapping.
+const specialGetterParams = "?$!0-"
+
+// lookupSpecial returns the value of a special parameter from the mapping.
+func (ev *evaluator) lookupSpecial(name string) (string, bool) {
+	if len(name) != 1 || !strings.Contains(specialGetterParams, name) {
+		return "", false
+	}
+	if sg, ok := ev.mapping.(SpecialGetter); ok {
+		return sg.GetSpecial(name)
+	}
+	return "", false
+}
+
+// isSpecialParam reports whether the character names a special parameter
+func isSpecialParam(c rune) bool {
+	return strings.ContainsRune("@*#?$!-", c)
+}
+
+// specialDefault returns the value of a special parameter that is not
+// provided by the mapping.
+func specialDefault(name string) (string, bool) {
+	switch name {
+	case "#":
+		return "0", true
+	case "$":
+		return strconv.Itoa(os.Getpid()), true
+	}
+	return "", false
+}
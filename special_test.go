@@ -0,0 +1,95 @@
+package posix
+
+import (
+	"os"
+	"strconv"
+	"testing"
+)
+
+// statusMap is a Map with the exit status of the last command.
+type statusMap struct {
+	Map
+	status string
+}
+
+func (m statusMap) GetSpecial(name string) (string, bool) {
+	if name == "?" {
+		return m.status, true
+	}
+	return "", false
+}
+
+func TestExpand_status(t *testing.T) {
+	x, err := Expand("exit $? ${?} ${?:-none}", statusMap{Map{"?": "ignored"}, "1"})
+	ok(t, err)
+	equals(t, "exit 1 1 1", x)
+
+	x, err = Expand("exit $?", Map{"?": "2"})
+	ok(t, err)
+	equals(t, "exit 2", x)
+
+	x, err = Expand("exit ${?-unknown}", Map{})
+	ok(t, err)
+	equals(t, "exit unknown", x)
+}
+
+// pidMap provides fixed process IDs.
+type pidMap struct{ Map }
+
+func (pidMap) GetSpecial(name string) (string, bool) {
+	switch name {
+	case "$":
+		return "100", true
+	case "!":
+		return "200", true
+	}
+	return "", false
+}
+
+func TestExpand_pids(t *testing.T) {
+	x, err := Expand("$$ ${$} $! ${!}", pidMap{})
+	ok(t, err)
+	equals(t, "100 100 200 200", x)
+
+	x, err = Expand("$$ ${!-none}", Map{})
+	ok(t, err)
+	equals(t, strconv.Itoa(os.Getpid())+" none", x)
+
+	x, err = Expand("$$-$!", Map{"$": "1", "!": "2"})
+	ok(t, err)
+	equals(t, "1-2", x)
+}
+
+func TestExpand_commandName(t *testing.T) {
+	x, err := Expand("$0 ${0}", Map{"0": "myscript"})
+	ok(t, err)
+	equals(t, "myscript myscript", x)
+
+	x, err = Expand("${0-none}", Map{})
+	ok(t, err)
+	equals(t, "none", x)
+
+	x, err = ExpandEnv("$0")
+	ok(t, err)
+	equals(t, os.Args[0], x)
+}
+
+// flagsMap provides the option flags.
+type flagsMap struct{ Map }
+
+func (flagsMap) GetSpecial(name string) (string, bool) {
+	if name == "-" {
+		return "eu", true
+	}
+	return "", false
+}
+
+func TestExpand_flags(t *testing.T) {
+	x, err := Expand("a $- ${-} b", flagsMap{})
+	ok(t, err)
+	equals(t, "a eu eu b", x)
+
+	x, err = Expand("a $- ${-:-none} b", Map{})
+	ok(t, err)
+	equals(t, "a  none b", x)
+}
@@ -0,0 +1,103 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var nounsettests = []struct {
+	in  string
+	out string
+	err string
+}{
+	{"$set ${set} ${#set}", "yes yes 3", ""},
+	{"$empty", "", ""},
+	{"$unset", "", "unset: unbound variable"},
+	{"${unset}", "", "unset: unbound variable"},
+	{"${#unset}", "", "unset: unbound variable"},
+	{"$1", "", "$1: unbound variable"},
+	{"$@$*", "", ""},
+	{"${unset-x}${unset:-y}", "xy", ""},
+	{"${unset+x}${unset:+y}", "", ""},
+	{"${unset:-$other}", "", "other: unbound variable"},
+	{"${set:+$other}", "", "other: unbound variable"},
+	{"${set:-$other}", "yes", ""},
+	{"${unset?required}", "", "required"},
+}
+
+func TestExpand_noUnset(t *testing.T) {
+	mapping := Map{"set": "yes", "empty": ""}
+	for _, test := range nounsettests {
+		out, err := Expand(test.in, mapping, WithNoUnset())
+		if test.err == "" {
+			ok(t, err)
+			equals(t, test.out, out)
+			continue
+		}
+		if err == nil {
+			t.Errorf("pattern %#v should have produced error %#v", test.in, test.err)
+			continue
+		}
+		equals(t, test.err, err.Error())
+	}
+}
+
+func TestExpand_noUnsetExpandError(t *testing.T) {
+	_, err := Expand("a $unset", Map{}, WithNoUnset())
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) {
+		t.Fatalf("expected an ExpandError, got: %#v", err)
+	}
+	equals(t, "unset", expandErr.Name)
+	equals(t, rune(0), expandErr.Op)
+
+	var posErr *PosError
+	if !errors.As(err, &posErr) {
+		t.Fatalf("expected a PosError, got: %#v", err)
+	}
+	equals(t, Pos(2), posErr.Pos)
+}
+
+func TestExpand_noUnsetKeepUnset(t *testing.T) {
+	out, err := Expand("$unset", Map{}, WithNoUnset(), WithKeepUnset())
+	ok(t, err)
+	equals(t, "$unset", out)
+}
+
+var noemptytests = []struct {
+	in  string
+	out string
+	err string
+}{
+	{"$set ${set-x}", "yes yes", ""},
+	{"$unset", "", ""},
+	{"$empty", "", "empty: parameter is empty"},
+	{"${empty}", "", "empty: parameter is empty"},
+	{"${empty-x}", "", "empty: parameter is empty"},
+	{"${empty:-x}", "x", ""},
+	{"${empty:+x}${empty+y}", "y", ""},
+	{"${#empty}", "0", ""},
+	{"${unset:-$empty}", "", "empty: parameter is empty"},
+}
+
+func TestExpand_noEmpty(t *testing.T) {
+	mapping := Map{"set": "yes", "empty": ""}
+	for _, test := range noemptytests {
+		out, err := Expand(test.in, mapping, WithNoEmpty())
+		if test.err == "" {
+			ok(t, err)
+			equals(t, test.out, out)
+			continue
+		}
+		if err == nil {
+			t.Errorf("pattern %#v should have produced error %#v", test.in, test.err)
+			continue
+		}
+		equals(t, test.err, err.Error())
+	}
+}
+
+func TestExpand_noEmptyNoUnset(t *testing.T) {
+	_, err := Expand("$a $b", Map{"a": ""}, WithNoEmpty(), WithNoUnset(), WithAllErrors())
+	equals(t, "a: parameter is empty\nb: unbound variable", err.Error())
+}
@@ -0,0 +1,65 @@
+package posix
+
+import "sync"
+
+// SyncRWMap implements the Getter and Setter interfaces for a map that is
+// safe for concurrent use, so expansions in multiple goroutines can share a
+// mapping and assign to it.
+//
+// The other mappings are safe for concurrent expansions only while they are
+// not modified: Map, Values, JSONGetter, and a FoldGetter, Environ or RWMap
+// that is not assigned to. Assignments with ${name=word} to an RWMap,
+// Environ, FoldGetter or Flags, or changing a mapping while it is in use,
+// must not happen concurrently with other expansions. Func and LookupFunc
+// are as safe as the function, and Chain and Params are as safe as the
+// mappings they wrap. The environment used by ExpandEnv is always safe.
+//
+// The zero value is an empty map ready to use.
+type SyncRWMap struct {
+	mu   sync.RWMutex
+	vars map[string]string
+}
+
+// NewSyncRWMap returns a SyncRWMap holding a copy of the map.
+func NewSyncRWMap(m map[string]string) *SyncRWMap {
+	s := &SyncRWMap{vars: make(map[string]string, len(m))}
+	for k, v := range m {
+		s.vars[k] = v
+	}
+	return s
+}
+
+func (s *SyncRWMap) Get(k string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.vars[k]
+	return v, ok
+}
+
+func (s *SyncRWMap) Set(k, v string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.vars == nil {
+		s.vars = make(map[string]string)
+	}
+	s.vars[k] = v
+	return nil
+}
+
+// Unset removes the variable.
+func (s *SyncRWMap) Unset(k string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.vars, k)
+}
+
+// Snapshot returns a copy of the variables.
+func (s *SyncRWMap) Snapshot() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m := make(map[string]string, len(s.vars))
+	for k, v := range s.vars {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,45 @@
+package posix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSyncRWMap(t *testing.T) {
+	m := NewSyncRWMap(map[string]string{"A": "a"})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, err := Expand(fmt.Sprintf("$A ${V%d:=%d} ${SHARED:=x}", i, i), m)
+			if err == nil && out != fmt.Sprintf("a %d x", i) {
+				err = fmt.Errorf("unexpected result: %q", out)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ok(t, err)
+	}
+
+	vars := m.Snapshot()
+	equals(t, 22, len(vars))
+	equals(t, "7", vars["V7"])
+
+	m.Unset("A")
+	_, set := m.Get("A")
+	equals(t, false, set)
+}
+
+func TestSyncRWMap_zero(t *testing.T) {
+	var m SyncRWMap
+	out, err := Expand("${A:=x}$A", &m)
+	ok(t, err)
+	equals(t, "xx", out)
+}
@@ -0,0 +1,304 @@
+// Package systemd expands the specifiers of systemd unit files, such as %i
+// and %h, from a Context provided by the caller, so unit files can be
+// generated without a running systemd. Context.ExpandEnv also expands
+// parameters like ${VAR:-default} with github.com/mgood/go-posix.
+//
+// See systemd.unit(5) for the meaning of each specifier.
+package systemd
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mgood/go-posix"
+)
+
+// Context holds the values of the specifiers. The specifiers for the parts
+// of the unit name, such as %i, %p and %f, are derived from Unit. A
+// specifier whose value is not set is an error, so a unit is never
+// generated with a missing value.
+type Context struct {
+	Unit         string // %n, the full unit name, such as "getty@tty1.service"
+	FragmentPath string // %y, the path of the unit file, and %Y its directory
+
+	Architecture   string // %a, such as "x86-64"
+	BootID         string // %b
+	MachineID      string // %m
+	Hostname       string // %H, and %l up to the first "."
+	PrettyHostname string // %q
+	KernelRelease  string // %v
+
+	OSID           string // %o, the ID of os-release
+	OSVersionID    string // %w
+	OSVariantID    string // %W
+	OSBuildID      string // %B
+	OSImageID      string // %M
+	OSImageVersion string // %A
+
+	User  string // %u
+	UID   int    // %U
+	Group string // %g
+	GID   int    // %G
+	Home  string // %h
+	Shell string // %s
+
+	RuntimeDir     string // %t, such as "/run" for system units
+	StateDir       string // %S, such as "/var/lib"
+	CacheDir       string // %C, such as "/var/cache"
+	LogDir         string // %L, such as "/var/log"
+	ConfigDir      string // %E, such as "/etc"
+	DataDir        string // %D, such as "/usr/share"
+	CredentialsDir string // %d
+	TempDir        string // %T, or "/tmp" if empty
+	VarTempDir     string // %V, or "/var/tmp" if empty
+}
+
+// Expand replaces the specifiers in s with their values. "%%" is a literal
+// "%", and an unknown specifier is an error.
+func (c *Context) Expand(s string) (string, error) {
+	i := strings.IndexByte(s, '%')
+	if i < 0 {
+		return s, nil
+	}
+	var b strings.Builder
+	for i >= 0 {
+		b.WriteString(s[:i])
+		if i+1 == len(s) {
+			return "", fmt.Errorf("unterminated specifier at the end of %q", s)
+		}
+		v, err := c.specifier(s[i+1])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+		s = s[i+2:]
+		i = strings.IndexByte(s, '%')
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+// Template returns a copy of the template with the specifiers in its text
+// replaced by their values, including the words of expansions such as
+// ${VAR:-%h}. The values are not parsed, so they are never expanded as
+// parameters.
+func (c *Context) Template(t *posix.Template) (*posix.Template, error) {
+	var err error
+	out := t.Rewrite(func(n posix.Node) []posix.Node {
+		if err != nil {
+			return nil
+		}
+		switch n := n.(type) {
+		case *posix.TextNode:
+			n.Text, err = c.Expand(n.Text)
+		case *posix.QuotedNode:
+			n.Text, err = c.Expand(n.Text)
+		}
+		return []posix.Node{n}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExpandEnv replaces the specifiers in s, and expands its parameters
+// against the mapping with the options, like posix.Expand, the same as
+// systemd does for the command lines of a unit.
+func (c *Context) ExpandEnv(s string, mapping posix.Getter, opts ...posix.Option) (string, error) {
+	t, err := posix.NewExpander(opts...).Parse(s)
+	if err != nil {
+		return "", err
+	}
+	t, err = c.Template(t)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(mapping)
+}
+
+// specifier returns the value of the specifier with the letter.
+func (c *Context) specifier(letter byte) (string, error) {
+	u := unitName(c.Unit)
+	var v string
+	switch letter {
+	case '%':
+		return "%", nil
+	case 'i':
+		// the instance is empty for units that are not instances
+		return u.instance(), c.needUnit(letter)
+	case 'I':
+		return unescape(u.instance()), c.needUnit(letter)
+	case 'n':
+		v = c.Unit
+	case 'N':
+		v = u.withoutSuffix()
+	case 'p':
+		v = u.prefix()
+	case 'P':
+		v = unescape(u.prefix())
+	case 'j':
+		v = u.final()
+	case 'J':
+		v = unescape(u.final())
+	case 'f':
+		if c.Unit != "" {
+			if i := u.instance(); i != "" {
+				v = unescapePath(i)
+			} else {
+				v = unescapePath(u.prefix())
+			}
+		}
+	case 'y':
+		v = c.FragmentPath
+	case 'Y':
+		if c.FragmentPath != "" {
+			v = path.Dir(c.FragmentPath)
+		}
+	case 'a':
+		v = c.Architecture
+	case 'b':
+		v = c.BootID
+	case 'm':
+		v = c.MachineID
+	case 'H':
+		v = c.Hostname
+	case 'l':
+		v, _, _ = strings.Cut(c.Hostname, ".")
+	case 'q':
+		v = c.PrettyHostname
+	case 'v':
+		v = c.KernelRelease
+	case 'o':
+		v = c.OSID
+	case 'w':
+		v = c.OSVersionID
+	case 'W':
+		v = c.OSVariantID
+	case 'B':
+		v = c.OSBuildID
+	case 'M':
+		v = c.OSImageID
+	case 'A':
+		v = c.OSImageVersion
+	case 'u':
+		v = c.User
+	case 'U':
+		return strconv.Itoa(c.UID), nil
+	case 'g':
+		v = c.Group
+	case 'G':
+		return strconv.Itoa(c.GID), nil
+	case 'h':
+		v = c.Home
+	case 's':
+		v = c.Shell
+	case 't':
+		v = c.RuntimeDir
+	case 'S':
+		v = c.StateDir
+	case 'C':
+		v = c.CacheDir
+	case 'L':
+		v = c.LogDir
+	case 'E':
+		v = c.ConfigDir
+	case 'D':
+		v = c.DataDir
+	case 'd':
+		v = c.CredentialsDir
+	case 'T':
+		v = c.TempDir
+		if v == "" {
+			v = "/tmp"
+		}
+	case 'V':
+		v = c.VarTempDir
+		if v == "" {
+			v = "/var/tmp"
+		}
+	default:
+		return "", fmt.Errorf("unknown specifier %%%c", letter)
+	}
+	if v == "" {
+		return "", fmt.Errorf("specifier %%%c is not set", letter)
+	}
+	return v, nil
+}
+
+// needUnit returns an error if the unit name needed by the specifier is
+// not set.
+func (c *Context) needUnit(letter byte) error {
+	if c.Unit == "" {
+		return fmt.Errorf("specifier %%%c is not set", letter)
+	}
+	return nil
+}
+
+// unitName is a unit name, such as "getty@tty1.service", made of a prefix,
+// an optional instance after "@", and a type suffix.
+type unitName string
+
+func (u unitName) withoutSuffix() string {
+	if i := strings.LastIndexByte(string(u), '.'); i >= 0 {
+		return string(u[:i])
+	}
+	return string(u)
+}
+
+func (u unitName) prefix() string {
+	name := u.withoutSuffix()
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func (u unitName) instance() string {
+	if _, instance, ok := strings.Cut(u.withoutSuffix(), "@"); ok {
+		return instance
+	}
+	return ""
+}
+
+// final returns the part of the prefix after the last "-".
+func (u unitName) final() string {
+	p := u.prefix()
+	return p[strings.LastIndexByte(p, '-')+1:]
+}
+
+// unescape undoes the escaping of systemd-escape, where "-" is a "/" and
+// "\xNN" is the byte with the hex value NN.
+func unescape(s string) string {
+	if !strings.ContainsAny(s, `-\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '-':
+			b.WriteByte('/')
+		case strings.HasPrefix(s[i:], `\x`) && i+4 <= len(s):
+			if n, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// unescapePath unescapes a name escaped with systemd-escape --path, which
+// is an absolute path where "-" alone is the root directory.
+func unescapePath(s string) string {
+	if s == "-" {
+		return "/"
+	}
+	return "/" + strings.TrimPrefix(unescape(s), "/")
+}
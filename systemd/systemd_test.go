@@ -0,0 +1,139 @@
+package systemd
+
+import (
+	"testing"
+
+	"github.com/mgood/go-posix"
+)
+
+var ctx = &Context{
+	Unit:         `dev-disk-by\x2dlabel-data@home-me.mount`,
+	FragmentPath: "/etc/systemd/system/backup@.service",
+	Hostname:     "web1.example.com",
+	User:         "me",
+	UID:          1000,
+	Home:         "/home/me",
+	RuntimeDir:   "/run/user/1000",
+}
+
+var expandtests = []struct {
+	in, out string
+}{
+	{"no specifiers", "no specifiers"},
+	{"100%%", "100%"},
+	{"%n", `dev-disk-by\x2dlabel-data@home-me.mount`},
+	{"%N", `dev-disk-by\x2dlabel-data@home-me`},
+	{"%p", `dev-disk-by\x2dlabel-data`},
+	{"%P", "dev/disk/by-label/data"},
+	{"%i", "home-me"},
+	{"%I", "home/me"},
+	{"%j", "data"},
+	{"%f", "/home/me"},
+	{"%y %Y", "/etc/systemd/system/backup@.service /etc/systemd/system"},
+	{"%H %l", "web1.example.com web1"},
+	{"%u:%U %h", "me:1000 /home/me"},
+	{"%t/app.sock", "/run/user/1000/app.sock"},
+	{"%T %V", "/tmp /var/tmp"},
+	{"$HOME %%h", "$HOME %h"},
+}
+
+func TestExpand(t *testing.T) {
+	for _, tt := range expandtests {
+		out, err := ctx.Expand(tt.in)
+		if err != nil {
+			t.Errorf("Expand(%q): %v", tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("Expand(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestExpand_unit(t *testing.T) {
+	tests := []struct {
+		unit, in, out string
+	}{
+		{"nginx.service", "%p|%i|%f|%j", "nginx||/nginx|nginx"},
+		{"getty@tty1.service", "%p %i %f", "getty tty1 /tty1"},
+		{"systemd-fsck@-.service", "%i %I %f", "- / /"},
+	}
+	for _, tt := range tests {
+		c := &Context{Unit: tt.unit}
+		out, err := c.Expand(tt.in)
+		if err != nil {
+			t.Errorf("%s: Expand(%q): %v", tt.unit, tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("%s: Expand(%q) = %q, want %q", tt.unit, tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestExpand_errors(t *testing.T) {
+	tests := []struct {
+		in, err string
+	}{
+		{"%z", "unknown specifier %z"},
+		{"100%", `unterminated specifier at the end of "100%"`},
+		{"%m", "specifier %m is not set"},
+		{"%i", ""},
+	}
+	for _, tt := range tests {
+		_, err := ctx.Expand(tt.in)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("Expand(%q): %v", tt.in, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("Expand(%q) error = %v, want %q", tt.in, err, tt.err)
+		}
+	}
+	if _, err := new(Context).Expand("%i"); err == nil || err.Error() != "specifier %i is not set" {
+		t.Errorf("Expand(%q) without a unit: %v", "%i", err)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	mapping := posix.Map{"PORT": "8080", "SPEC": "%h"}
+	tests := []struct {
+		in, out string
+	}{
+		{"%h/app -p $PORT", "/home/me/app -p 8080"},
+		{"${DATA:-%h/data}", "/home/me/data"},
+		{"'%u' $SPEC", "me %h"},
+		{"${PORT:+%i}", "home-me"},
+	}
+	for _, tt := range tests {
+		out, err := ctx.ExpandEnv(tt.in, mapping, posix.WithQuoteRemoval())
+		if err != nil {
+			t.Errorf("ExpandEnv(%q): %v", tt.in, err)
+		} else if out != tt.out {
+			t.Errorf("ExpandEnv(%q) = %q, want %q", tt.in, out, tt.out)
+		}
+	}
+
+	if _, err := ctx.ExpandEnv("${DATA:-%z}", mapping); err == nil || err.Error() != "unknown specifier %z" {
+		t.Errorf("ExpandEnv(%q) error = %v", "${DATA:-%z}", err)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := posix.Parse("%h/$NAME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ctx.Template(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		s, err := out.Execute(posix.Map{"NAME": name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "/home/me/" + name; s != want {
+			t.Errorf("Execute = %q, want %q", s, want)
+		}
+	}
+	if s, err := tmpl.Execute(posix.Map{"NAME": "a"}); err != nil || s != "%h/a" {
+		t.Errorf("the original template changed: %q, %v", s, err)
+	}
+}
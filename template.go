@@ -0,0 +1,591 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Template is a parsed string that can be expanded repeatedly against
+// different mappings without parsing it again. A Template is safe for
+// concurrent use by multiple goroutines.
+//
+// Executing a Template only evaluates the parsed nodes into a single
+// buffer, so expanding the same string many times is several times faster
+// than calling Expand in a loop, and allocates a small constant amount
+// rather than once per token.
+type Template struct {
+	// Nodes are the parsed elements of the template, in order.
+	Nodes []Node
+
+	opts Expander
+	src  string
+}
+
+// Parse parses the string into a Template with the options, reporting any
+// syntax errors. Like Expander.Parse, the options are the defaults for
+// executing the template, and the ones that change how it is parsed, such
+// as WithDialect, are an ErrParseOption error if Execute changes them.
+func Parse(s string, opts ...Option) (*Template, error) {
+	return NewExpander(opts...).Parse(s)
+}
+
+// Parse parses the string into a Template with the options set on the
+// Expander. The options are copied into the Template, so it is always
+// expanded with the dialect and other rules it was parsed with. The options
+// that only affect expansion, such as NoUnset, are defaults that Execute
+// may override.
+func (e *Expander) Parse(s string) (*Template, error) {
+	nodes, src, err := parse(s, e, e.QuoteRemoval)
+	if err != nil {
+		return nil, e.wrapError(err)
+	}
+	return &Template{Nodes: nodes, opts: *e, src: src}, nil
+}
+
+// Validate reports any syntax errors in the string, without expanding it.
+// No defaults are evaluated and no assignments are made.
+func Validate(s string) error {
+	return new(Expander).Validate(s)
+}
+
+// Validate reports any syntax errors in the string with the options set on
+// the Expander, like Validate.
+func (e *Expander) Validate(s string) error {
+	_, err := e.Parse(s)
+	return err
+}
+
+// Execute expands the template based on the mapping, like Expand. With the
+// AllErrors option, the expanded string is returned along with any errors.
+//
+// The options override the ones the template was parsed with for this
+// expansion only. An option that changes how the template is parsed, such
+// as WithDialect, is an ErrParseOption error.
+func (t *Template) Execute(mapping Getter, opts ...Option) (string, error) {
+	return t.execute(nil, mapping, opts)
+}
+
+// execute expands the template, with the context for ExecuteContext or nil.
+func (t *Template) execute(ctx context.Context, mapping Getter, opts []Option) (string, error) {
+	e, err := t.options(opts)
+	if err != nil {
+		return "", err
+	}
+	ev := &evaluator{mapping: mapping, opts: e, ctx: ctx, src: t.src}
+	segs, err := ev.evalAll(t.Nodes)
+	if err != nil && !e.AllErrors {
+		return "", e.wrapError(err)
+	}
+	return segs.String(), e.wrapError(err)
+}
+
+// options returns the options to execute the template with, which are the
+// ones it was parsed with, overridden by opts.
+func (t *Template) options(opts []Option) (*Expander, error) {
+	if len(opts) == 0 {
+		return &t.opts, nil
+	}
+	e := t.opts
+	for _, opt := range opts {
+		opt(&e)
+	}
+	switch {
+	case e.Dialect != t.opts.Dialect:
+		return nil, parseOptionError{"Dialect"}
+	case e.QuoteRemoval != t.opts.QuoteRemoval:
+		return nil, parseOptionError{"QuoteRemoval"}
+	case e.Tilde != t.opts.Tilde:
+		return nil, parseOptionError{"Tilde"}
+	case e.DottedNames != t.opts.DottedNames:
+		return nil, parseOptionError{"DottedNames"}
+	case e.Dialect == Bash && e.MaxOutput != t.opts.MaxOutput:
+		// brace expansion is limited when parsing
+		return nil, parseOptionError{"MaxOutput"}
+	}
+	return &e, nil
+}
+
+// Node is an element of a parsed Template.
+type Node interface {
+	// eval appends the segments of the node's expansion to dst.
+	eval(ev *evaluator, dst segments) (segments, error)
+}
+
+// TextNode is literal text.
+type TextNode struct {
+	Text string
+}
+
+// QuotedNode is text from single-quotes or escaped by a backslash, which
+// is not subject to field splitting.
+type QuotedNode struct {
+	Text string
+}
+
+// DoubleQuotedNode is a double-quoted string, which may contain expansions.
+type DoubleQuotedNode struct {
+	Nodes []Node
+}
+
+// ParamNode expands to the value of a parameter, like $name or ${name}.
+type ParamNode struct {
+	Name string
+	Pos  Pos // the offset of the "$" in the input
+}
+
+// LengthNode expands to the length of a parameter's value, like ${#name}.
+type LengthNode struct {
+	Name string
+	Pos  Pos // the offset of the "$" in the input
+}
+
+// ParamOpNode expands a parameter with an operator, like ${name:-word}.
+type ParamOpNode struct {
+	Name string
+
+	// Op is the operator, one of '-', '=', '?' or '+'.
+	Op rune
+
+	// Colon is set when the operator is preceded by a colon, so a null
+	// value is treated the same as unset.
+	Colon bool
+
+	// Word is the word following the operator.
+	Word []Node
+
+	Pos Pos // the offset of the "$" in the input
+}
+
+// TildeNode expands to the home directory for a tilde-prefix, like ~ or
+// ~user.
+type TildeNode struct {
+	User string
+	Pos  Pos // the offset of the "~" in the input
+}
+
+// evaluator holds the mapping and options used to evaluate nodes.
+type evaluator struct {
+	mapping Getter
+	opts    *Expander
+	ctx     context.Context      // the context for ExecuteContext, or nil
+	src     string               // the input the nodes were parsed from, to locate errors
+	errs    []error              // the errors collected with the AllErrors option
+	memo    map[string]memoValue // the values looked up with the Memoize option
+	size    int                  // the length of the output so far, for MaxOutput
+}
+
+// A segment of the evaluated text, recording how field splitting applies to it.
+type segment struct {
+	text     string
+	quoted   bool   // quoted text is never split
+	expanded bool   // the result of an expansion, which is split using IFS
+	fieldSep bool   // separates the fields of $@, even when quoted
+	starSep  bool   // separates the fields of $* when unquoted
+	noField  bool   // "$@" with no positional parameters
+	param    string // the parameter the text was read from
+}
+
+type segments []segment
+
+func (s segments) String() string {
+	if len(s) == 1 {
+		return s[0].text
+	}
+	n := 0
+	for _, seg := range s {
+		n += len(seg.text)
+	}
+	var b strings.Builder
+	b.Grow(n)
+	for _, seg := range s {
+		b.WriteString(seg.text)
+	}
+	return b.String()
+}
+
+// evalNodes appends the segments from evaluating the nodes to dst, or
+// returns the first error encountered.
+func evalNodes(ev *evaluator, dst segments, nodes []Node) (segments, error) {
+	if len(nodes) == 1 && !ev.opts.AllErrors {
+		return nodes[0].eval(ev, dst)
+	}
+	for _, n := range nodes {
+		segs, err := n.eval(ev, dst)
+		if err != nil {
+			if !ev.opts.AllErrors || err == ErrOutputLimit || ev.canceled() {
+				return nil, err
+			}
+			// continue with the expansion replaced by an empty string
+			ev.errs = append(ev.errs, err)
+			continue
+		}
+		dst = segs
+	}
+	return dst, nil
+}
+
+// evalAll evaluates the nodes of a whole template, returning the errors
+// collected with the AllErrors option joined together.
+func (ev *evaluator) evalAll(nodes []Node) (segments, error) {
+	segs, err := evalNodes(ev, make(segments, 0, len(nodes)), nodes)
+	if err != nil {
+		return nil, err
+	}
+	return segs, ev.collected()
+}
+
+// collected returns the errors collected with the AllErrors option joined
+// together, or nil if there were none.
+func (ev *evaluator) collected() error {
+	if len(ev.errs) == 0 {
+		return nil
+	}
+	return errors.Join(ev.errs...)
+}
+
+func (n *TextNode) eval(ev *evaluator, dst segments) (segments, error) {
+	return ev.output(dst, segment{text: n.Text})
+}
+
+func (n *QuotedNode) eval(ev *evaluator, dst segments) (segments, error) {
+	return ev.output(dst, segment{text: n.Text, quoted: true})
+}
+
+func (n *DoubleQuotedNode) eval(ev *evaluator, dst segments) (segments, error) {
+	// start with an empty quoted segment, so "" produces an empty field
+	start := len(dst)
+	dst = append(dst, segment{quoted: true})
+	dst, err := evalNodes(ev, dst, n.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	if onlyNoField(dst[start+1:]) {
+		// "$@" without any positional parameters is removed entirely,
+		// rather than producing an empty field
+		return dst[:start], nil
+	}
+	for i := start + 1; i < len(dst); i++ {
+		dst[i].quoted = true
+	}
+	return dst, nil
+}
+
+func onlyNoField(segs segments) bool {
+	for _, seg := range segs {
+		if !seg.noField {
+			return false
+		}
+	}
+	return len(segs) > 0
+}
+
+func (n *ParamNode) eval(ev *evaluator, dst segments) (segments, error) {
+	if !ev.allowed(n.Name) {
+		return ev.disallow(dst, n, n.Pos, &ExpandError{Name: n.Name})
+	}
+	if n.Name == "@" || n.Name == "*" {
+		return ev.output(dst, ev.positionalFields(n.Name)...)
+	}
+	v, set, err := ev.get(n.Name, n.Pos)
+	if err != nil {
+		return nil, ev.error(n.Pos, &ExpandError{Name: n.Name, Err: err})
+	}
+	if !set && ev.keepUnset(n.Name) {
+		return ev.output(dst, ev.verbatim(n)...)
+	}
+	if !set && ev.opts.NoUnset {
+		return nil, ev.error(n.Pos, &ExpandError{Name: n.Name, Err: unboundError{n.Name}})
+	}
+	if set && v == "" && ev.opts.NoEmpty {
+		return nil, ev.error(n.Pos, &ExpandError{Name: n.Name, Err: emptyError{n.Name}})
+	}
+	return ev.output(dst, segment{text: v, expanded: true, param: n.Name})
+}
+
+func (n *LengthNode) eval(ev *evaluator, dst segments) (segments, error) {
+	if !ev.allowed(n.Name) {
+		return ev.disallow(dst, n, n.Pos, &ExpandError{Name: n.Name})
+	}
+	v, set, err := ev.get(n.Name, n.Pos)
+	if err != nil {
+		return nil, ev.error(n.Pos, &ExpandError{Name: n.Name, Err: err})
+	}
+	if !set && ev.keepUnset(n.Name) {
+		return ev.output(dst, ev.verbatim(n)...)
+	}
+	if !set && ev.opts.NoUnset {
+		return nil, ev.error(n.Pos, &ExpandError{Name: n.Name, Err: unboundError{n.Name}})
+	}
+	return ev.output(dst, segment{text: strconv.Itoa(len(v)), expanded: true})
+}
+
+func (n *ParamOpNode) eval(ev *evaluator, dst segments) (segments, error) {
+	if !ev.allowed(n.Name) {
+		return ev.disallow(dst, n, n.Pos, &ExpandError{Name: n.Name, Op: n.Op, Colon: n.Colon})
+	}
+	paramVal, paramSet, err := ev.get(n.Name, n.Pos)
+	if err != nil {
+		return nil, n.error(ev, "", err)
+	}
+	if !paramSet && ev.keepUnset(n.Name) {
+		return ev.output(dst, ev.verbatim(n)...)
+	}
+	if n.Colon {
+		paramSet = paramVal != ""
+	}
+
+	if n.Op == '+' {
+		if paramSet {
+			return evalNodes(ev, dst, n.Word)
+		}
+		return dst, nil
+	}
+
+	if paramSet {
+		if n.Name == "@" || n.Name == "*" {
+			return ev.output(dst, ev.positionalFields(n.Name)...)
+		}
+		if paramVal == "" && ev.opts.NoEmpty {
+			return nil, n.error(ev, "", emptyError{n.Name})
+		}
+		return ev.output(dst, segment{text: paramVal, expanded: true, param: n.Name})
+	}
+
+	start := len(dst)
+	dst, err = evalNodes(ev, dst, n.Word)
+	if err != nil {
+		return nil, err
+	}
+	word := dst[start:]
+
+	switch n.Op {
+	case '-':
+		return dst, nil
+	case '=':
+		if !isVarName(n.Name) {
+			return nil, n.error(ev, "", specialAssignError{n.Name})
+		}
+		switch ev.opts.Assign {
+		case AssignError:
+			return nil, n.error(ev, "", readOnlyError{n.Name})
+		case AssignIgnore:
+			return dst, nil
+		}
+		if setter, ok := ev.mapping.(Setter); ok {
+			value := word.String()
+			ev.at(n.Pos)
+			err := setter.Set(n.Name, value)
+			if err != nil {
+				return nil, n.error(ev, "", err)
+			}
+			ev.remember(n.Name, value, true)
+			// the result is the new value of the parameter, which is split
+			// as a whole
+			for i := range word {
+				word[i].quoted = false
+				word[i].expanded = true
+			}
+			return dst, nil
+		}
+		return nil, n.error(ev, "", assignError{n.Name, ev.mapping})
+	case '?':
+		message := ev.redact(word)
+		if ev.opts.Dialect == Compose {
+			return nil, n.error(ev, message, requiredError{n.Name, message})
+		}
+		return nil, n.error(ev, message, unsetError{n.Name, message})
+	}
+
+	return nil, n.error(ev, "", opError{string(n.Op)})
+}
+
+// error returns an ExpandError for the expansion, at its position in the
+// input.
+func (n *ParamOpNode) error(ev *evaluator, message string, err error) error {
+	return ev.error(n.Pos, &ExpandError{
+		Name:    n.Name,
+		Op:      n.Op,
+		Colon:   n.Colon,
+		Message: message,
+		Err:     err,
+	})
+}
+
+// error returns the error at the position in the input.
+func (ev *evaluator) error(pos Pos, err error) error {
+	return newPosError(ev.src, pos, err)
+}
+
+func (n *TildeNode) eval(ev *evaluator, dst segments) (segments, error) {
+	if n.User == "" {
+		// HOME is looked up like a reference to it, so the options and
+		// mapping interfaces apply to it
+		if !ev.allowed("HOME") {
+			return ev.disallow(dst, n, n.Pos, &ExpandError{Name: "HOME"})
+		}
+		home, ok, err := ev.get("HOME", n.Pos)
+		if err != nil {
+			return nil, ev.error(n.Pos, &ExpandError{Name: "HOME", Err: err})
+		}
+		if ok {
+			return ev.output(dst, segment{text: home, quoted: true, param: "HOME"})
+		}
+	} else if ev.opts.UserHome != nil {
+		if home, ok := ev.opts.UserHome(n.User); ok {
+			return ev.output(dst, segment{text: home, quoted: true})
+		}
+	}
+	return ev.output(dst, segment{text: "~" + n.User})
+}
+
+// parse lexes the string and builds the nodes from the lexer's items. It
+// also returns the input as lexed, after any brace expansion, which the
+// positions of the nodes refer to.
+func parse(s string, opts *Expander, words bool) ([]Node, string, error) {
+	if opts.Dialect == Bash {
+		// brace expansion happens before the other expansions, and may
+		// produce more output than the limit from a short input
+		expanded, ok := expandBraceWords(s, words, opts.MaxOutput)
+		if !ok {
+			return nil, s, ErrOutputLimit
+		}
+		s = expanded
+	}
+	l := lex(s, opts, words)
+	p := &parser{lex: l, src: l.input, dotted: opts.DottedNames, dialect: opts.Dialect}
+	nodes, _, err := p.parseNodes(false)
+	if err != nil && opts.Dialect == Compose {
+		var pe *PosError
+		if errors.As(err, &pe) {
+			pe.Err = templateError{s, pe.Err}
+		}
+	}
+	return nodes, l.input, err
+}
+
+type parser struct {
+	lex     *lexer
+	src     string
+	dotted  bool    // allow dotted names with the DottedNames option
+	dialect Dialect // the dialect, which determines the valid names
+}
+
+// How a list of nodes was terminated
+type parseEnd int
+
+const (
+	endEOF parseEnd = iota
+	endQuote
+	endBracket
+)
+
+// parseNodes parses items until the end of the input, or the end of the
+// enclosing double-quotes or bracket.
+func (p *parser) parseNodes(inQuotes bool) ([]Node, parseEnd, error) {
+	var nodes []Node
+	for {
+		tok, ok := p.lex.nextToken()
+		if !ok {
+			break
+		}
+		switch it := tok.item.(type) {
+		case itemText:
+			nodes = append(nodes, &TextNode{string(it)})
+		case itemQuotedText:
+			nodes = append(nodes, &QuotedNode{string(it)})
+		case itemDoubleQuote:
+			if inQuotes {
+				return nodes, endQuote, nil
+			}
+			children, end, err := p.parseNodes(true)
+			if err != nil {
+				return nil, end, err
+			}
+			nodes = append(nodes, &DoubleQuotedNode{children})
+			if end != endQuote {
+				// the quotes were not closed before the end of the bracket
+				return nodes, end, nil
+			}
+		case itemEndBracket:
+			return nodes, endBracket, nil
+		case itemUnexpectedEOF:
+			return nil, endEOF, newPosError(p.src, tok.pos, eofError{rune(it)})
+		case itemReadParam:
+			if !p.isParamName(string(it)) {
+				return nil, endEOF, p.substError(tok.pos)
+			}
+			nodes = append(nodes, &ParamNode{Name: string(it), Pos: tok.pos})
+		case itemParamLen:
+			if !p.isParamName(string(it)) {
+				return nil, endEOF, p.substError(tok.pos)
+			}
+			nodes = append(nodes, &LengthNode{Name: string(it), Pos: tok.pos})
+		case itemTilde:
+			// the token starts after the "~"
+			nodes = append(nodes, &TildeNode{User: string(it), Pos: tok.pos - 1})
+		case itemBadOp:
+			if it.badSubst {
+				return nil, endEOF, p.substError(tok.pos)
+			}
+			if it.bashOnly {
+				return nil, endEOF, newPosError(p.src, tok.pos, dialectError{it.parameter, it.op})
+			}
+			return nil, endEOF, newPosError(p.src, tok.pos, opError{it.op})
+		case itemParamOp:
+			if !p.isParamName(it.parameter) {
+				return nil, endEOF, p.substError(tok.pos)
+			}
+			if !strings.ContainsRune("-=?+", it.op) {
+				return nil, endEOF, newPosError(p.src, tok.pos, opError{string(it.op)})
+			}
+			word, end, err := p.parseNodes(false)
+			if err != nil {
+				return nil, end, err
+			}
+			if end != endBracket {
+				return nil, end, newPosError(p.src, tok.pos, eofError{'}'})
+			}
+			nodes = append(nodes, &ParamOpNode{
+				Name:  it.parameter,
+				Op:    it.op,
+				Colon: it.nullIsEmpty,
+				Word:  word,
+				Pos:   tok.pos,
+			})
+		}
+	}
+	return nodes, endEOF, nil
+}
+
+// isParamName reports whether the string is the name of a parameter,
+// including dotted names with the DottedNames option.
+func (p *parser) isParamName(s string) bool {
+	switch p.dialect {
+	case Kubernetes, Windows, Make:
+		return true
+	case Compose:
+		return isName(s) || p.dotted && isDottedName(s)
+	}
+	return isParamName(s) || p.dotted && isDottedName(s)
+}
+
+// substError returns a "bad substitution" error for the expansion starting
+// at the position.
+func (p *parser) substError(pos Pos) error {
+	ref := p.src[pos:]
+	if end := scriptRefEnd(ref); end > 0 {
+		ref = ref[:end]
+	}
+	return newPosError(p.src, pos, substError{ref})
+}
+
+// isParamName reports whether the string is the name of a variable, or a
+// positional or special parameter.
+func isParamName(s string) bool {
+	if len(s) == 1 && (isSpecialParam(rune(s[0])) || isNum(rune(s[0]))) {
+		return true
+	}
+	return isName(s) || isPositional(s)
+}
@@ -0,0 +1,199 @@
+package posix
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse_execute(t *testing.T) {
+	tmpl, err := Parse("${greeting:-hello}, $name!")
+	ok(t, err)
+
+	for _, c := range []struct {
+		mapping Map
+		out     string
+	}{
+		{Map{"name": "world"}, "hello, world!"},
+		{Map{"name": "you", "greeting": "hi"}, "hi, you!"},
+		{Map{}, "hello, !"},
+	} {
+		out, err := tmpl.Execute(c.mapping)
+		ok(t, err)
+		equals(t, c.out, out)
+	}
+}
+
+func TestParse_nodes(t *testing.T) {
+	tmpl, err := Parse(`a${b:=c$d}${#e}"$f"`)
+	ok(t, err)
+	equals(t, []Node{
+		&TextNode{"a"},
+		&ParamOpNode{Name: "b", Op: '=', Colon: true, Pos: 1, Word: []Node{
+			&TextNode{"c"},
+			&ParamNode{Name: "d", Pos: 7},
+		}},
+		&LengthNode{Name: "e", Pos: 10},
+		&TextNode{`"`},
+		&ParamNode{Name: "f", Pos: 16},
+		&TextNode{`"`},
+	}, tmpl.Nodes)
+}
+
+func TestParse_quoteRemovalNodes(t *testing.T) {
+	tmpl, err := (&Expander{QuoteRemoval: true}).Parse(`'a'"$b"`)
+	ok(t, err)
+	equals(t, []Node{
+		&QuotedNode{"a"},
+		&DoubleQuotedNode{[]Node{&ParamNode{Name: "b", Pos: 4}}},
+	}, tmpl.Nodes)
+}
+
+var parseErrorTests = []struct {
+	in  string
+	err string
+}{
+	{"${a", "unexpected EOF while looking for matching `}'"},
+	{"${a:-b", "unexpected EOF while looking for matching `}'"},
+	{"${a:-'b}", "unexpected EOF while looking for matching `''"},
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, test := range parseErrorTests {
+		_, err := Parse(test.in)
+		if err == nil {
+			t.Errorf("Parse(%q) expected an error", test.in)
+			continue
+		}
+		equals(t, test.err, err.Error())
+	}
+}
+
+func TestParse_options(t *testing.T) {
+	e := &Expander{QuoteRemoval: true}
+	tmpl, err := e.Parse(`'$a'`)
+	ok(t, err)
+
+	// later changes to the Expander do not affect the parsed template
+	e.QuoteRemoval = false
+	out, err := tmpl.Execute(Map{"a": "x"})
+	ok(t, err)
+	equals(t, "$a", out)
+
+	tmpl, err = Parse("${a}-$$b", WithDialect(Compose), WithNoUnset())
+	ok(t, err)
+	out, err = tmpl.Execute(Map{"a": "x"})
+	ok(t, err)
+	equals(t, "x-$b", out)
+	_, err = tmpl.Execute(Map{})
+	equals(t, "a: unbound variable", err.Error())
+	_, err = tmpl.Execute(Map{"a": "x"}, WithDialect(POSIX))
+	equals(t, true, errors.Is(err, ErrParseOption))
+
+	tmpl, err = Parse(`'$a'`, WithQuoteRemoval())
+	ok(t, err)
+	out, err = tmpl.Execute(Map{"a": "x"})
+	ok(t, err)
+	equals(t, "$a", out)
+	_, err = tmpl.Execute(Map{"a": "x"}, WithQuoteRemoval())
+	ok(t, err)
+
+	_, err = Parse("${a.b}")
+	equals(t, "${a.b}: bad substitution", err.Error())
+	_, err = Parse("${a.b}", WithDottedNames())
+	ok(t, err)
+}
+
+func TestExecute_options(t *testing.T) {
+	tmpl, err := NewExpander(WithDialect(Compose), WithNoUnset()).Parse("${a}-$$b")
+	ok(t, err)
+
+	// the options the template was parsed with are the defaults
+	_, err = tmpl.Execute(Map{})
+	equals(t, "a: unbound variable", err.Error())
+	out, err := tmpl.Execute(Map{"a": "x"})
+	ok(t, err)
+	equals(t, "x-$b", out)
+
+	// other options only apply to one expansion
+	out, err = tmpl.Execute(Map{}, WithKeepUnset())
+	ok(t, err)
+	equals(t, "${a}-$b", out)
+	_, err = tmpl.Execute(Map{"a": "xxxx"}, WithMaxOutput(4))
+	equals(t, ErrOutputLimit, err)
+	_, err = tmpl.Execute(Map{})
+	equals(t, "a: unbound variable", err.Error())
+
+	var b strings.Builder
+	ok(t, tmpl.ExecuteWriter(&b, Map{}, WithOnUnset(func(string) (string, error) { return "y", nil })))
+	equals(t, "y-$b", b.String())
+	buf, err := tmpl.AppendExecute(nil, Map{"a": ""}, WithNoEmpty())
+	equals(t, "a: parameter is empty", err.Error())
+	equals(t, "", string(buf))
+	out, err = tmpl.ExecuteContext(context.Background(), Map{"a": "z"}, WithAllow("b"))
+	ok(t, err)
+	equals(t, "${a}-$b", out)
+
+	// options that change the parsing can't be overridden
+	for _, o := range []struct {
+		opt  Option
+		name string
+	}{
+		{WithDialect(POSIX), "Dialect"},
+		{WithQuoteRemoval(), "QuoteRemoval"},
+		{WithTilde(nil), "Tilde"},
+		{WithDottedNames(), "DottedNames"},
+	} {
+		_, err := tmpl.Execute(Map{"a": "x"}, o.opt)
+		equals(t, o.name+": option can only be set when parsing", err.Error())
+		equals(t, true, errors.Is(err, ErrParseOption))
+	}
+
+	// setting a parse option to the same value is allowed
+	_, err = tmpl.Execute(Map{"a": "x"}, WithDialect(Compose))
+	ok(t, err)
+
+	bash, err := NewExpander(WithDialect(Bash)).Parse("{a,b}")
+	ok(t, err)
+	_, err = bash.Execute(Map{}, WithMaxOutput(2))
+	equals(t, true, errors.Is(err, ErrParseOption))
+}
+
+var validatetests = []struct {
+	in  string
+	err string
+}{
+	{"$a ${b:-c} ${#d} ${1} $@", ""},
+	{"${a", "unexpected EOF while looking for matching `}'"},
+	{"${a:%b}", "${a:%b}: bad substitution"},
+	{"${a%b}", "unexpected op: '%'"},
+	{"${}", "${}: bad substitution"},
+	{"${a:}", "${a:}: bad substitution"},
+	{"${!a}", "${!a}: bad substitution"},
+	{"x ${#a:} y", "${#a:}: bad substitution"},
+	{"${#a.b}", "${#a.b}: bad substitution"},
+	{"${a:-${b.c}}", "${b.c}: bad substitution"},
+	{"${foo bar}", "${foo bar}: bad substitution"},
+	{"${a.b:-x}", "${a.b:-x}: bad substitution"},
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range validatetests {
+		err := Validate(test.in)
+		if test.err == "" {
+			ok(t, err)
+			continue
+		}
+		if err == nil {
+			t.Errorf("Validate(%q) expected an error", test.in)
+			continue
+		}
+		equals(t, test.err, err.Error())
+	}
+}
+
+func TestValidate_quotes(t *testing.T) {
+	err := (&Expander{QuoteRemoval: true}).Validate(`"$a`)
+	equals(t, "unexpected EOF while looking for matching `\"'", err.Error())
+}
@@ -0,0 +1,62 @@
+package posix
+
+import (
+	"os/user"
+	"strings"
+)
+
+// UserHomeFunc returns the home directory of the named user for "~user"
+// tilde expansion, or false if the user is unknown.
+type UserHomeFunc func(name string) (dir string, exists bool)
+
+// LookupUserHome is a UserHomeFunc that looks up users known to the
+// operating system.
+func LookupUserHome(name string) (string, bool) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
+
+// tildeAllowed reports whether a tilde at the position starts a tilde-prefix.
+// After a ":", it only does in the value of an assignment, like
+// PATH=~/bin:~/sbin.
+func (l *lexer) tildeAllowed(at Pos) bool {
+	if at == l.wordStart {
+		return true
+	}
+	if l.depth > 0 {
+		return false
+	}
+	switch l.input[at-1] {
+	case ':':
+		eq := strings.IndexByte(l.input[:at], '=')
+		return eq >= 0 && isName(l.input[:eq]) && !l.blank
+	case '=':
+		return isName(l.input[:at-1])
+	}
+	return false
+}
+
+// lexTilde scans the login name following a tilde, up to the first slash,
+// or the end of the word.
+func lexTilde(l *lexer) stateFn {
+	for {
+		c := l.next()
+		switch {
+		case c == eof, c == '/', c == ':' && l.depth == 0, c == '}' && l.depth > 0,
+			l.depth == 0 && (c == ' ' || c == '\t' || c == '\n'):
+			l.backup()
+			l.emit(itemTilde(l.token()))
+			l.ignore()
+			return lexText
+		case !isAlphaNum(c) && c != '.' && c != '-':
+			// quoting or expansions in the prefix disable tilde expansion
+			l.backup()
+			l.emit(itemText("~" + l.token()))
+			l.ignore()
+			return lexText
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+var tildetests = []struct {
+	in  string
+	out string
+}{
+	{"~", "/home/me"},
+	{"~/bin", "/home/me/bin"},
+	{"~alice/bin", "/home/alice/bin"},
+	{"~bob/bin", "~bob/bin"},
+	{"a~", "a~"},
+	{"a/~/b", "a/~/b"},
+	{"PATH=~/bin:~alice:~", "PATH=/home/me/bin:/home/alice:/home/me"},
+	{"~/bin:~alice:~", "/home/me/bin:~alice:~"},
+	{"echo a:~", "echo a:~"},
+	{"PATH=a b:~", "PATH=a b:~"},
+	{"a:b=c:~", "a:b=c:~"},
+	{"PATH=~/bin:~alice/bin", "PATH=/home/me/bin:/home/alice/bin"},
+	{"a b=~", "a b=~"},
+	{"${unset:-~/x}", "/home/me/x"},
+	{"${unset:-a~}", "a~"},
+	{"${set:+~alice}", "/home/alice"},
+	{"~$set", "~yes"},
+	{`~"alice"`, `~"alice"`},
+	{"${unset-'~'}", "~"},
+}
+
+func TestExpand_tilde(t *testing.T) {
+	mapping := Map{
+		"HOME": "/home/me",
+		"set":  "yes",
+	}
+	e := &Expander{
+		Tilde: true,
+		UserHome: func(name string) (string, bool) {
+			if name == "alice" {
+				return "/home/alice", true
+			}
+			return "", false
+		},
+	}
+
+	for _, tt := range tildetests {
+		x, err := e.Expand(tt.in, mapping)
+		if err != nil {
+			t.Errorf("pattern %#v should not have produced an error, but got: %s", tt.in, err)
+		}
+		if x != tt.out {
+			t.Errorf("pattern %#v should expand to %#v, but got %#v", tt.in, tt.out, x)
+		}
+	}
+}
+
+func TestExpand_tildeDisabled(t *testing.T) {
+	x, err := Expand("~/bin", Map{"HOME": "/home/me"})
+	ok(t, err)
+	equals(t, "~/bin", x)
+}
+
+func TestExpand_tildeUnsetHome(t *testing.T) {
+	e := &Expander{Tilde: true}
+	x, err := e.Expand("~/bin:~alice", Map{})
+	ok(t, err)
+	equals(t, "~/bin:~alice", x)
+}
+
+func TestExpand_tildeQuotedBlank(t *testing.T) {
+	e := &Expander{Tilde: true, QuoteRemoval: true}
+	x, err := e.Expand(`PATH='a b':~ "c d":~`, Map{"HOME": "/home/me"})
+	ok(t, err)
+	equals(t, "PATH=a b:/home/me c d:~", x)
+}
+
+// failingMap is an ErrGetter whose lookups fail.
+type failingMap struct {
+	Map
+}
+
+func (failingMap) Lookup(k string) (string, bool, error) {
+	return "", false, errLookup
+}
+
+func TestExpand_tildeHomeLookup(t *testing.T) {
+	home := Map{"HOME": "/home/me"}
+
+	// HOME is looked up like a reference to it
+	x, err := Expand("~ $USER", home, WithTilde(nil), WithAllow("USER"))
+	ok(t, err)
+	equals(t, "~ ", x)
+	_, err = Expand("~", home, WithTilde(nil), WithAllow("USER"), WithDisallowError())
+	equals(t, "HOME: variable not allowed", err.Error())
+
+	_, err = Expand("~", failingMap{home}, WithTilde(nil))
+	equals(t, true, errors.Is(err, errLookup))
+
+	a := NewAudit(home)
+	x, err = Expand("~/a ~ $HOME", a, WithTilde(nil), WithMemoize())
+	ok(t, err)
+	equals(t, "/home/me/a ~ /home/me", x)
+	equals(t, 1, len(a.Log()))
+}
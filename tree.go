@@ -0,0 +1,73 @@
+package posix
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tree is the interface for hierarchical key/value backends, such as the
+// Windows registry or nested configuration maps.
+type Tree interface {
+	Lookup(path []string) (value string, exists bool)
+}
+
+// TreeFunc implements the Tree interface for lookup functions.
+type TreeFunc func(path []string) (string, bool)
+
+func (f TreeFunc) Lookup(path []string) (string, bool) {
+	return f(path)
+}
+
+// DefaultTreeSeparator separates the parts of a parameter name for a
+// TreeGetter without a Separator, so ${a__b__c} looks up the path a, b, c.
+const DefaultTreeSeparator = "__"
+
+// TreeGetter implements the Getter interface for a Tree, by splitting
+// parameter names into paths on the Separator.
+type TreeGetter struct {
+	Tree      Tree
+	Separator string
+}
+
+func (g TreeGetter) Get(k string) (string, bool) {
+	sep := g.Separator
+	if sep == "" {
+		sep = DefaultTreeSeparator
+	}
+	return g.Tree.Lookup(strings.Split(k, sep))
+}
+
+// NestedMap implements the Tree interface for nested maps, such as decoded
+// JSON or YAML documents. Scalar values are formatted with fmt.Sprint. A nil
+// value, such as a JSON null, is unset, as it is for a JSONGetter, and paths
+// ending at a nested map are not set.
+type NestedMap map[string]any
+
+func (m NestedMap) Lookup(path []string) (string, bool) {
+	var node any = map[string]any(m)
+	for _, key := range path {
+		var child any
+		var ok bool
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok = n[key]
+		case NestedMap:
+			child, ok = n[key]
+		}
+		if !ok {
+			return "", false
+		}
+		node = child
+	}
+
+	switch v := node.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case map[string]any, NestedMap:
+		return "", false
+	default:
+		return fmt.Sprint(v), true
+	}
+}
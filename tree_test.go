@@ -0,0 +1,48 @@
+package posix
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTreeGetter(t *testing.T) {
+	tree := NestedMap{
+		"server": map[string]any{
+			"host": "example.com",
+			"port": 8080,
+			"tls":  NestedMap{"enabled": true},
+		},
+		"empty": nil,
+	}
+
+	x, err := Expand("${server__host}:${server__port} ${server__tls__enabled} [${empty-unset}] ${server-unset} ${server__missing-unset}", TreeGetter{Tree: tree})
+	ok(t, err)
+	equals(t, "example.com:8080 true [unset] unset unset", x)
+}
+
+func TestNestedMap_null(t *testing.T) {
+	var obj map[string]any
+	ok(t, json.Unmarshal([]byte(`{"a": {"b": null, "c": ""}}`), &obj))
+	in := "${a.b-unset} ${a.b:-empty} [${a.c-unset}]"
+
+	x, err := Expand(in, JSONObject(obj), WithDottedNames())
+	ok(t, err)
+	equals(t, "unset empty []", x)
+
+	x, err = Expand(in, TreeGetter{Tree: NestedMap(obj), Separator: "."}, WithDottedNames())
+	ok(t, err)
+	equals(t, "unset empty []", x)
+}
+
+func TestTreeGetter_separator(t *testing.T) {
+	tree := TreeFunc(func(path []string) (string, bool) {
+		if len(path) == 2 && path[0] == "HKLM" {
+			return "value of " + path[1], true
+		}
+		return "", false
+	})
+
+	x, err := Expand("${HKLM_Path} ${HKCU_Path-none}", TreeGetter{Tree: tree, Separator: "_"})
+	ok(t, err)
+	equals(t, "value of Path none", x)
+}
@@ -0,0 +1,57 @@
+package posix
+
+// Trust is the interface for mappings that know which variables have values
+// from a trusted source.
+type Trust interface {
+	Trusted(name string) bool
+}
+
+// Span is a range of bytes in the output of an expansion, from Start up to
+// but not including End, that was read from the parameter Param.
+type Span struct {
+	Start, End int
+	Param      string
+}
+
+// ExpandUntrusted expands the string like Expand, and also reports the spans
+// of the output that were read from untrusted parameters, so they can be
+// validated or escaped before the output is used.
+//
+// If the mapping implements Trust, parameters are untrusted when Trusted
+// returns false, otherwise all parameters are untrusted. Text from the
+// template itself, including the words of operators like ${var:-word}, is
+// trusted, while values from nested expansions in those words are checked
+// separately.
+func ExpandUntrusted(s string, mapping Getter) (string, []Span, error) {
+	return new(Expander).ExpandUntrusted(s, mapping)
+}
+
+// ExpandUntrusted expands the string and reports its untrusted spans like
+// ExpandUntrusted, using the options set on the Expander.
+func (e *Expander) ExpandUntrusted(s string, mapping Getter) (string, []Span, error) {
+	t, err := e.Parse(s)
+	if err != nil {
+		return "", nil, err
+	}
+	ev := &evaluator{mapping: mapping, opts: e, src: t.src}
+	segs, err := ev.evalAll(t.Nodes)
+	if err != nil && !e.AllErrors {
+		return "", nil, e.wrapError(err)
+	}
+
+	trust, _ := mapping.(Trust)
+	var spans []Span
+	pos := 0
+	for _, seg := range segs {
+		end := pos + len(seg.text)
+		if seg.param != "" && end > pos && (trust == nil || !trust.Trusted(seg.param)) {
+			if n := len(spans); n > 0 && spans[n-1].End == pos && spans[n-1].Param == seg.param {
+				spans[n-1].End = end
+			} else {
+				spans = append(spans, Span{pos, end, seg.param})
+			}
+		}
+		pos = end
+	}
+	return segs.String(), spans, e.wrapError(err)
+}
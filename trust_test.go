@@ -0,0 +1,44 @@
+package posix
+
+import "testing"
+
+// trustMap is a Map where only the listed names are trusted.
+type trustMap struct {
+	RWMap
+	trusted map[string]bool
+}
+
+func (m trustMap) Trusted(name string) bool {
+	return m.trusted[name]
+}
+
+func TestExpandUntrusted(t *testing.T) {
+	mapping := trustMap{
+		RWMap{"user": "bob; rm -rf /", "host": "example.com", "null": ""},
+		map[string]bool{"host": true},
+	}
+
+	x, spans, err := ExpandUntrusted("ssh ${user}@$host ${null} ${unset:-$user!} ${unset2:=$host-$user}", mapping)
+	ok(t, err)
+	equals(t, "ssh bob; rm -rf /@example.com  bob; rm -rf /! example.com-bob; rm -rf /", x)
+	equals(t, []Span{
+		{4, 17, "user"},
+		{31, 44, "user"},
+		{58, 71, "user"},
+	}, spans)
+	for _, span := range spans {
+		equals(t, "bob; rm -rf /", x[span.Start:span.End])
+	}
+}
+
+func TestExpandUntrusted_allUntrusted(t *testing.T) {
+	x, spans, err := ExpandUntrusted("$a$b", Map{"a": "1", "b": "2"})
+	ok(t, err)
+	equals(t, "12", x)
+	equals(t, []Span{{0, 1, "a"}, {1, 2, "b"}}, spans)
+}
+
+func TestExpandUntrusted_error(t *testing.T) {
+	_, _, err := ExpandUntrusted("${a:?missing}", Map{})
+	equals(t, "missing", err.Error())
+}
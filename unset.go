@@ -0,0 +1,28 @@
+package posix
+
+import "errors"
+
+// ErrUnset is returned by an OnUnset callback to leave the variable unset.
+var ErrUnset = errors.New("variable is not set")
+
+// fetch returns the value of a parameter like lookupErr, calling the OnUnset
+// callback for variables that are not set.
+func (ev *evaluator) fetch(name string, pos Pos) (string, bool, error) {
+	ev.at(pos)
+	defer ev.at(-1)
+	v, ok, err := ev.lookupErr(name)
+	if err != nil {
+		return "", false, err
+	}
+	if ok || ev.opts.OnUnset == nil || !isVarName(name) {
+		return v, ok, nil
+	}
+	v, err = ev.opts.OnUnset(name)
+	if errors.Is(err, ErrUnset) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return v, true, nil
+}
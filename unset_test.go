@@ -0,0 +1,48 @@
+package posix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpand_onUnset(t *testing.T) {
+	var calls []string
+	secondary := Map{"B": "b"}
+	onUnset := func(name string) (string, error) {
+		calls = append(calls, name)
+		if v, ok := secondary.Get(name); ok {
+			return v, nil
+		}
+		return "", ErrUnset
+	}
+
+	out, err := Expand("$A $B ${C-c} ${B:+set}", Map{"A": "a"}, WithOnUnset(onUnset))
+	ok(t, err)
+	equals(t, "a b c set", out)
+	equals(t, []string{"B", "C", "B"}, calls)
+}
+
+func TestExpand_onUnsetError(t *testing.T) {
+	errMissing := errors.New("missing required setting")
+	onUnset := func(name string) (string, error) {
+		return "", errMissing
+	}
+
+	_, err := Expand("x ${A:-default}", Map{}, WithOnUnset(onUnset))
+	if !errors.Is(err, errMissing) {
+		t.Fatalf("expected the callback's error, got: %#v", err)
+	}
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) {
+		t.Fatalf("expected an ExpandError, got: %#v", err)
+	}
+	equals(t, "A", expandErr.Name)
+}
+
+func TestExpand_onUnsetNoUnset(t *testing.T) {
+	onUnset := func(name string) (string, error) {
+		return "", ErrUnset
+	}
+	_, err := Expand("$A", Map{}, WithOnUnset(onUnset), WithNoUnset())
+	equals(t, "A: unbound variable", err.Error())
+}
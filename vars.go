@@ -0,0 +1,67 @@
+package posix
+
+// Vars parses the string and returns the names of the parameters it
+// references, in the order they first appear. This includes parameters
+// referenced within the words of operators, such as ${a:-$b}.
+func Vars(s string) ([]string, error) {
+	return new(Expander).Vars(s)
+}
+
+// Vars parses the string with the options set on the Expander and returns
+// the names of the parameters it references, like Vars.
+func (e *Expander) Vars(s string) ([]string, error) {
+	t, err := e.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return t.Vars(), nil
+}
+
+// Vars returns the names of the parameters referenced by the template, in
+// the order they first appear.
+func (t *Template) Vars() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, ref := range t.Refs() {
+		if !seen[ref.Name] {
+			seen[ref.Name] = true
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
+
+// Ref is a reference to a parameter in a template.
+type Ref struct {
+	Name   string
+	Pos    Pos // the offset of the reference in the input
+	Line   int // the line number of Pos, starting at 1
+	Column int // the byte offset of Pos within its line, starting at 1
+}
+
+// Refs returns each reference to a parameter in the template, in the order
+// they appear, including "~" as a reference to HOME. Like the positions of
+// errors, the positions are in the input after any brace expansion.
+func (t *Template) Refs() []Ref {
+	var refs []Ref
+	add := func(name string, pos Pos) {
+		line, col := position(t.src, pos)
+		refs = append(refs, Ref{Name: name, Pos: pos, Line: line, Column: col})
+	}
+	t.Walk(func(n Node) bool {
+		switch n := n.(type) {
+		case *ParamNode:
+			add(n.Name, n.Pos)
+		case *LengthNode:
+			add(n.Name, n.Pos)
+		case *ParamOpNode:
+			add(n.Name, n.Pos)
+		case *TildeNode:
+			if n.User == "" {
+				add("HOME", n.Pos)
+			}
+		}
+		return true
+	})
+	return refs
+}
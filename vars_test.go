@@ -0,0 +1,50 @@
+package posix
+
+import "testing"
+
+var varstests = []struct {
+	in   string
+	vars []string
+}{
+	{"plain text", nil},
+	{"$a", []string{"a"}},
+	{"$a ${b} $a", []string{"a", "b"}},
+	{"${#a}", []string{"a"}},
+	{"${a:-$b}", []string{"a", "b"}},
+	{"${a:+${b:=${c}}}", []string{"a", "b", "c"}},
+	{"${a:-'$b'}", []string{"a"}},
+	{"$1 $@ $?", []string{"1", "@", "?"}},
+}
+
+func TestVars(t *testing.T) {
+	for _, test := range varstests {
+		vars, err := Vars(test.in)
+		ok(t, err)
+		equals(t, test.vars, vars)
+	}
+}
+
+func TestVars_tilde(t *testing.T) {
+	vars, err := (&Expander{Tilde: true}).Vars("~/bin:$PATH")
+	ok(t, err)
+	equals(t, []string{"HOME", "PATH"}, vars)
+}
+
+func TestVars_error(t *testing.T) {
+	_, err := Vars("${a")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestTemplate_refs(t *testing.T) {
+	tmpl, err := (&Expander{Tilde: true}).Parse("~/bin:$PATH\n  ${a:-$b} ${#a}")
+	ok(t, err)
+	equals(t, []Ref{
+		{Name: "HOME", Pos: 0, Line: 1, Column: 1},
+		{Name: "PATH", Pos: 6, Line: 1, Column: 7},
+		{Name: "a", Pos: 14, Line: 2, Column: 3},
+		{Name: "b", Pos: 19, Line: 2, Column: 8},
+		{Name: "a", Pos: 23, Line: 2, Column: 12},
+	}, tmpl.Refs())
+}
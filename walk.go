@@ -0,0 +1,64 @@
+package posix
+
+// Walk traverses the nodes in depth-first order, calling fn for each node.
+// If fn returns false, the children of that node are skipped.
+func Walk(nodes []Node, fn func(Node) bool) {
+	for _, n := range nodes {
+		if !fn(n) {
+			continue
+		}
+		switch n := n.(type) {
+		case *DoubleQuotedNode:
+			Walk(n.Nodes, fn)
+		case *ParamOpNode:
+			Walk(n.Word, fn)
+		}
+	}
+}
+
+// Walk traverses the nodes of the template, like Walk.
+func (t *Template) Walk(fn func(Node) bool) {
+	Walk(t.Nodes, fn)
+}
+
+// Rewrite returns a copy of the nodes with each node replaced by the result
+// of fn. The children of a node are rewritten before the node itself. fn is
+// called with a copy of each node, which it may modify in place, and returns
+// the nodes to replace it, or nil to remove it. The original nodes are not
+// modified.
+func Rewrite(nodes []Node, fn func(Node) []Node) []Node {
+	var out []Node
+	for _, n := range nodes {
+		switch x := n.(type) {
+		case *DoubleQuotedNode:
+			n = &DoubleQuotedNode{Rewrite(x.Nodes, fn)}
+		case *ParamOpNode:
+			op := *x
+			op.Word = Rewrite(x.Word, fn)
+			n = &op
+		case *TextNode:
+			c := *x
+			n = &c
+		case *QuotedNode:
+			c := *x
+			n = &c
+		case *ParamNode:
+			c := *x
+			n = &c
+		case *LengthNode:
+			c := *x
+			n = &c
+		case *TildeNode:
+			c := *x
+			n = &c
+		}
+		out = append(out, fn(n)...)
+	}
+	return out
+}
+
+// Rewrite returns a new template with the nodes replaced by the result of
+// fn, like Rewrite. The new template keeps the options of the original.
+func (t *Template) Rewrite(fn func(Node) []Node) *Template {
+	return &Template{Nodes: Rewrite(t.Nodes, fn), opts: t.opts, src: t.src}
+}
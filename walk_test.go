@@ -0,0 +1,102 @@
+package posix
+
+import "testing"
+
+func TestWalk(t *testing.T) {
+	tmpl, err := (&Expander{QuoteRemoval: true}).Parse(`a"$b"${c:-${#d}}`)
+	ok(t, err)
+
+	var names []string
+	tmpl.Walk(func(n Node) bool {
+		switch n := n.(type) {
+		case *TextNode:
+			names = append(names, "text "+n.Text)
+		case *ParamNode:
+			names = append(names, "param "+n.Name)
+		case *LengthNode:
+			names = append(names, "length "+n.Name)
+		case *ParamOpNode:
+			names = append(names, "op "+n.Name)
+		case *DoubleQuotedNode:
+			names = append(names, "quotes")
+		}
+		return true
+	})
+	equals(t, []string{"text a", "quotes", "param b", "op c", "length d"}, names)
+}
+
+func TestWalk_skipChildren(t *testing.T) {
+	tmpl, err := Parse("${a:-$b}$c")
+	ok(t, err)
+
+	var names []string
+	tmpl.Walk(func(n Node) bool {
+		switch n := n.(type) {
+		case *ParamNode:
+			names = append(names, n.Name)
+		case *ParamOpNode:
+			names = append(names, n.Name)
+			return false
+		}
+		return true
+	})
+	equals(t, []string{"a", "c"}, names)
+}
+
+func TestRewrite_rename(t *testing.T) {
+	tmpl, err := Parse("$old ${old:-$other}")
+	ok(t, err)
+
+	renamed := tmpl.Rewrite(func(n Node) []Node {
+		switch n := n.(type) {
+		case *ParamNode:
+			if n.Name == "old" {
+				n.Name = "new"
+			}
+		case *ParamOpNode:
+			if n.Name == "old" {
+				n.Name = "new"
+			}
+		}
+		return []Node{n}
+	})
+	equals(t, []string{"new", "other"}, renamed.Vars())
+
+	// the original template is unchanged
+	equals(t, []string{"old", "other"}, tmpl.Vars())
+}
+
+func TestRewrite_inline(t *testing.T) {
+	tmpl, err := Parse("$host:${port:-80}")
+	ok(t, err)
+
+	consts := Map{"host": "example.com"}
+	inlined := tmpl.Rewrite(func(n Node) []Node {
+		if p, isParam := n.(*ParamNode); isParam {
+			if v, set := consts.Get(p.Name); set {
+				return []Node{&TextNode{v}}
+			}
+		}
+		return []Node{n}
+	})
+	equals(t, []string{"port"}, inlined.Vars())
+
+	out, err := inlined.Execute(Map{"port": "8080"})
+	ok(t, err)
+	equals(t, "example.com:8080", out)
+}
+
+func TestRewrite_stripOperators(t *testing.T) {
+	tmpl, err := Parse("${a:-default}")
+	ok(t, err)
+
+	stripped := tmpl.Rewrite(func(n Node) []Node {
+		if op, isOp := n.(*ParamOpNode); isOp {
+			return []Node{&ParamNode{Name: op.Name}}
+		}
+		return []Node{n}
+	})
+	out, err := stripped.Execute(Map{})
+	ok(t, err)
+	equals(t, "", out)
+}
@@ -0,0 +1,52 @@
+package posix
+
+import (
+	"bytes"
+	"strings"
+)
+
+// lexWindows lexes the input for the Windows dialect, where the only
+// expansions are %name% references and %% escapes. A "%" without a closing
+// "%" on the same line is literal text.
+func lexWindows(l *lexer) stateFn {
+	for {
+		i := strings.IndexByte(l.input[l.pos:], '%')
+		if i < 0 {
+			l.pos = Pos(len(l.input))
+			l.emitText()
+			return nil
+		}
+		l.pos += Pos(i)
+		rest := l.input[l.pos+1:]
+		end := strings.IndexAny(rest, "%\n")
+		switch {
+		case end == 0:
+			l.emitText()
+			l.pos += 2
+			l.emit(itemQuotedText("%"))
+			l.ignore()
+		case end > 0 && rest[end] == '%':
+			l.emitText()
+			l.exprStart = l.pos
+			l.emit(itemReadParam(rest[:end]))
+			l.pos += Pos(end) + 2
+			l.ignore()
+		default:
+			l.pos++
+		}
+	}
+}
+
+// percentRefEnd returns the length of the %name% reference or %% escape at
+// the start of the input, 1 if the "%" does not start either, or -1 if it
+// may continue past the end of the input.
+func percentRefEnd(in []byte) int {
+	end := bytes.IndexAny(in[1:], "%\n")
+	switch {
+	case end < 0:
+		return -1
+	case in[1+end] == '\n':
+		return 1
+	}
+	return end + 2
+}
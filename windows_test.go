@@ -0,0 +1,49 @@
+package posix
+
+import "testing"
+
+var windowstests = []dialecttest{
+	{"plain", "plain"},
+	{`%USERPROFILE%\bin;%PATH%`, `C:\Users\me\bin;C:\Windows`},
+	{"100%% sure %%PATH%%", "100% sure %PATH%"},
+	{"%UNSET%|%EMPTY%|", "||"},
+	{"%Program Files%", `C:\Program Files`},
+	{"50% off\n%PATH%", "50% off\nC:\\Windows"},
+	{"trailing % and %", "trailing "}, // a reference to an unset " and "
+	{"%", "%"},
+	{"%PATH", "%PATH"},
+	{`$PATH ${PATH} "%PATH%" '%PATH%' ~`, `$PATH ${PATH} "C:\Windows" 'C:\Windows' ~`},
+}
+
+func TestExpand_windows(t *testing.T) {
+	mapping := Map{
+		"USERPROFILE":   `C:\Users\me`,
+		"PATH":          `C:\Windows`,
+		"Program Files": `C:\Program Files`,
+		"EMPTY":         "",
+	}
+	testDialect(t, Windows, mapping, windowstests)
+}
+
+func TestExpand_windowsOptions(t *testing.T) {
+	mapping := NewFoldGetter([]string{`Path=C:\Windows`})
+	out, err := Expand("%PATH% %path% %UNSET% %no such%", mapping, WithDialect(Windows), WithKeepUnset())
+	ok(t, err)
+	equals(t, `C:\Windows C:\Windows %UNSET% %no such%`, out)
+
+	out, err = Expand("50% off % and %PATH%", mapping, WithDialect(Windows), WithKeepUnset())
+	ok(t, err)
+	equals(t, "50% off % and C:\\Windows", out)
+
+	tmpl, err := NewExpander(WithDialect(Windows)).Parse("50% off % and %PATH%")
+	ok(t, err)
+	equals(t, []string{" off ", "PATH"}, tmpl.Vars())
+
+	_, err = Expand("%PATH% %UNSET%", mapping, WithDialect(Windows), WithNoUnset())
+	equals(t, "UNSET: unbound variable", err.Error())
+
+	tmpl, err = NewExpander(WithDialect(Windows)).Parse("%A% 5%% $B")
+	ok(t, err)
+	equals(t, "%A% 5%% $B", tmpl.String())
+	equals(t, []string{"A"}, tmpl.Vars())
+}
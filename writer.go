@@ -0,0 +1,44 @@
+package posix
+
+import "io"
+
+// ExpandWriter expands the string like Expand, writing the result to w as
+// each part of it is expanded rather than building the whole result in
+// memory. If the expansion fails, the output before the error has already
+// been written.
+func ExpandWriter(w io.Writer, s string, mapping Getter) error {
+	return new(Expander).ExpandWriter(w, s, mapping)
+}
+
+// ExpandWriter expands the string and writes the result to w like
+// ExpandWriter, using the options set on the Expander.
+func (e *Expander) ExpandWriter(w io.Writer, s string, mapping Getter) error {
+	t, err := e.Parse(s)
+	if err != nil {
+		return err
+	}
+	return t.ExecuteWriter(w, mapping)
+}
+
+// ExecuteWriter expands the template based on the mapping like Execute,
+// writing the result to w as each part of it is expanded.
+func (t *Template) ExecuteWriter(w io.Writer, mapping Getter, opts ...Option) error {
+	e, err := t.options(opts)
+	if err != nil {
+		return err
+	}
+	ev := &evaluator{mapping: mapping, opts: e, src: t.src}
+	var segs segments
+	for _, n := range t.Nodes {
+		segs, err = evalNodes(ev, segs[:0], []Node{n})
+		if err != nil {
+			return e.wrapError(err)
+		}
+		for _, seg := range segs {
+			if _, err := io.WriteString(w, seg.text); err != nil {
+				return err
+			}
+		}
+	}
+	return e.wrapError(ev.collected())
+}
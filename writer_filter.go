@@ -0,0 +1,76 @@
+package posix
+
+import (
+	"errors"
+	"io"
+)
+
+// NewWriter returns a writer that expands the parameters in the data
+// written to it, like Expand, and writes the result to w. Data is held until
+// the expansions in it are complete, so Close must be called to expand and
+// write the remaining data. Close does not close w.
+func NewWriter(w io.Writer, mapping Getter) io.WriteCloser {
+	return new(Expander).NewWriter(w, mapping)
+}
+
+// NewWriter returns a writer that expands the data written to it like
+// NewWriter, using the options set on the Expander. The data is divided into
+// parts the same as for NewReader, and MaxOutput, Memoize and AllErrors
+// apply to all of the data written, with the errors of AllErrors returned
+// by Close.
+func (e *Expander) NewWriter(w io.Writer, mapping Getter) io.WriteCloser {
+	return &writer{w: w, split: e.splitter(), st: &stream{e: e, mapping: mapping}}
+}
+
+type writer struct {
+	w     io.Writer
+	split *splitter
+	st    *stream
+	in    []byte // input that has not been expanded yet
+	err   error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.in = append(w.in, p...)
+	if err := w.flush(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close expands and writes the remaining data.
+func (w *writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flush(true); err != nil {
+		return err
+	}
+	if err := w.st.collected(); err != nil {
+		w.err = err
+		return err
+	}
+	w.err = errWriterClosed
+	return nil
+}
+
+// flush expands and writes the pending input that can be expanded.
+func (w *writer) flush(eof bool) error {
+	n := w.split.prefix(w.in, eof)
+	if n == 0 {
+		return nil
+	}
+	out, err := w.st.expand(string(w.in[:n]))
+	w.in = w.in[:copy(w.in, w.in[n:])]
+	if err != nil {
+		w.err = err
+		return err
+	}
+	_, w.err = io.WriteString(w.w, out)
+	return w.err
+}
+
+var errWriterClosed = errors.New("posix: write to closed writer")
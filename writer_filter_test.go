@@ -0,0 +1,96 @@
+package posix
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNewWriter(t *testing.T) {
+	mapping := Map{"a": "1", "b": "2"}
+	for _, test := range readertests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, mapping)
+		// write one byte at a time, so expansions are split across writes
+		for i := 0; i < len(test.in); i++ {
+			n, err := w.Write([]byte{test.in[i]})
+			ok(t, err)
+			equals(t, 1, n)
+		}
+		ok(t, w.Close())
+		equals(t, test.out, buf.String())
+	}
+}
+
+func TestNewWriter_partial(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Map{"name": "world"})
+	_, err := io.WriteString(w, "hello $na")
+	ok(t, err)
+	equals(t, "hello ", buf.String())
+
+	_, err = io.WriteString(w, "me!")
+	ok(t, err)
+	equals(t, "hello world!", buf.String())
+	ok(t, w.Close())
+}
+
+func TestNewWriter_error(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Map{})
+	_, err := io.WriteString(w, "${a:?missing} ")
+	equals(t, "missing", err.Error())
+
+	_, err = io.WriteString(w, "more")
+	equals(t, "missing", err.Error())
+	equals(t, "missing", w.Close().Error())
+}
+
+func TestNewWriter_sharedState(t *testing.T) {
+	// the options apply to all of the data, when it is written in pieces
+	var buf bytes.Buffer
+	w := NewExpander(WithMaxOutput(5000)).NewWriter(&buf, Map{"a": "0123456789"})
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		_, err = io.WriteString(w, "$a ")
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	equals(t, ErrOutputLimit, err)
+	equals(t, true, buf.Len() <= 5000)
+
+	buf.Reset()
+	m := &countingMap{Map: Map{"a": "1"}}
+	w = NewExpander(WithMemoize()).NewWriter(&buf, m)
+	for _, word := range []string{"$a", " $a", " $a"} {
+		_, err := io.WriteString(w, word)
+		ok(t, err)
+	}
+	ok(t, w.Close())
+	equals(t, "1 1 1", buf.String())
+	equals(t, 1, m.count("a"))
+
+	buf.Reset()
+	w = NewExpander(WithAllErrors()).NewWriter(&buf, Map{"a": "1"})
+	for _, word := range []string{"${b:?}", " $a", " ${c:?}"} {
+		_, err := io.WriteString(w, word)
+		ok(t, err)
+	}
+	equals(t, "b: parameter null or not set\nc: parameter null or not set", w.Close().Error())
+	equals(t, " 1 ", buf.String())
+}
+
+func TestNewWriter_closed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, Map{"a": "1"})
+	_, err := io.Copy(w, strings.NewReader("${a"))
+	ok(t, err)
+	equals(t, "unexpected EOF while looking for matching `}'", w.Close().Error())
+
+	w = NewWriter(&buf, Map{})
+	ok(t, w.Close())
+	_, err = w.Write([]byte("x"))
+	equals(t, errWriterClosed, err)
+}
@@ -0,0 +1,51 @@
+package posix
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExpandWriter(t *testing.T) {
+	var buf bytes.Buffer
+	err := ExpandWriter(&buf, "a=$a b=${b:-default}", Map{"a": "1"})
+	ok(t, err)
+	equals(t, "a=1 b=default", buf.String())
+}
+
+func TestExpandWriter_error(t *testing.T) {
+	var buf bytes.Buffer
+	err := ExpandWriter(&buf, "a=$a b=${b:?required} c", Map{"a": "1"})
+	equals(t, "required", err.Error())
+	equals(t, "a=1 b=", buf.String())
+}
+
+func TestExpandWriter_allErrors(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewExpander(WithAllErrors()).ExpandWriter(&buf, "${a:?} ${b:?} c", Map{})
+	equals(t, "a: parameter null or not set\nb: parameter null or not set", err.Error())
+	equals(t, "  c", buf.String())
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestExpandWriter_writeError(t *testing.T) {
+	err := ExpandWriter(failingWriter{}, "$a", Map{"a": "1"})
+	equals(t, errWrite, err)
+}
+
+func TestTemplate_ExecuteWriter(t *testing.T) {
+	tmpl, err := Parse("hello, $name")
+	ok(t, err)
+	for _, name := range []string{"world", "you"} {
+		var buf bytes.Buffer
+		ok(t, tmpl.ExecuteWriter(&buf, Map{"name": name}))
+		equals(t, "hello, "+name, buf.String())
+	}
+}